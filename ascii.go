@@ -0,0 +1,311 @@
+package loops
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// DrawASCII writes a text diagram of the system's topology.
+//
+// Blocks are drawn as boxes, such as [3 Add] for block 3 of type Add.
+// The longest chain of blocks is drawn from left to right.
+// Paths which leave the chain and return to it, such as feedback loops,
+// are drawn below it. All remaining connections are listed at the end.
+func (s *System) DrawASCII(w io.Writer) error {
+	label := func(b int) string {
+		return fmt.Sprintf("[%d %s]", b, typeName(s.blocks[b].Block))
+	}
+
+	// Collect block to block connections.
+	// out[b] lists the connections leaving block b in order.
+	var conns []connection
+	out := make([][]int, len(s.blocks))
+	for _, c := range s.connections {
+		if c.src < 0 || c.dst < 0 || c.o < 0 || c.i < 0 {
+			continue
+		}
+		out[c.src] = append(out[c.src], len(conns))
+		conns = append(conns, c)
+	}
+	drawn := make([]bool, len(conns))
+
+	// follow walks along the first connection to a block
+	// which has not been visited, and returns the path.
+	follow := func(start int) (path, via []int) {
+		path = []int{start}
+		seen := map[int]bool{start: true}
+		for b := start; ; {
+			next := -1
+			for _, k := range out[b] {
+				if d := conns[k].dst; !seen[d] {
+					next = k
+					break
+				}
+			}
+			if next < 0 {
+				return path, via
+			}
+			b = conns[next].dst
+			seen[b] = true
+			path = append(path, b)
+			via = append(via, next)
+		}
+	}
+
+	// The main row is the longest chain, preferably starting at a source.
+	var row, rowVia []int
+	for pass := 0; pass < 2 && row == nil; pass++ {
+		for b := range s.blocks {
+			if pass == 0 && len(s.blocks[b].In) > 0 {
+				continue
+			}
+			if p, v := follow(b); len(p) > len(row) {
+				row, rowVia = p, v
+			}
+		}
+	}
+	if row == nil {
+		_, err := fmt.Fprintln(w, "(empty system)")
+		return err
+	}
+	for _, k := range rowVia {
+		drawn[k] = true
+	}
+
+	// Find paths leaving the row, passing through blocks which are
+	// not on the row and returning to it.
+	pos := make(map[int]int) // position of a block on the row
+	for n, b := range row {
+		pos[b] = n
+	}
+	onRow := func(b int) bool { _, ok := pos[b]; return ok }
+	placed := make(map[int]bool)
+	for _, b := range row {
+		placed[b] = true
+	}
+	type loop struct {
+		src, dst int
+		inner    string // blocks of the path with the arrows
+	}
+	var loops []loop
+	for _, src := range row {
+		for _, k := range out[src] {
+			if drawn[k] {
+				continue
+			}
+			// Walk off the row until it is reached again.
+			var path []int
+			via := []int{k}
+			inPath := make(map[int]bool)
+			d := conns[k].dst
+			for d >= 0 && !onRow(d) {
+				if placed[d] || inPath[d] {
+					d = -1
+					break
+				}
+				path = append(path, d)
+				inPath[d] = true
+				next := -1
+				for _, n := range out[d] {
+					if e := conns[n].dst; onRow(e) || !placed[e] && !inPath[e] {
+						next = n
+						break
+					}
+				}
+				if next < 0 {
+					d = -1
+					break
+				}
+				via = append(via, next)
+				d = conns[next].dst
+			}
+			if d < 0 {
+				continue
+			}
+			for _, k := range via {
+				drawn[k] = true
+			}
+			for _, b := range path {
+				placed[b] = true
+			}
+			names := make([]string, len(path))
+			for n, b := range path {
+				names[n] = label(b)
+			}
+			var inner string
+			if pos[d] < pos[src] {
+				for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+					names[i], names[j] = names[j], names[i]
+				}
+				inner = strings.Join(names, " <-- ")
+				if inner != "" {
+					inner = " " + inner + " <"
+				}
+			} else {
+				inner = strings.Join(names, " --> ")
+				if inner != "" {
+					inner = "> " + inner + " "
+				}
+			}
+			loops = append(loops, loop{src, d, inner})
+		}
+	}
+
+	// Lay out the row and remember the center column of each block.
+	// The arrows between the blocks are lengthened, until each loop
+	// is wide enough for it's path, such that it's corners stay above
+	// the vertical lines.
+	pad := make([]int, len(row)) // additional dashes of the arrow before row[n]
+	col := make(map[int]int)
+	var top strings.Builder
+	layout := func() {
+		top.Reset()
+		for n, b := range row {
+			if n > 0 {
+				top.WriteString(" --" + strings.Repeat("-", pad[n]) + "> ")
+			}
+			l := label(b)
+			col[b] = top.Len() + len(l)/2
+			top.WriteString(l)
+		}
+	}
+	layout()
+	for _, l := range loops {
+		lo, hi := pos[l.src], pos[l.dst]
+		if hi < lo {
+			lo, hi = hi, lo
+		}
+		if short := len(l.inner) + 4 - (col[row[hi]] - col[row[lo]]); short > 0 && hi > lo {
+			pad[hi] += short
+			layout()
+		}
+	}
+
+	grid := [][]byte{[]byte(top.String())}
+	put := func(x, y int, c byte) {
+		for len(grid) <= y {
+			grid = append(grid, nil)
+		}
+		for len(grid[y]) <= x {
+			grid[y] = append(grid[y], ' ')
+		}
+		grid[y][x] = c
+	}
+	vert := func(x, y int) {
+		if y < len(grid) && x < len(grid[y]) && grid[y][x] != ' ' {
+			put(x, y, '+') // crossing
+			return
+		}
+		put(x, y, '|')
+	}
+	text := func(x, y int, t string) {
+		for k := 0; k < len(t); k++ {
+			put(x+k, y, t[k])
+		}
+	}
+
+	// Each loop is drawn in a band of two lines below the row.
+	for depth, l := range loops {
+		xs, xd, y := col[l.src], col[l.dst], 2*(depth+1)
+		for yy := 1; yy < y; yy++ {
+			vert(xs, yy)
+			vert(xd, yy)
+		}
+		lo, hi := xs, xd
+		if xd < xs {
+			put(xd, 1, '^')
+			lo, hi = xd, xs
+		} else {
+			put(xd, 1, 'v')
+		}
+		if hi-lo < len(l.inner)+4 {
+			hi = lo + len(l.inner) + 4 // a loop back to the same block
+		}
+		put(lo, y, '+')
+		for x := lo + 1; x < hi; x++ {
+			put(x, y, '-')
+		}
+		text(lo+3, y, l.inner)
+		put(hi, y, '+')
+	}
+	for _, line := range grid {
+		if _, err := fmt.Fprintln(w, strings.TrimRight(string(line), " ")); err != nil {
+			return err
+		}
+	}
+
+	// List everything which could not be drawn.
+	for k, c := range conns {
+		if !drawn[k] {
+			if _, err := fmt.Fprintf(w, "%s -%d:%d-> %s\n", label(c.src), c.o, c.i, label(c.dst)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PrintConnections writes a table of all connections, in the order they
+// have been made, with the initial conditions of their destination inputs.
+// Unconnected ports are listed below as warnings.
+// Ports of the system itself are shown as the block "system".
+func (s *System) PrintConnections(w io.Writer) error {
+	block := func(k int) string { return fmt.Sprintf("%d %s", k, typeName(s.blocks[k].Block)) }
+	ics := make(map[[2]int][]string)
+	for _, ic := range s.initials {
+		key := [2]int{ic.block, ic.input}
+		ics[key] = append(ics[key], strconv.FormatFloat(ic.value, 'g', -1, 64))
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tsource\tport\tdestination\tport\tbuffer\tic")
+	for n, c := range s.connections {
+		src, dst, o, i := "system", "system", strconv.Itoa(c.o), strconv.Itoa(c.i)
+		if c.o < 0 {
+			o = strconv.Itoa(-c.o - 1)
+		} else {
+			src = block(c.src)
+		}
+		if c.i < 0 {
+			i = strconv.Itoa(-c.i - 1)
+		} else {
+			dst = block(c.dst)
+		}
+		if c.vector {
+			o, i = "v"+o, "v"+i
+		}
+		ic := ""
+		if !c.vector && c.i >= 0 {
+			ic = strings.Join(ics[[2]int{c.dst, c.i}], ",")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%s\n", n, src, o, dst, i, c.buf, ic)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for k, b := range s.blocks {
+		for _, p := range []struct {
+			kind  string
+			n     int
+			valid func(int) bool
+		}{
+			{"input", len(b.In), func(i int) bool { return b.In[i] != nil }},
+			{"output", len(b.Out), func(i int) bool { return b.Out[i] != nil }},
+			{"vector input", len(b.VIn), func(i int) bool { return b.VIn[i] != nil }},
+			{"vector output", len(b.VOut), func(i int) bool { return b.VOut[i] != nil }},
+		} {
+			for i := 0; i < p.n; i++ {
+				if !p.valid(i) {
+					if _, err := fmt.Fprintf(w, "warning: %s %d of block %s is not connected\n", p.kind, i, block(k)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package loops
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestDrawASCII draws the 1st order system.
+func TestDrawASCII(t *testing.T) {
+	var s System
+	s.Add(&Integrate{State: 1})      // 0
+	s.Add(&Recorder{NumChannels: 1}) // 1
+	s.Add(Scale(-1))                 // 2
+	s.Add(Add{})                     // 3
+	s.Add(Tee{})                     // 4
+	s.Add(Source(0))                 // 5
+	s.Add(&Stop{Time: 1})            // 6
+	s.Connect(0, 4, 0, 0)            // inte -> tee
+	s.Connect(4, 1, 0, 0)            // tee -> rec
+	s.Connect(4, 2, 1, 0)            // tee -> neg
+	s.Connect(2, 3, 0, 1)            // neg -> add
+	s.Connect(5, 6, 0, 0)            // zeros -> stop
+	s.Connect(6, 3, 0, 0)            // stop -> add
+	s.Connect(3, 0, 0, 0)            // add -> inte
+
+	var buf bytes.Buffer
+	if err := s.DrawASCII(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	t.Log("\n" + out)
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if want := "[5 Source] --> [6 Stop] --> [3 Add] --> [0 Integrate] --> [4 Tee] --> [1 Recorder]"; lines[0] != want {
+		t.Fatalf("got row\n%s\nwant\n%s", lines[0], want)
+	}
+	if len(lines) != 3 || !strings.Contains(lines[2], "[2 Scale] <") {
+		t.Fatalf("feedback path is not drawn below the row:\n%s", out)
+	}
+}
+
+// TestDrawASCIIWideLoop draws a feedback path, which is wider than the
+// blocks it connects. The arrow on the row is lengthened, such that the
+// corners of the loop are below the blocks.
+func TestDrawASCIIWideLoop(t *testing.T) {
+	var s System
+	s.Add(Source(1))      // 0
+	s.Add(Add{})          // 1
+	s.Add(Tee{})          // 2
+	s.Add(&Print{})       // 3
+	s.Add(Scale(-0.5))    // 4
+	s.Connect(0, 1, 0, 0) // src -> add
+	s.Connect(1, 2, 0, 0) // add -> tee
+	s.Connect(2, 3, 0, 0) // tee -> print
+	s.Connect(2, 4, 1, 0) // tee -> scale
+	s.Connect(4, 1, 0, 1) // scale -> add
+	var buf bytes.Buffer
+	if err := s.DrawASCII(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	t.Log("\n" + out)
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines", len(lines))
+	}
+	up, riser := strings.IndexByte(lines[1], '^'), strings.IndexByte(lines[1], '|')
+	left, right := strings.IndexByte(lines[2], '+'), strings.LastIndexByte(lines[2], '+')
+	if up != strings.Index(lines[0], "[1 Add]")+3 || riser != strings.Index(lines[0], "[2 Tee]")+3 {
+		t.Fatalf("the vertical lines at %d and %d are not below the blocks", up, riser)
+	}
+	if left != up || right != riser {
+		t.Fatalf("corners at %d and %d, vertical lines at %d and %d", left, right, up, riser)
+	}
+	if !strings.Contains(lines[2], "[4 Scale] <") {
+		t.Fatalf("the feedback path is missing: %q", lines[2])
+	}
+}
+
+// TestPrintConnections lists the connections of the ODE examples.
+func TestPrintConnections(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		s        *System
+		n, ics   int
+		warnings int
+	}{
+		{"ode1", ode1System(discard{}, &Stop{Time: 1}), 7, 1, 0},
+		{"ode2", ode2System(discard{}, &Stop{Time: 1}), 11, 2, 0},
+	} {
+		var buf bytes.Buffer
+		if err := c.s.PrintConnections(&buf); err != nil {
+			t.Fatal(err)
+		}
+		t.Log("\n" + buf.String())
+		lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+		if len(lines) != 1+c.n {
+			t.Fatalf("%s: got %d lines, want %d connections", c.name, len(lines), c.n)
+		}
+		if f := strings.Fields(lines[0]); len(f) != 7 || f[0] != "#" || f[6] != "ic" {
+			t.Fatalf("%s: header %q", c.name, lines[0])
+		}
+		ics := 0
+		for _, l := range lines[1:] {
+			if f := strings.Fields(l); len(f) == 9 {
+				ics++
+			} else if len(f) != 8 {
+				t.Fatalf("%s: line %q", c.name, l)
+			}
+		}
+		if ics != c.ics {
+			t.Fatalf("%s: %d lines with initial conditions, want %d", c.name, ics, c.ics)
+		}
+	}
+
+	// The 1st line of ode1 is inte -> tee, it has no initial condition.
+	var buf bytes.Buffer
+	ode1System(discard{}, &Stop{Time: 1}).PrintConnections(&buf)
+	if f := strings.Fields(strings.Split(buf.String(), "\n")[1]); strings.Join(f, " ") != "0 0 Integrate 0 4 Tee 0 0" {
+		t.Fatalf("got %q", f)
+	}
+
+	// Unconnected ports are reported.
+	var s System
+	s.Add(Add{})
+	s.Add(Tee{})
+	s.Connect(0, 1, 0, 0)
+	buf.Reset()
+	if err := s.PrintConnections(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "warning:"); n != 4 || !strings.Contains(buf.String(), "warning: input 1 of block 0 Add is not connected") {
+		t.Fatalf("got\n%s", buf.String())
+	}
+}
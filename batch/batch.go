@@ -0,0 +1,79 @@
+// Package batch runs parameter studies on copies of a loops.System
+package batch
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/ktye/loops"
+)
+
+// Result is the outcome of a single run.
+type Result struct {
+	Value  float64              // Parameter value.
+	Scopes map[int]*loops.Scope // Scope blocks of the run by block index.
+	Err    error
+}
+
+// Run simulates a copy of base for each of the values.
+// param is called with the copy and the value before it is started,
+// it changes the parameter of a block, which it gets by System.Block.
+// The copies are made by System.Clone and the base system is not changed.
+// Each run must end by itself, e.g. with a Stop block.
+//
+// Up to workers simulations run in parallel, GOMAXPROCS if workers is 0.
+// The results are in the order of values. Errors of a single run are
+// reported in it's Result, the error return is for errors before any
+// run is started.
+func Run(base *loops.System, param func(*loops.System, float64), values []float64, workers int) ([]Result, error) {
+	if workers < 0 {
+		return nil, fmt.Errorf("batch: workers must not be negative: %d", workers)
+	} else if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	systems := make([]*loops.System, len(values))
+	for i := range values {
+		s, err := base.Clone()
+		if err != nil {
+			return nil, fmt.Errorf("batch: %v", err)
+		}
+		systems[i] = s
+	}
+
+	results := make([]Result, len(values))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(values)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = run(systems[i], param, values[i])
+			}
+		}()
+	}
+	for i := range values {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results, nil
+}
+
+// run changes the parameter of s to v and starts it.
+func run(s *loops.System, param func(*loops.System, float64), v float64) Result {
+	r := Result{Value: v, Scopes: make(map[int]*loops.Scope)}
+	param(s, v)
+	if err := s.Start(context.Background()); err != nil {
+		r.Err = fmt.Errorf("batch: value %v: %v", v, err)
+		return r
+	}
+	for k := 0; k < s.NumBlocks(); k++ {
+		if scope, ok := s.Block(k).(*loops.Scope); ok {
+			r.Scopes[k] = scope
+		}
+	}
+	return r
+}
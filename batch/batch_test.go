@@ -0,0 +1,95 @@
+package batch
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ktye/loops"
+)
+
+// oscillator returns the system x” + 2ζωx' + ω²x = 0 with x(0) = 1 and ω = 2π.
+// The damping block 4 is a polynomial with the coefficient -2ζω of the
+// linear term, which is set for each ζ. Block 8 is the scope of x.
+func oscillator() *loops.System {
+	const w = 2 * math.Pi
+	var s loops.System
+	s.Add(&loops.Integrate{})                        // 0 v
+	s.Add(&loops.Integrate{State: 1})                // 1 x
+	s.Add(loops.Tee{})                               // 2
+	s.Add(loops.Tee{})                               // 3
+	s.Add(loops.Polynomial{Coeffs: []float64{0, 0}}) // 4 -2ζω
+	s.Add(loops.Scale(-w * w))                       // 5
+	s.Add(loops.Add{})                               // 6
+	s.Add(&loops.Stop{Time: 5})                      // 7
+	s.Add(&loops.Scope{NumChannels: 1})              // 8
+	s.Connect(6, 7, 0, 0)                            // add -> stop
+	s.Connect(7, 0, 0, 0)                            // stop -> v
+	s.Connect(0, 2, 0, 0)                            // v -> tee
+	s.Connect(2, 1, 0, 0)                            // tee -> x
+	s.Connect(2, 4, 1, 0)                            // tee -> damping
+	s.Connect(4, 6, 0, 0)                            // damping -> add
+	s.Connect(1, 3, 0, 0)                            // x -> tee
+	s.Connect(3, 5, 0, 0)                            // tee -> spring
+	s.Connect(5, 6, 0, 1)                            // spring -> add
+	s.Connect(3, 8, 1, 0)                            // tee -> scope
+	s.AddIC(0, 6, 0)                                 // -2ζωv0
+	s.AddIC(-w*w, 6, 1)                              // -ω²x0
+	return &s
+}
+
+// frequency returns the mean frequency of the rising zero crossings of x.
+func frequency(x []float64, dt float64) float64 {
+	var first, last float64
+	n := -1
+	for k := 1; k < len(x); k++ {
+		if x[k-1] < 0 && x[k] >= 0 {
+			t := dt * (float64(k-1) + x[k-1]/(x[k-1]-x[k]))
+			if n < 0 {
+				first = t
+			}
+			last = t
+			n++
+		}
+	}
+	return float64(n) / (last - first)
+}
+
+// TestRun sweeps the damping ratio. The frequency of the damped
+// oscillation is ω√(1-ζ²), which decreases with ζ.
+func TestRun(t *testing.T) {
+	base := oscillator()
+	zeta := []float64{0, 0.2, 0.4, 0.6}
+	results, err := Run(base, func(s *loops.System, z float64) {
+		// The copy has it's own coefficients.
+		s.Block(4).(loops.Polynomial).Coeffs[1] = -2 * z * 2 * math.Pi
+	}, zeta, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(zeta) {
+		t.Fatalf("got %d results", len(results))
+	}
+	last := math.Inf(1)
+	for i, r := range results {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if r.Value != zeta[i] || len(r.Scopes) != 1 || r.Scopes[8] == nil {
+			t.Fatalf("result %d: value %v, scopes %v", i, r.Value, r.Scopes)
+		}
+		f := frequency(r.Scopes[8].Data[0], loops.DefaultDT)
+		want := math.Sqrt(1 - zeta[i]*zeta[i])
+		t.Logf("ζ=%v: f=%.4f Hz, want %.4f", zeta[i], f, want)
+		if f >= last || math.Abs(f-want) > 0.02 {
+			t.Errorf("ζ=%v: frequency %v, want %v, decreasing from %v", zeta[i], f, want, last)
+		}
+		last = f
+	}
+	if base.Block(4).(loops.Polynomial).Coeffs[1] != 0 {
+		t.Fatal("the base system has been changed")
+	}
+
+	if _, err := Run(base, func(*loops.System, float64) {}, zeta, -1); err == nil {
+		t.Fatal("expected an error for negative workers")
+	}
+}
@@ -1,7 +1,18 @@
 package loops
 
 import (
+	"encoding/csv"
 	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/bits"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 // In this file some standard blocks are defined.
@@ -18,8 +29,10 @@ import (
 // Scale multiplies it's input with a constant factor.
 type Scale float64
 
-func (b Scale) Inputs() int  { return 1 }
-func (b Scale) Outputs() int { return 1 }
+func (b Scale) InputNames() []string  { return []string{"in"} }
+func (b Scale) OutputNames() []string { return []string{"out"} }
+func (b Scale) Inputs() int           { return 1 }
+func (b Scale) Outputs() int          { return 1 }
 func (b Scale) Step(in, out []float64) bool {
 	out[0] = float64(b) * in[0]
 	return true
@@ -28,82 +41,2353 @@ func (b Scale) Step(in, out []float64) bool {
 // Add adds too inputs and sends the result to the output channel.
 type Add struct{}
 
-func (b Add) Inputs() int  { return 2 }
-func (b Add) Outputs() int { return 1 }
+func (b Add) InputNames() []string  { return []string{"in0", "in1"} }
+func (b Add) OutputNames() []string { return []string{"out"} }
+func (b Add) Inputs() int           { return 2 }
+func (b Add) Outputs() int          { return 1 }
 func (b Add) Step(in, out []float64) bool {
 	out[0] = in[0] + in[1]
 	return true
 }
 
+// Subtract subtracts the second input from the first.
+type Subtract struct{}
+
+func (b Subtract) InputNames() []string  { return []string{"in0", "in1"} }
+func (b Subtract) OutputNames() []string { return []string{"out"} }
+func (b Subtract) Inputs() int           { return 2 }
+func (b Subtract) Outputs() int          { return 1 }
+func (b Subtract) Step(in, out []float64) bool {
+	out[0] = in[0] - in[1]
+	return true
+}
+
+// Negate changes the sign of it's input.
+type Negate struct{}
+
+func (b Negate) InputNames() []string  { return []string{"in"} }
+func (b Negate) OutputNames() []string { return []string{"out"} }
+func (b Negate) Inputs() int           { return 1 }
+func (b Negate) Outputs() int          { return 1 }
+func (b Negate) Step(in, out []float64) bool {
+	out[0] = -in[0]
+	return true
+}
+
+// Abs outputs the absolute value of it's input.
+type Abs struct{}
+
+func (b Abs) InputNames() []string  { return []string{"in"} }
+func (b Abs) OutputNames() []string { return []string{"out"} }
+func (b Abs) Inputs() int           { return 1 }
+func (b Abs) Outputs() int          { return 1 }
+func (b Abs) Step(in, out []float64) bool {
+	out[0] = math.Abs(in[0])
+	return true
+}
+
+// Sign outputs -1, 0 or 1 for a negative, zero or positive input.
+type Sign struct{}
+
+func (b Sign) InputNames() []string  { return []string{"in"} }
+func (b Sign) OutputNames() []string { return []string{"out"} }
+func (b Sign) Inputs() int           { return 1 }
+func (b Sign) Outputs() int          { return 1 }
+func (b Sign) Step(in, out []float64) bool {
+	switch {
+	case in[0] > 0:
+		out[0] = 1
+	case in[0] < 0:
+		out[0] = -1
+	default:
+		out[0] = in[0] // 0, -0 or NaN
+	}
+	return true
+}
+
+// Sqrt outputs the square root of it's input.
+// A negative input ends the simulation.
+type Sqrt struct{}
+
+func (b Sqrt) InputNames() []string  { return []string{"in"} }
+func (b Sqrt) OutputNames() []string { return []string{"out"} }
+func (b Sqrt) Inputs() int           { return 1 }
+func (b Sqrt) Outputs() int          { return 1 }
+func (b Sqrt) Step(in, out []float64) bool {
+	if in[0] < 0 {
+		log.Printf("sqrt: negative input: %v", in[0])
+		return false
+	}
+	out[0] = math.Sqrt(in[0])
+	return true
+}
+
+// Square outputs the square of it's input.
+type Square struct{}
+
+func (b Square) InputNames() []string  { return []string{"in"} }
+func (b Square) OutputNames() []string { return []string{"out"} }
+func (b Square) Inputs() int           { return 1 }
+func (b Square) Outputs() int          { return 1 }
+func (b Square) Step(in, out []float64) bool {
+	out[0] = in[0] * in[0]
+	return true
+}
+
+// MathFunc applies Fn to it's input.
+// SinBlock, CosBlock, ExpBlock, LogBlock, PowBlock, TanhBlock, SigmoidBlock
+// and HeavisideBlock return MathFunc blocks for common functions.
+type MathFunc struct {
+	Fn func(float64) float64 `json:"-"`
+}
+
+// SinBlock returns a MathFunc, which computes math.Sin.
+func SinBlock() MathFunc { return MathFunc{Fn: math.Sin} }
+
+// CosBlock returns a MathFunc, which computes math.Cos.
+func CosBlock() MathFunc { return MathFunc{Fn: math.Cos} }
+
+// ExpBlock returns a MathFunc, which computes math.Exp.
+func ExpBlock() MathFunc { return MathFunc{Fn: math.Exp} }
+
+// LogBlock returns a MathFunc, which computes the natural logarithm math.Log.
+func LogBlock() MathFunc { return MathFunc{Fn: math.Log} }
+
+// PowBlock returns a MathFunc, which raises it's input to the power exp.
+func PowBlock(exp float64) MathFunc {
+	return MathFunc{Fn: func(x float64) float64 { return math.Pow(x, exp) }}
+}
+
+// TanhBlock returns a MathFunc, which computes math.Tanh.
+func TanhBlock() MathFunc { return MathFunc{Fn: math.Tanh} }
+
+// SigmoidBlock returns a MathFunc, which computes the logistic function 1/(1+exp(-steepness*x)).
+func SigmoidBlock(steepness float64) MathFunc {
+	return MathFunc{Fn: func(x float64) float64 { return 1 / (1 + math.Exp(-steepness*x)) }}
+}
+
+// HeavisideBlock returns a MathFunc, which outputs 1 if it's input is at or above threshold, and 0 otherwise.
+func HeavisideBlock(threshold float64) MathFunc {
+	return MathFunc{Fn: func(x float64) float64 {
+		if x >= threshold {
+			return 1
+		}
+		return 0
+	}}
+}
+
+func (b MathFunc) Validate() error {
+	if b.Fn == nil {
+		return fmt.Errorf("math func: Fn is nil")
+	}
+	return nil
+}
+func (b MathFunc) Clone() Block          { return b }
+func (b MathFunc) InputNames() []string  { return []string{"in"} }
+func (b MathFunc) OutputNames() []string { return []string{"out"} }
+func (b MathFunc) Inputs() int           { return 1 }
+func (b MathFunc) Outputs() int          { return 1 }
+func (b MathFunc) Step(in, out []float64) bool {
+	out[0] = b.Fn(in[0])
+	return true
+}
+
+// Polynomial evaluates the polynomial with the coefficients Coeffs
+// at it's input, starting with the constant term.
+// Without coefficients the output is 0.
+type Polynomial struct {
+	Coeffs []float64
+}
+
+func (b Polynomial) InputNames() []string  { return []string{"in"} }
+func (b Polynomial) OutputNames() []string { return []string{"out"} }
+func (b Polynomial) Inputs() int           { return 1 }
+func (b Polynomial) Outputs() int          { return 1 }
+func (b Polynomial) Step(in, out []float64) bool {
+	out[0] = horner(b.Coeffs, in[0])
+	return true
+}
+
+// Polynomial2D evaluates the bivariate polynomial sum(Coeffs[i][j] x^i y^j)
+// at the inputs x and y. The rows may have different lengths.
+type Polynomial2D struct {
+	Coeffs [][]float64
+}
+
+func (b Polynomial2D) InputNames() []string  { return []string{"x", "y"} }
+func (b Polynomial2D) OutputNames() []string { return []string{"out"} }
+func (b Polynomial2D) Inputs() int           { return 2 }
+func (b Polynomial2D) Outputs() int          { return 1 }
+func (b Polynomial2D) Step(in, out []float64) bool {
+	// Horner's method in x, with coefficients which are polynomials in y.
+	x, y, r := in[0], in[1], 0.0
+	for i := len(b.Coeffs) - 1; i >= 0; i-- {
+		r = r*x + horner(b.Coeffs[i], y)
+	}
+	out[0] = r
+	return true
+}
+
+// horner evaluates the polynomial c[0] + c[1] x + c[2] x^2 + ...
+func horner(c []float64, x float64) float64 {
+	r := 0.0
+	for i := len(c) - 1; i >= 0; i-- {
+		r = r*x + c[i]
+	}
+	return r
+}
+
+// Multiply multiplies two inputs.
+type Multiply struct{}
+
+func (b Multiply) InputNames() []string  { return []string{"in0", "in1"} }
+func (b Multiply) OutputNames() []string { return []string{"out"} }
+func (b Multiply) Inputs() int           { return 2 }
+func (b Multiply) Outputs() int          { return 1 }
+func (b Multiply) Step(in, out []float64) bool {
+	out[0] = in[0] * in[1]
+	return true
+}
+
+// Divide divides the first input by the second.
+// A division by zero stops the simulation.
+type Divide struct{}
+
+func (b Divide) InputNames() []string  { return []string{"in0", "in1"} }
+func (b Divide) OutputNames() []string { return []string{"out"} }
+func (b Divide) Inputs() int           { return 2 }
+func (b Divide) Outputs() int          { return 1 }
+func (b Divide) Step(in, out []float64) bool {
+	if in[1] == 0 {
+		log.Printf("divide: division by zero: %v/0", in[0])
+		return false
+	}
+	out[0] = in[0] / in[1]
+	return true
+}
+
+// AddN adds N inputs.
+type AddN struct {
+	N int // Number of inputs.
+}
+
+func (b AddN) Validate() error {
+	if b.N < 1 {
+		return fmt.Errorf("addn: N must be positive: %d", b.N)
+	}
+	return nil
+}
+func (b AddN) InputNames() []string  { return PortNames("in", b.N) }
+func (b AddN) OutputNames() []string { return []string{"out"} }
+func (b AddN) Inputs() int           { return b.N }
+func (b AddN) Outputs() int          { return 1 }
+func (b AddN) Step(in, out []float64) bool {
+	if len(in) != b.N {
+		log.Printf("addn: got %d inputs, want %d", len(in), b.N)
+		return false
+	}
+	out[0] = 0
+	for _, v := range in {
+		out[0] += v
+	}
+	return true
+}
+
+// MulN multiplies N inputs.
+type MulN struct {
+	N int // Number of inputs.
+}
+
+func (b MulN) Validate() error {
+	if b.N < 1 {
+		return fmt.Errorf("muln: N must be positive: %d", b.N)
+	}
+	return nil
+}
+func (b MulN) InputNames() []string  { return PortNames("in", b.N) }
+func (b MulN) OutputNames() []string { return []string{"out"} }
+func (b MulN) Inputs() int           { return b.N }
+func (b MulN) Outputs() int          { return 1 }
+func (b MulN) Step(in, out []float64) bool {
+	if len(in) != b.N {
+		log.Printf("muln: got %d inputs, want %d", len(in), b.N)
+		return false
+	}
+	out[0] = 1
+	for _, v := range in {
+		out[0] *= v
+	}
+	return true
+}
+
+// Saturation clamps it's input to the range [Min, Max].
+type Saturation struct {
+	Min, Max float64
+}
+
+func (b Saturation) Validate() error {
+	if b.Min >= b.Max {
+		return fmt.Errorf("saturation: min %v must be smaller than max %v", b.Min, b.Max)
+	}
+	return nil
+}
+func (b Saturation) InputNames() []string  { return []string{"in"} }
+func (b Saturation) OutputNames() []string { return []string{"out"} }
+func (b Saturation) Inputs() int           { return 1 }
+func (b Saturation) Outputs() int          { return 1 }
+func (b Saturation) Step(in, out []float64) bool {
+	out[0] = math.Max(b.Min, math.Min(b.Max, in[0]))
+	return true
+}
+
+// Deadband outputs 0 while the input is within [-Width/2, Width/2].
+// Outside, the input is shifted by Width/2 towards zero,
+// so that the output is continuous.
+type Deadband struct {
+	Width float64
+}
+
+func (b Deadband) Validate() error {
+	if b.Width < 0 {
+		return fmt.Errorf("deadband: negative width %v", b.Width)
+	}
+	return nil
+}
+func (b Deadband) InputNames() []string  { return []string{"in"} }
+func (b Deadband) OutputNames() []string { return []string{"out"} }
+func (b Deadband) Inputs() int           { return 1 }
+func (b Deadband) Outputs() int          { return 1 }
+func (b Deadband) Step(in, out []float64) bool {
+	h := b.Width / 2
+	switch x := in[0]; {
+	case x > h:
+		out[0] = x - h
+	case x < -h:
+		out[0] = x + h
+	default:
+		out[0] = 0
+	}
+	return true
+}
+
+// Quantizer rounds it's input to the nearest multiple of Resolution.
+// Halfway values are rounded away from zero.
+type Quantizer struct {
+	Resolution float64
+}
+
+func (b Quantizer) Validate() error       { return validResolution("quantizer", b.Resolution) }
+func (b Quantizer) InputNames() []string  { return []string{"in"} }
+func (b Quantizer) OutputNames() []string { return []string{"out"} }
+func (b Quantizer) Inputs() int           { return 1 }
+func (b Quantizer) Outputs() int          { return 1 }
+func (b Quantizer) Step(in, out []float64) bool {
+	out[0] = quantize(in[0], b.Resolution, math.Round)
+	return true
+}
+
+// Truncate rounds it's input down to the next lower multiple of Resolution.
+type Truncate struct {
+	Resolution float64
+}
+
+func (b Truncate) Validate() error       { return validResolution("truncate", b.Resolution) }
+func (b Truncate) InputNames() []string  { return []string{"in"} }
+func (b Truncate) OutputNames() []string { return []string{"out"} }
+func (b Truncate) Inputs() int           { return 1 }
+func (b Truncate) Outputs() int          { return 1 }
+func (b Truncate) Step(in, out []float64) bool {
+	out[0] = quantize(in[0], b.Resolution, math.Floor)
+	return true
+}
+
+// Ceiling rounds it's input up to the next higher multiple of Resolution.
+type Ceiling struct {
+	Resolution float64
+}
+
+func (b Ceiling) Validate() error       { return validResolution("ceiling", b.Resolution) }
+func (b Ceiling) InputNames() []string  { return []string{"in"} }
+func (b Ceiling) OutputNames() []string { return []string{"out"} }
+func (b Ceiling) Inputs() int           { return 1 }
+func (b Ceiling) Outputs() int          { return 1 }
+func (b Ceiling) Step(in, out []float64) bool {
+	out[0] = quantize(in[0], b.Resolution, math.Ceil)
+	return true
+}
+
+// validResolution returns an error for the block name, if r is not positive.
+func validResolution(name string, r float64) error {
+	if r <= 0 {
+		return fmt.Errorf("%s: resolution must be positive: %v", name, r)
+	}
+	return nil
+}
+
+// quantize rounds x to a multiple of r with the rounding function f.
+// If 1/r is an integer m, such as for r = 0.1, x is scaled by m and
+// the result divided by m, which is exact for the integer multiple.
+// Values within rounding error of a multiple are treated as the multiple,
+// so that 0.3 is not truncated to 0.2.
+func quantize(x, r float64, f func(float64) float64) float64 {
+	m := math.Round(1 / r)
+	exact := m >= 1 && math.Abs(1/r-m) < 1e-9*m
+	q := x / r
+	if exact {
+		q = x * m
+	}
+	if n := math.Round(q); math.Abs(q-n) < 1e-9*math.Max(1, math.Abs(q)) {
+		q = n
+	}
+	q = f(q)
+	if exact {
+		return q / m
+	}
+	return q * r
+}
+
+// Lookup1D interpolates piecewise-linear in a table of breakpoints X
+// and values Y. X must be strictly increasing.
+// Inputs outside [X[0], X[len-1]] are clamped to the boundary values,
+// or linearly extrapolated from the first or last segment if Extrapolate is set.
+type Lookup1D struct {
+	X, Y        []float64
+	Extrapolate bool
+}
+
+func (b Lookup1D) Validate() error {
+	if len(b.X) != len(b.Y) {
+		return fmt.Errorf("lookup1d: x has %d, y %d values", len(b.X), len(b.Y))
+	}
+	if len(b.X) < 2 {
+		return fmt.Errorf("lookup1d: needs at least 2 breakpoints")
+	}
+	for i := 1; i < len(b.X); i++ {
+		if b.X[i] <= b.X[i-1] {
+			return fmt.Errorf("lookup1d: x is not strictly increasing at index %d", i)
+		}
+	}
+	return nil
+}
+func (b Lookup1D) InputNames() []string  { return []string{"in"} }
+func (b Lookup1D) OutputNames() []string { return []string{"out"} }
+func (b Lookup1D) Inputs() int           { return 1 }
+func (b Lookup1D) Outputs() int          { return 1 }
+func (b Lookup1D) Step(in, out []float64) bool {
+	x, n := in[0], len(b.X)
+	if !b.Extrapolate {
+		if x <= b.X[0] {
+			out[0] = b.Y[0]
+			return true
+		} else if x >= b.X[n-1] {
+			out[0] = b.Y[n-1]
+			return true
+		}
+	}
+	// i is the upper end of the interval, limited to the outer segments.
+	i := sort.SearchFloat64s(b.X, x)
+	i = max(1, min(n-1, i))
+	x0, x1, y0, y1 := b.X[i-1], b.X[i], b.Y[i-1], b.Y[i]
+	out[0] = y0 + (x-x0)*(y1-y0)/(x1-x0)
+	return true
+}
+
+// Lookup2D interpolates bilinear in a table with the values Z[i][j]
+// at the breakpoints X[i] and Y[j]. X and Y must be strictly increasing.
+// The inputs are x and y, which are clamped to the table range.
+type Lookup2D struct {
+	X, Y []float64
+	Z    [][]float64
+}
+
+func (b Lookup2D) Validate() error {
+	for _, v := range []struct {
+		name string
+		x    []float64
+	}{{"x", b.X}, {"y", b.Y}} {
+		if len(v.x) < 2 {
+			return fmt.Errorf("lookup2d: %s needs at least 2 breakpoints", v.name)
+		}
+		for i := 1; i < len(v.x); i++ {
+			if v.x[i] <= v.x[i-1] {
+				return fmt.Errorf("lookup2d: %s is not strictly increasing at index %d", v.name, i)
+			}
+		}
+	}
+	if len(b.Z) != len(b.X) {
+		return fmt.Errorf("lookup2d: z has %d rows, want %d", len(b.Z), len(b.X))
+	}
+	for i, row := range b.Z {
+		if len(row) != len(b.Y) {
+			return fmt.Errorf("lookup2d: z row %d has %d values, want %d", i, len(row), len(b.Y))
+		}
+	}
+	return nil
+}
+func (b Lookup2D) InputNames() []string  { return []string{"x", "y"} }
+func (b Lookup2D) OutputNames() []string { return []string{"out"} }
+func (b Lookup2D) Inputs() int           { return 2 }
+func (b Lookup2D) Outputs() int          { return 1 }
+func (b Lookup2D) Step(in, out []float64) bool {
+	i, u := interval(b.X, in[0])
+	j, v := interval(b.Y, in[1])
+	z := b.Z
+	out[0] = (1-u)*(1-v)*z[i][j] + u*(1-v)*z[i+1][j] + (1-u)*v*z[i][j+1] + u*v*z[i+1][j+1]
+	return true
+}
+
+// interval returns the index i of the interval [x[i], x[i+1]] which contains v
+// and the relative position of v within it, clamped to [0, 1].
+func interval(x []float64, v float64) (int, float64) {
+	i := sort.SearchFloat64s(x, v) - 1
+	i = max(0, min(len(x)-2, i))
+	f := (v - x[i]) / (x[i+1] - x[i])
+	return i, math.Max(0, math.Min(1, f))
+}
+
+// GainScheduler multiplies it's first input with a gain, which depends on
+// the scheduling variable at the second input.
+// The gain is interpolated linearly between the breakpoints Schedules with
+// the values Gains and held constant outside.
+type GainScheduler struct {
+	Schedules []float64 // Strictly increasing breakpoints.
+	Gains     []float64 // Gain at each breakpoint.
+}
+
+func (b GainScheduler) Validate() error {
+	return validateSchedule("gainscheduler", b.Schedules, len(b.Gains))
+}
+func (b GainScheduler) InputNames() []string  { return []string{"in", "schedule"} }
+func (b GainScheduler) OutputNames() []string { return []string{"out"} }
+func (b GainScheduler) Inputs() int           { return 2 }
+func (b GainScheduler) Outputs() int          { return 1 }
+func (b GainScheduler) Step(in, out []float64) bool {
+	i, f := interval(b.Schedules, in[1])
+	out[0] = ((1-f)*b.Gains[i] + f*b.Gains[i+1]) * in[0]
+	return true
+}
+
+// PIDGains is a parameter set of a PID controller.
+type PIDGains struct {
+	P, I, D float64
+}
+
+// GainSchedulerPID is a PID controller with scheduled parameters.
+// It's inputs are the control error and the scheduling variable.
+// Each parameter is interpolated like the gain of GainScheduler.
+// The output is P*e + I*∫e dt + D*de/dt, where the derivative
+// is a backward difference, which is 0 on the first step.
+type GainSchedulerPID struct {
+	Schedules []float64  // Strictly increasing breakpoints.
+	Gains     []PIDGains // Parameter set at each breakpoint.
+	integral  float64
+	last      float64
+	started   bool
+	dt        float64
+}
+
+func (b *GainSchedulerPID) Validate() error {
+	return validateSchedule("gainschedulerpid", b.Schedules, len(b.Gains))
+}
+func (b *GainSchedulerPID) SetDT(dt float64)      { b.dt = dt }
+func (b *GainSchedulerPID) Reset()                { b.integral, b.last, b.started = 0, 0, false }
+func (b *GainSchedulerPID) InputNames() []string  { return []string{"e", "schedule"} }
+func (b *GainSchedulerPID) OutputNames() []string { return []string{"u"} }
+func (b *GainSchedulerPID) Inputs() int           { return 2 }
+func (b *GainSchedulerPID) Outputs() int          { return 1 }
+func (b *GainSchedulerPID) Step(in, out []float64) bool {
+	dt, e := timeStep(b.dt), in[0]
+	i, f := interval(b.Schedules, in[1])
+	g0, g1 := b.Gains[i], b.Gains[i+1]
+	p, ki, d := (1-f)*g0.P+f*g1.P, (1-f)*g0.I+f*g1.I, (1-f)*g0.D+f*g1.D
+	b.integral += e * dt
+	var de float64
+	if b.started {
+		de = (e - b.last) / dt
+	}
+	b.last, b.started = e, true
+	out[0] = p*e + ki*b.integral + d*de
+	return true
+}
+
+// validateSchedule checks that there are at least 2 strictly increasing
+// breakpoints and n values.
+func validateSchedule(name string, x []float64, n int) error {
+	if len(x) != n {
+		return fmt.Errorf("%s: %d schedules, %d gains", name, len(x), n)
+	}
+	if len(x) < 2 {
+		return fmt.Errorf("%s: needs at least 2 breakpoints", name)
+	}
+	for i := 1; i < len(x); i++ {
+		if x[i] <= x[i-1] {
+			return fmt.Errorf("%s: schedules are not strictly increasing at index %d", name, i)
+		}
+	}
+	return nil
+}
+
+// RateLimiter limits the rate of change of it's input.
+// Rising and Falling are the maximum rates per second, both positive.
+// The first input passes unchanged.
+type RateLimiter struct {
+	Rising, Falling float64
+	last            float64
+	started         bool
+	dt              float64
+}
+
+func (b *RateLimiter) Validate() error {
+	if b.Rising <= 0 || b.Falling <= 0 {
+		return fmt.Errorf("ratelimiter: rates must be positive: rising %v, falling %v", b.Rising, b.Falling)
+	}
+	return nil
+}
+func (b *RateLimiter) SetDT(dt float64)      { b.dt = dt }
+func (b *RateLimiter) Reset()                { b.last, b.started = 0, false }
+func (b *RateLimiter) InputNames() []string  { return []string{"in"} }
+func (b *RateLimiter) OutputNames() []string { return []string{"out"} }
+func (b *RateLimiter) Inputs() int           { return 1 }
+func (b *RateLimiter) Outputs() int          { return 1 }
+func (b *RateLimiter) Step(in, out []float64) bool {
+	if !b.started {
+		b.last, b.started = in[0], true
+	} else {
+		dt := timeStep(b.dt)
+		d := math.Max(-b.Falling*dt, math.Min(b.Rising*dt, in[0]-b.last))
+		b.last += d
+	}
+	out[0] = b.last
+	return true
+}
+
+// Switch passes it's first input if the control input is at least
+// Threshold, and the second input otherwise.
+type Switch struct {
+	Threshold float64
+}
+
+func (b Switch) InputNames() []string  { return []string{"a", "b", "control"} }
+func (b Switch) OutputNames() []string { return []string{"out"} }
+func (b Switch) Inputs() int           { return 3 }
+func (b Switch) Outputs() int          { return 1 }
+func (b Switch) Step(in, out []float64) bool {
+	if in[2] >= b.Threshold {
+		out[0] = in[0]
+	} else {
+		out[0] = in[1]
+	}
+	return true
+}
+
+// MultiSwitch passes one of N data inputs, which is selected by the
+// last input. The selector is rounded to the nearest index and limited
+// to [0, N-1].
+type MultiSwitch struct {
+	N int // Number of data inputs.
+}
+
+func (b MultiSwitch) Validate() error {
+	if b.N < 1 {
+		return fmt.Errorf("multiswitch: N must be positive: %d", b.N)
+	}
+	return nil
+}
+func (b MultiSwitch) InputNames() []string  { return append(PortNames("in", b.N), "select") }
+func (b MultiSwitch) OutputNames() []string { return []string{"out"} }
+func (b MultiSwitch) Inputs() int           { return b.N + 1 }
+func (b MultiSwitch) Outputs() int          { return 1 }
+func (b MultiSwitch) Step(in, out []float64) bool {
+	i := int(math.Round(in[b.N]))
+	out[0] = in[max(0, min(b.N-1, i))]
+	return true
+}
+
+// Relay switches it's output between OnValue and OffValue with hysteresis.
+// It switches on, when the input reaches OnThreshold and switches off,
+// when it falls to OffThreshold. It starts in the off state.
+type Relay struct {
+	OnThreshold, OffThreshold float64
+	OnValue, OffValue         float64
+	on                        bool
+}
+
+func (b *Relay) Validate() error {
+	if b.OnThreshold <= b.OffThreshold {
+		return fmt.Errorf("relay: on threshold %v must be larger than off threshold %v", b.OnThreshold, b.OffThreshold)
+	}
+	return nil
+}
+func (b *Relay) Reset()                { b.on = false }
+func (b *Relay) InputNames() []string  { return []string{"in"} }
+func (b *Relay) OutputNames() []string { return []string{"out"} }
+func (b *Relay) Inputs() int           { return 1 }
+func (b *Relay) Outputs() int          { return 1 }
+func (b *Relay) Step(in, out []float64) bool {
+	if !b.on && in[0] >= b.OnThreshold {
+		b.on = true
+	} else if b.on && in[0] <= b.OffThreshold {
+		b.on = false
+	}
+	if b.on {
+		out[0] = b.OnValue
+	} else {
+		out[0] = b.OffValue
+	}
+	return true
+}
+
+// Hysteresis models backlash or mechanical play with a dead zone of Width.
+// While the input increases, the output follows in-Width/2 and while it
+// decreases, the output follows in+Width/2. When the direction changes,
+// the output is held until the input has crossed the dead zone.
+// The output starts at 0.
+type Hysteresis struct {
+	Width float64
+	out   float64
+}
+
+func (b *Hysteresis) Validate() error {
+	if !(b.Width >= 0) || math.IsInf(b.Width, 1) {
+		return fmt.Errorf("hysteresis: width %v must be finite and not negative", b.Width)
+	}
+	return nil
+}
+func (b *Hysteresis) Reset()                { b.out = 0 }
+func (b *Hysteresis) InputNames() []string  { return []string{"in"} }
+func (b *Hysteresis) OutputNames() []string { return []string{"out"} }
+func (b *Hysteresis) Inputs() int           { return 1 }
+func (b *Hysteresis) Outputs() int          { return 1 }
+func (b *Hysteresis) Step(in, out []float64) bool {
+	h := b.Width / 2
+	if lo := in[0] - h; lo > b.out {
+		b.out = lo // moving up
+	} else if hi := in[0] + h; hi < b.out {
+		b.out = hi // moving down
+	}
+	out[0] = b.out
+	return true
+}
+
 // Integrate does a simple time integration.
 // The block is used to solve differential equations.
 type Integrate struct {
 	State float64 // This can be set as the initial state.
+	dt    float64
+}
+
+func (b *Integrate) SetDT(dt float64)      { b.dt = dt }
+func (b *Integrate) IsDelay() bool         { return true }
+func (b *Integrate) InputNames() []string  { return []string{"in"} }
+func (b *Integrate) OutputNames() []string { return []string{"out"} }
+func (b *Integrate) Inputs() int           { return 1 }
+func (b *Integrate) Outputs() int          { return 1 }
+func (b *Integrate) Step(in, out []float64) bool {
+	b.State += in[0] * timeStep(b.dt)
+	out[0] = b.State
+	return true
+}
+
+// Derivative differentiates it's input with a backward difference.
+// If FilterCoeff is within (0, 1], the derivative is smoothed with a first
+// order low pass: y = FilterCoeff*dx/dt + (1-FilterCoeff)*y[n-1].
+// The output of the first step is 0.
+type Derivative struct {
+	FilterCoeff    float64 // 0 is no filter.
+	prev, filtered float64
+	started        bool
+	dt             float64
+}
+
+func (b *Derivative) Validate() error {
+	if b.FilterCoeff < 0 || b.FilterCoeff > 1 {
+		return fmt.Errorf("derivative: filter coefficient %v is not within [0, 1]", b.FilterCoeff)
+	}
+	return nil
+}
+func (b *Derivative) SetDT(dt float64)      { b.dt = dt }
+func (b *Derivative) Reset()                { b.prev, b.filtered, b.started = 0, 0, false }
+func (b *Derivative) InputNames() []string  { return []string{"in"} }
+func (b *Derivative) OutputNames() []string { return []string{"out"} }
+func (b *Derivative) Inputs() int           { return 1 }
+func (b *Derivative) Outputs() int          { return 1 }
+func (b *Derivative) Step(in, out []float64) bool {
+	d := 0.0
+	if b.started {
+		d = (in[0] - b.prev) / timeStep(b.dt)
+	}
+	if c := b.FilterCoeff; c > 0 && b.started {
+		d = c*d + (1-c)*b.filtered
+	}
+	b.prev, b.filtered, b.started = in[0], d, true
+	out[0] = d
+	return true
+}
+
+// RK4 solves the differential equation x' = F(x, u) for it's input u
+// with the classical 4th order Runge-Kutta method.
+//
+// It is not a replacement for Integrate, which integrates the derivative
+// computed by the blocks of a loop. The stages of Runge-Kutta need the
+// derivative at intermediate states within one time step, but a loop
+// computes it only once per step from the state which Integrate sent.
+// Like AdaptiveIntegrate, RK4 computes the derivative with it's model
+// function F instead, so the loop around it only provides the input u.
+// The input u is held constant over the step.
+// The output is the state at the end of the time step.
+type RK4 struct {
+	F     func(x, u float64) float64 `json:"-"`
+	State float64                    // This can be set as the initial state.
+	dt    float64
+}
+
+func (b *RK4) Validate() error {
+	if b.F == nil {
+		return fmt.Errorf("rk4: F is nil")
+	}
+	return nil
+}
+func (b *RK4) Clone() Block          { c := *b; return &c }
+func (b *RK4) SetDT(dt float64)      { b.dt = dt }
+func (b *RK4) IsDelay() bool         { return true }
+func (b *RK4) InputNames() []string  { return []string{"in"} }
+func (b *RK4) OutputNames() []string { return []string{"out"} }
+func (b *RK4) Inputs() int           { return 1 }
+func (b *RK4) Outputs() int          { return 1 }
+func (b *RK4) Step(in, out []float64) bool {
+	x, u := b.State, in[0]
+	b.State = rk4(b.F, x, u, timeStep(b.dt), b.F(x, u))
+	out[0] = b.State
+	return true
+}
+
+// rk4 returns the state after a Runge-Kutta step of size h from x,
+// where k1 = f(x, u) is the derivative at the start.
+func rk4(f func(x, u float64) float64, x, u, h, k1 float64) float64 {
+	k2 := f(x+h/2*k1, u)
+	k3 := f(x+h/2*k2, u)
+	k4 := f(x+h*k3, u)
+	return x + h/6*(k1+2*k2+2*k3+k4)
+}
+
+// AdamsBashforth4 solves the differential equation x' = F(x, u) for it's
+// input u with the explicit 4 step Adams-Bashforth method
+// x[n+1] = x[n] + dt/24*(55*f[n] - 59*f[n-1] + 37*f[n-2] - 9*f[n-3]),
+// which evaluates F once per time step, instead of four times for RK4.
+// The last four derivatives are kept in a ring buffer.
+// The first three time steps are bootstrapped with the RK4 method.
+type AdamsBashforth4 struct {
+	F     func(x, u float64) float64 `json:"-"`
+	State float64                    // This can be set as the initial state.
+	f     [4]float64
+	n     int // number of derivatives in f
+	pos   int // position of the next derivative in f
+	dt    float64
+}
+
+func (b *AdamsBashforth4) Validate() error {
+	if b.F == nil {
+		return fmt.Errorf("adams-bashforth: F is nil")
+	}
+	return nil
+}
+func (b *AdamsBashforth4) Clone() Block          { return &AdamsBashforth4{F: b.F, State: b.State} }
+func (b *AdamsBashforth4) SetDT(dt float64)      { b.dt = dt }
+func (b *AdamsBashforth4) IsDelay() bool         { return true }
+func (b *AdamsBashforth4) Reset()                { b.n, b.pos = 0, 0 }
+func (b *AdamsBashforth4) InputNames() []string  { return []string{"in"} }
+func (b *AdamsBashforth4) OutputNames() []string { return []string{"out"} }
+func (b *AdamsBashforth4) Inputs() int           { return 1 }
+func (b *AdamsBashforth4) Outputs() int          { return 1 }
+func (b *AdamsBashforth4) Step(in, out []float64) bool {
+	dt, x, u := timeStep(b.dt), b.State, in[0]
+	k1 := b.F(x, u)
+	b.f[b.pos] = k1
+	b.pos = (b.pos + 1) % 4
+	b.n = min(b.n+1, 4)
+	if b.n < 4 {
+		b.State = rk4(b.F, x, u, dt, k1)
+	} else {
+		f := func(i int) float64 { return b.f[(b.pos+3-i)%4] } // f[n-i]
+		b.State += (55*f(0) - 59*f(1) + 37*f(2) - 9*f(3)) * dt / 24
+	}
+	out[0] = b.State
+	return true
+}
+
+// AdaptiveIntegrate solves the differential equation x' = F(x, u)
+// for it's input u, which is held constant over each time step.
+// Other than Integrate, the derivative is computed by the block itself,
+// which allows it to take several sub-steps within one time step dt.
+//
+// The local error of a sub-step is estimated as the difference between
+// the 4th order Runge-Kutta and the 2nd order midpoint method.
+// A sub-step with an error above Tol is rejected and repeated with a smaller
+// size, but not below MinDT. After each accepted sub-step, the size
+// of the next one is adapted to the error. It is limited to MaxDT,
+// or to dt, if MaxDT is 0. CurrentDT is the size of the next sub-step,
+// it can be monitored or given as the initial size.
+// The output is the state at the end of the time step.
+type AdaptiveIntegrate struct {
+	F         func(x, u float64) float64 `json:"-"`
+	State     float64                    // This can be set as the initial state.
+	Tol       float64
+	MinDT     float64
+	MaxDT     float64
+	CurrentDT float64
+	dt        float64
+}
+
+func (b *AdaptiveIntegrate) Validate() error {
+	if b.F == nil {
+		return fmt.Errorf("adaptive integrate: F is nil")
+	}
+	if !(b.Tol > 0) {
+		return fmt.Errorf("adaptive integrate: tolerance %v is not positive", b.Tol)
+	}
+	if b.MinDT < 0 || b.MaxDT < 0 || b.MaxDT > 0 && b.MaxDT < b.MinDT {
+		return fmt.Errorf("adaptive integrate: invalid step size limits [%v, %v]", b.MinDT, b.MaxDT)
+	}
+	return nil
+}
+func (b *AdaptiveIntegrate) Clone() Block          { c := *b; return &c }
+func (b *AdaptiveIntegrate) SetDT(dt float64)      { b.dt = dt }
+func (b *AdaptiveIntegrate) IsDelay() bool         { return true }
+func (b *AdaptiveIntegrate) InputNames() []string  { return []string{"in"} }
+func (b *AdaptiveIntegrate) OutputNames() []string { return []string{"out"} }
+func (b *AdaptiveIntegrate) Inputs() int           { return 1 }
+func (b *AdaptiveIntegrate) Outputs() int          { return 1 }
+func (b *AdaptiveIntegrate) Step(in, out []float64) bool {
+	dt, u := timeStep(b.dt), in[0]
+	maxDT := dt
+	if b.MaxDT > 0 {
+		maxDT = min(b.MaxDT, dt)
+	}
+	// A tiny lower limit prevents an endless loop, if MinDT is 0.
+	minDT := min(max(b.MinDT, 1e-9*dt), maxDT)
+	h := b.CurrentDT
+	if h <= 0 || h > maxDT {
+		h = maxDT
+	}
+	h = max(h, minDT)
+
+	// factor scales the step size for the error e of the 3rd order estimate.
+	factor := func(e float64) float64 {
+		if e == 0 {
+			return 5
+		}
+		return min(5, max(0.2, 0.9*math.Cbrt(b.Tol/e)))
+	}
+	x := b.State
+	for t := 0.0; t < dt; {
+		last := dt-t <= h
+		s := h
+		if last {
+			s = dt - t
+		}
+		k1 := b.F(x, u)
+		k2 := b.F(x+s/2*k1, u)
+		k3 := b.F(x+s/2*k2, u)
+		k4 := b.F(x+s*k3, u)
+		x4 := x + s/6*(k1+2*k2+2*k3+k4)
+		e := math.Abs(x4 - (x + s*k2))
+		if math.IsNaN(e) {
+			log.Printf("adaptive integrate: state is not finite")
+			return false
+		}
+		if e > b.Tol && s > minDT {
+			h = max(minDT, s*factor(e))
+			continue
+		}
+		x = x4
+		if last {
+			t = dt
+		} else {
+			t += s
+		}
+		if s == h {
+			h = min(maxDT, max(minDT, h*factor(e)))
+		}
+	}
+	b.State, b.CurrentDT = x, h
+	out[0] = x
+	return true
+}
+
+// TransferFunction is a continuous-time transfer function H(s) = Num(s)/Den(s).
+// The polynomial coefficients are given in descending powers of s.
+// The transfer function must be strictly proper: len(Den) > len(Num).
+//
+// The block is realized in observable canonical form and integrated
+// with the forward Euler method, like Integrate.
+// Its output is the first state, which does not depend directly on the input.
+type TransferFunction struct {
+	Num, Den []float64
+	x        []float64 // state vector
+	dt       float64
+}
+
+func (b *TransferFunction) Validate() error {
+	if len(b.Den) == 0 || b.Den[0] == 0 {
+		return fmt.Errorf("transfer function: leading denominator coefficient is zero")
+	}
+	if len(b.Den) <= len(b.Num) {
+		return fmt.Errorf("transfer function: not strictly proper: num has %d, den %d coefficients", len(b.Num), len(b.Den))
+	}
+	return nil
+}
+func (b *TransferFunction) SetDT(dt float64)      { b.dt = dt }
+func (b *TransferFunction) IsDelay() bool         { return true }
+func (b *TransferFunction) Reset()                { b.x = nil }
+func (b *TransferFunction) InputNames() []string  { return []string{"u"} }
+func (b *TransferFunction) OutputNames() []string { return []string{"y"} }
+func (b *TransferFunction) Inputs() int           { return 1 }
+func (b *TransferFunction) Outputs() int          { return 1 }
+func (b *TransferFunction) Step(in, out []float64) bool {
+	n := len(b.Den) - 1
+	if b.x == nil {
+		b.x = make([]float64, n)
+	}
+	// With the normalized coefficients a[k] = Den[k+1]/Den[0]
+	// and the numerator padded to n coefficients b[k]:
+	//	x[k]' = -a[k]*x[0] + x[k+1] + b[k]*u
+	//	y     = x[0]
+	d0, u, x0 := b.Den[0], in[0], b.x[0]
+	off := n - len(b.Num)
+	dt := timeStep(b.dt)
+	for k := 0; k < n; k++ {
+		dx := -b.Den[k+1] / d0 * x0
+		if k+1 < n {
+			dx += b.x[k+1]
+		}
+		if k >= off {
+			dx += b.Num[k-off] / d0 * u
+		}
+		b.x[k] += dx * dt
+	}
+	out[0] = b.x[0]
+	return true
+}
+
+// BiquadIIR is a second order IIR filter section in direct form II transposed:
+//
+//	y  = (B0*x + s1) / A0
+//	s1 = B1*x - A1*y + s2
+//	s2 = B2*x - A2*y
+//
+// which is the normalized form for the coefficients divided by A0.
+// A0 must be set, usually to 1.
+// Several sections can be cascaded for higher orders, see blocks/filter.
+type BiquadIIR struct {
+	B0, B1, B2 float64
+	A0, A1, A2 float64
+	s1, s2     float64
+}
+
+func (b *BiquadIIR) Validate() error {
+	if b.A0 == 0 || math.IsNaN(b.A0) || math.IsInf(b.A0, 0) {
+		return fmt.Errorf("biquad: A0 must be finite and not zero: %v", b.A0)
+	}
+	return nil
+}
+func (b *BiquadIIR) Reset()                { b.s1, b.s2 = 0, 0 }
+func (b *BiquadIIR) InputNames() []string  { return []string{"in"} }
+func (b *BiquadIIR) OutputNames() []string { return []string{"out"} }
+func (b *BiquadIIR) Inputs() int           { return 1 }
+func (b *BiquadIIR) Outputs() int          { return 1 }
+func (b *BiquadIIR) Step(in, out []float64) bool {
+	x := in[0]
+	y := (b.B0*x + b.s1) / b.A0
+	b.s1 = b.B1*x - b.A1*y + b.s2
+	b.s2 = b.B2*x - b.A2*y
+	out[0] = y
+	return true
+}
+
+// Delay delays it's input by a fixed number of steps.
+// The first Samples outputs are 0.
+// With Samples = 1 it is a unit delay, which breaks algebraic loops.
+type Delay struct {
+	Samples int
+	buf     []float64 // ring buffer
+	pos     int
+}
+
+func (b *Delay) Validate() error {
+	if b.Samples < 1 {
+		return fmt.Errorf("delay: samples must be positive: %d", b.Samples)
+	}
+	return nil
+}
+func (b *Delay) IsDelay() bool         { return true }
+func (b *Delay) Reset()                { b.buf, b.pos = nil, 0 }
+func (b *Delay) InputNames() []string  { return []string{"in"} }
+func (b *Delay) OutputNames() []string { return []string{"out"} }
+func (b *Delay) Inputs() int           { return 1 }
+func (b *Delay) Outputs() int          { return 1 }
+func (b *Delay) Step(in, out []float64) bool {
+	if b.buf == nil {
+		b.buf = make([]float64, b.Samples)
+	}
+	out[0] = b.buf[b.pos]
+	b.buf[b.pos] = in[0]
+	b.pos = (b.pos + 1) % len(b.buf)
+	return true
+}
+
+// Deadtime delays it's input by DelaySeconds of simulation time.
+// The delay in steps is max(1, round(DelaySeconds/dt)).
+// The first outputs are 0. If the time step changes, the buffer
+// is resized and keeps the most recent inputs.
+type Deadtime struct {
+	DelaySeconds float64
+	buf          []float64 // ring buffer
+	pos          int
+	dt           float64
+}
+
+func (b *Deadtime) Validate() error {
+	if b.DelaySeconds < 0 {
+		return fmt.Errorf("deadtime: negative delay %v", b.DelaySeconds)
+	}
+	return nil
+}
+
+// Samples returns the delay in steps for the current time step.
+func (b *Deadtime) Samples() int {
+	return max(1, int(math.Round(b.DelaySeconds/timeStep(b.dt))))
+}
+func (b *Deadtime) SetDT(dt float64) {
+	b.dt = dt
+	if b.buf != nil {
+		b.resize(b.Samples())
+	}
+}
+func (b *Deadtime) IsDelay() bool         { return true }
+func (b *Deadtime) Reset()                { b.buf, b.pos = nil, 0 }
+func (b *Deadtime) InputNames() []string  { return []string{"in"} }
+func (b *Deadtime) OutputNames() []string { return []string{"out"} }
+func (b *Deadtime) Inputs() int           { return 1 }
+func (b *Deadtime) Outputs() int          { return 1 }
+func (b *Deadtime) Step(in, out []float64) bool {
+	if b.buf == nil {
+		b.buf = make([]float64, b.Samples())
+	}
+	out[0] = b.buf[b.pos]
+	b.buf[b.pos] = in[0]
+	b.pos = (b.pos + 1) % len(b.buf)
+	return true
+}
+
+// resize changes the buffer to n samples.
+// It drops the oldest values or prepends zeros.
+func (b *Deadtime) resize(n int) {
+	if n == len(b.buf) {
+		return
+	}
+	old := make([]float64, len(b.buf)) // oldest first
+	for i := range old {
+		old[i] = b.buf[(b.pos+i)%len(b.buf)]
+	}
+	buf := make([]float64, n)
+	copy(buf[max(0, n-len(old)):], old[max(0, len(old)-n):])
+	b.buf, b.pos = buf, 0
+}
+
+// MovingAverage is the mean of the last Window inputs.
+// During startup, the mean of all inputs so far is used.
+type MovingAverage struct {
+	Window int
+	buf    []float64 // ring buffer
+	pos, n int
+	sum    float64
+}
+
+func (b *MovingAverage) Validate() error {
+	if b.Window < 1 {
+		return fmt.Errorf("moving average: window must be positive: %d", b.Window)
+	}
+	return nil
+}
+func (b *MovingAverage) Reset()                { b.buf, b.pos, b.n, b.sum = nil, 0, 0, 0 }
+func (b *MovingAverage) InputNames() []string  { return []string{"in"} }
+func (b *MovingAverage) OutputNames() []string { return []string{"out"} }
+func (b *MovingAverage) Inputs() int           { return 1 }
+func (b *MovingAverage) Outputs() int          { return 1 }
+func (b *MovingAverage) Step(in, out []float64) bool {
+	if b.buf == nil {
+		b.buf = make([]float64, b.Window)
+	}
+	if b.n < b.Window {
+		b.n++
+	}
+	b.sum += in[0] - b.buf[b.pos]
+	b.buf[b.pos] = in[0]
+	b.pos = (b.pos + 1) % len(b.buf)
+	out[0] = b.sum / float64(b.n)
+	return true
+}
+
+// Convolution is a FIR filter y[n] = sum(Kernel[k]*x[n-k]), which convolves
+// it's input with Kernel. The inputs before the first step are 0.
+type Convolution struct {
+	Kernel []float64
+	buf    []float64 // ring buffer of the last inputs
+	pos    int       // position of the newest input
+}
+
+func (b *Convolution) Validate() error {
+	if len(b.Kernel) == 0 {
+		return fmt.Errorf("convolution: empty kernel")
+	}
+	return nil
+}
+func (b *Convolution) Reset()                { b.buf, b.pos = nil, 0 }
+func (b *Convolution) InputNames() []string  { return []string{"in"} }
+func (b *Convolution) OutputNames() []string { return []string{"out"} }
+func (b *Convolution) Inputs() int           { return 1 }
+func (b *Convolution) Outputs() int          { return 1 }
+func (b *Convolution) Step(in, out []float64) bool {
+	n := len(b.Kernel)
+	if b.buf == nil {
+		b.buf = make([]float64, n)
+	}
+	b.pos = (b.pos + 1) % n
+	b.buf[b.pos] = in[0]
+	y := 0.0
+	for k, h := range b.Kernel {
+		y = math.FMA(h, b.buf[(b.pos-k+n)%n], y)
+	}
+	out[0] = y
+	return true
+}
+
+// MedianFilter is the median of the last Window inputs.
+// During startup, the median of all inputs so far is used.
+// For an even number of samples, it is the mean of the two middle values.
+type MedianFilter struct {
+	Window int
+	buf    []float64 // ring buffer in input order
+	sorted []float64 // the same values in ascending order
+	pos    int
+}
+
+func (b *MedianFilter) Validate() error {
+	if b.Window < 1 {
+		return fmt.Errorf("median filter: window must be positive: %d", b.Window)
+	}
+	return nil
+}
+func (b *MedianFilter) Reset()                { b.buf, b.sorted, b.pos = nil, nil, 0 }
+func (b *MedianFilter) InputNames() []string  { return []string{"in"} }
+func (b *MedianFilter) OutputNames() []string { return []string{"out"} }
+func (b *MedianFilter) Inputs() int           { return 1 }
+func (b *MedianFilter) Outputs() int          { return 1 }
+func (b *MedianFilter) Step(in, out []float64) bool {
+	x := in[0]
+	if len(b.buf) < b.Window {
+		b.buf = append(b.buf, x)
+	} else {
+		// Remove the oldest value from the sorted values.
+		old := b.buf[b.pos]
+		i := sort.SearchFloat64s(b.sorted, old)
+		b.sorted = append(b.sorted[:i], b.sorted[i+1:]...)
+		b.buf[b.pos] = x
+		b.pos = (b.pos + 1) % b.Window
+	}
+	i := sort.SearchFloat64s(b.sorted, x)
+	b.sorted = append(b.sorted, 0)
+	copy(b.sorted[i+1:], b.sorted[i:])
+	b.sorted[i] = x
+
+	n := len(b.sorted)
+	if n%2 == 1 {
+		out[0] = b.sorted[n/2]
+	} else {
+		out[0] = (b.sorted[n/2-1] + b.sorted[n/2]) / 2
+	}
+	return true
 }
 
-func (b *Integrate) Inputs() int  { return 1 }
-func (b *Integrate) Outputs() int { return 1 }
-func (b *Integrate) Step(in, out []float64) bool {
-	b.State += in[0] * DT
-	out[0] = b.State
+// SampleAndHold samples it's input with SampleRate in Hz
+// and holds the value in between.
+// The first input is sampled immediately.
+type SampleAndHold struct {
+	SampleRate float64
+	hold       float64
+	elapsed    float64 // time since the last sample
+	started    bool
+	dt         float64
+}
+
+func (b *SampleAndHold) Validate() error {
+	if b.SampleRate <= 0 {
+		return fmt.Errorf("sample and hold: sample rate must be positive: %v", b.SampleRate)
+	}
+	return nil
+}
+func (b *SampleAndHold) SetDT(dt float64)      { b.dt = dt }
+func (b *SampleAndHold) Reset()                { b.hold, b.elapsed, b.started = 0, 0, false }
+func (b *SampleAndHold) InputNames() []string  { return []string{"in"} }
+func (b *SampleAndHold) OutputNames() []string { return []string{"out"} }
+func (b *SampleAndHold) Inputs() int           { return 1 }
+func (b *SampleAndHold) Outputs() int          { return 1 }
+func (b *SampleAndHold) Step(in, out []float64) bool {
+	dt := timeStep(b.dt)
+	// Half a step tolerates the rounding of the accumulated time.
+	if !b.started || b.elapsed >= 1/b.SampleRate-dt/2 {
+		b.hold, b.elapsed, b.started = in[0], 0, true
+	}
+	b.elapsed += dt
+	out[0] = b.hold
+	return true
+}
+
+// RunningMin outputs the smallest input so far.
+// The current minimum is Value.
+type RunningMin struct {
+	Value   float64
+	started bool
+}
+
+func (b *RunningMin) Reset()                { b.Value, b.started = 0, false }
+func (b *RunningMin) InputNames() []string  { return []string{"in"} }
+func (b *RunningMin) OutputNames() []string { return []string{"out"} }
+func (b *RunningMin) Inputs() int           { return 1 }
+func (b *RunningMin) Outputs() int          { return 1 }
+func (b *RunningMin) Step(in, out []float64) bool {
+	if !b.started || in[0] < b.Value {
+		b.Value, b.started = in[0], true
+	}
+	out[0] = b.Value
+	return true
+}
+
+// RunningMax outputs the largest input so far.
+// The current maximum is Value.
+type RunningMax struct {
+	Value   float64
+	started bool
+}
+
+func (b *RunningMax) Reset()                { b.Value, b.started = 0, false }
+func (b *RunningMax) InputNames() []string  { return []string{"in"} }
+func (b *RunningMax) OutputNames() []string { return []string{"out"} }
+func (b *RunningMax) Inputs() int           { return 1 }
+func (b *RunningMax) Outputs() int          { return 1 }
+func (b *RunningMax) Step(in, out []float64) bool {
+	if !b.started || in[0] > b.Value {
+		b.Value, b.started = in[0], true
+	}
+	out[0] = b.Value
+	return true
+}
+
+// MinMax outputs the smallest and the largest input so far.
+type MinMax struct {
+	Min RunningMin
+	Max RunningMax
+}
+
+func (b *MinMax) Reset()                { b.Min.Reset(); b.Max.Reset() }
+func (b *MinMax) InputNames() []string  { return []string{"in"} }
+func (b *MinMax) OutputNames() []string { return []string{"min", "max"} }
+func (b *MinMax) Inputs() int           { return 1 }
+func (b *MinMax) Outputs() int          { return 2 }
+func (b *MinMax) Step(in, out []float64) bool {
+	b.Min.Step(in, out[:1])
+	b.Max.Step(in, out[1:])
+	return true
+}
+
+// Statistics computes the running mean, variance and standard deviation
+// of it's input with Welford's algorithm, without storing the history.
+// The outputs are the input, the mean, the variance and the standard deviation.
+// The variance is the sample variance, which is 0 for the first input.
+type Statistics struct {
+	Mean, Variance, StdDev float64
+	n                      int
+	m2                     float64 // sum of squared deviations from the mean
+}
+
+func (b *Statistics) Reset()                { *b = Statistics{} }
+func (b *Statistics) InputNames() []string  { return []string{"in"} }
+func (b *Statistics) OutputNames() []string { return []string{"value", "mean", "variance", "stddev"} }
+func (b *Statistics) Inputs() int           { return 1 }
+func (b *Statistics) Outputs() int          { return 4 }
+func (b *Statistics) Step(in, out []float64) bool {
+	x := in[0]
+	b.n++
+	d := x - b.Mean
+	b.Mean += d / float64(b.n)
+	b.m2 += d * (x - b.Mean)
+	if b.n > 1 {
+		b.Variance = b.m2 / float64(b.n-1)
+	}
+	b.StdDev = math.Sqrt(b.Variance)
+	out[0], out[1], out[2], out[3] = x, b.Mean, b.Variance, b.StdDev
 	return true
 }
 
 // Source emits a constant value each time it is called.
 type Source float64
 
-func (b Source) Inputs() int  { return 0 }
-func (b Source) Outputs() int { return 1 }
+func (b Source) InputNames() []string  { return nil }
+func (b Source) OutputNames() []string { return []string{"out"} }
+func (b Source) Inputs() int           { return 0 }
+func (b Source) Outputs() int          { return 1 }
 func (b Source) Step(in, out []float64) bool {
 	out[0] = float64(b)
 	return true
 }
 
+// SineSource emits a sine wave.
+// Like all time dependent sources, it keeps track of the time itself.
+// The first output is at time 0.
+type SineSource struct {
+	Amplitude float64
+	Frequency float64 // Frequency in Hz.
+	Phase     float64 // Phase in radians.
+	t, dt     float64
+	clocked
+}
+
+func (b *SineSource) SetDT(dt float64)      { b.dt = dt }
+func (b *SineSource) Reset()                { b.t, b.k = 0, 0 }
+func (b *SineSource) InputNames() []string  { return nil }
+func (b *SineSource) OutputNames() []string { return []string{"out"} }
+func (b *SineSource) Inputs() int           { return 0 }
+func (b *SineSource) Outputs() int          { return 1 }
+func (b *SineSource) Step(in, out []float64) bool {
+	out[0] = b.Amplitude * math.Sin(2*math.Pi*b.Frequency*b.t+b.Phase)
+	b.t = b.next(b.dt)
+	return true
+}
+
+// ChirpSource emits a linear chirp, a sine whose frequency sweeps
+// linearly from StartFreq to EndFreq within Duration seconds.
+// After Duration the frequency stays at EndFreq.
+type ChirpSource struct {
+	Amplitude float64
+	StartFreq float64 // Frequency at t=0 in Hz.
+	EndFreq   float64 // Frequency at t=Duration in Hz.
+	Duration  float64 // Sweep time in seconds.
+	t, dt     float64
+	clocked
+}
+
+func (b *ChirpSource) Validate() error {
+	if b.Duration <= 0 {
+		return fmt.Errorf("chirp: duration must be positive: %v", b.Duration)
+	}
+	return nil
+}
+func (b *ChirpSource) SetDT(dt float64)      { b.dt = dt }
+func (b *ChirpSource) Reset()                { b.t, b.k = 0, 0 }
+func (b *ChirpSource) InputNames() []string  { return nil }
+func (b *ChirpSource) OutputNames() []string { return []string{"out"} }
+func (b *ChirpSource) Inputs() int           { return 0 }
+func (b *ChirpSource) Outputs() int          { return 1 }
+func (b *ChirpSource) Step(in, out []float64) bool {
+	// The phase is the integral of the frequency.
+	f0, f1, T := b.StartFreq, b.EndFreq, b.Duration
+	t := math.Min(b.t, T)
+	phase := f0*t + (f1-f0)/(2*T)*t*t
+	if b.t > T {
+		phase += f1 * (b.t - T)
+	}
+	out[0] = b.Amplitude * math.Sin(2*math.Pi*phase)
+	b.t = b.next(b.dt)
+	return true
+}
+
+// SquareSource emits a square wave, which is +Amplitude
+// for the first half of each period and -Amplitude for the second.
+type SquareSource struct {
+	Amplitude float64
+	Frequency float64 // Frequency in Hz.
+	t, dt     float64
+	clocked
+}
+
+func (b *SquareSource) SetDT(dt float64)      { b.dt = dt }
+func (b *SquareSource) Reset()                { b.t, b.k = 0, 0 }
+func (b *SquareSource) InputNames() []string  { return nil }
+func (b *SquareSource) OutputNames() []string { return []string{"out"} }
+func (b *SquareSource) Inputs() int           { return 0 }
+func (b *SquareSource) Outputs() int          { return 1 }
+func (b *SquareSource) Step(in, out []float64) bool {
+	if _, f := math.Modf(b.Frequency * b.t); f < 0.5 {
+		out[0] = b.Amplitude
+	} else {
+		out[0] = -b.Amplitude
+	}
+	b.t = b.next(b.dt)
+	return true
+}
+
+// SawtoothSource emits a sawtooth wave, which rises linearly
+// from -Amplitude to +Amplitude during each period.
+type SawtoothSource struct {
+	Amplitude float64
+	Frequency float64 // Frequency in Hz.
+	t, dt     float64
+	clocked
+}
+
+func (b *SawtoothSource) SetDT(dt float64)      { b.dt = dt }
+func (b *SawtoothSource) Reset()                { b.t, b.k = 0, 0 }
+func (b *SawtoothSource) InputNames() []string  { return nil }
+func (b *SawtoothSource) OutputNames() []string { return []string{"out"} }
+func (b *SawtoothSource) Inputs() int           { return 0 }
+func (b *SawtoothSource) Outputs() int          { return 1 }
+func (b *SawtoothSource) Step(in, out []float64) bool {
+	_, f := math.Modf(b.Frequency * b.t)
+	out[0] = b.Amplitude * (2*f - 1)
+	b.t = b.next(b.dt)
+	return true
+}
+
+// RampSource emits Offset + Slope*t.
+type RampSource struct {
+	Slope  float64
+	Offset float64
+	t, dt  float64
+	clocked
+}
+
+func (b *RampSource) SetDT(dt float64)      { b.dt = dt }
+func (b *RampSource) Reset()                { b.t, b.k = 0, 0 }
+func (b *RampSource) InputNames() []string  { return nil }
+func (b *RampSource) OutputNames() []string { return []string{"out"} }
+func (b *RampSource) Inputs() int           { return 0 }
+func (b *RampSource) Outputs() int          { return 1 }
+func (b *RampSource) Step(in, out []float64) bool {
+	out[0] = b.Offset + b.Slope*b.t
+	b.t = b.next(b.dt)
+	return true
+}
+
+// ImpulseSource emits a discrete impulse with the area Amplitude:
+// At the first step with t >= AtTime, it outputs Amplitude/dt, otherwise 0.
+type ImpulseSource struct {
+	Amplitude float64
+	AtTime    float64
+	t, dt     float64
+	done      bool
+	clocked
+}
+
+func (b *ImpulseSource) SetDT(dt float64)      { b.dt = dt }
+func (b *ImpulseSource) Reset()                { b.t, b.k, b.done = 0, 0, false }
+func (b *ImpulseSource) InputNames() []string  { return nil }
+func (b *ImpulseSource) OutputNames() []string { return []string{"out"} }
+func (b *ImpulseSource) Inputs() int           { return 0 }
+func (b *ImpulseSource) Outputs() int          { return 1 }
+func (b *ImpulseSource) Step(in, out []float64) bool {
+	dt := timeStep(b.dt)
+	if b.clock != nil {
+		dt = b.clock.DT()
+	}
+	out[0] = 0
+	if !b.done && b.t >= b.AtTime {
+		out[0], b.done = b.Amplitude/dt, true
+	}
+	b.t = b.next(b.dt)
+	return true
+}
+
+// WhiteNoise emits normally distributed random values with zero mean.
+// The sequence is reproducible for a given Seed.
+// If Seed is 0, a random seed is used, which is returned by UsedSeed.
+type WhiteNoise struct {
+	StdDev float64
+	Seed   int64
+	rng    *rand.Rand
+	seed   int64
+}
+
+// UsedSeed returns the seed of the random sequence.
+// It is only known after the first step, if Seed is 0.
+func (b *WhiteNoise) UsedSeed() int64       { return b.seed }
+func (b *WhiteNoise) Reset()                { b.rng = nil }
+func (b *WhiteNoise) InputNames() []string  { return nil }
+func (b *WhiteNoise) OutputNames() []string { return []string{"out"} }
+func (b *WhiteNoise) Inputs() int           { return 0 }
+func (b *WhiteNoise) Outputs() int          { return 1 }
+func (b *WhiteNoise) Step(in, out []float64) bool {
+	if b.rng == nil {
+		b.rng, b.seed = newRand(b.Seed, b.seed)
+	}
+	out[0] = b.StdDev * b.rng.NormFloat64()
+	return true
+}
+
+// UniformNoise emits uniformly distributed random values in [Min, Max).
+// Seed is used as for WhiteNoise.
+type UniformNoise struct {
+	Min, Max float64
+	Seed     int64
+	rng      *rand.Rand
+	seed     int64
+}
+
+func (b *UniformNoise) Validate() error {
+	if b.Min > b.Max {
+		return fmt.Errorf("uniform noise: min %v is larger than max %v", b.Min, b.Max)
+	}
+	return nil
+}
+
+// UsedSeed returns the seed of the random sequence.
+// It is only known after the first step, if Seed is 0.
+func (b *UniformNoise) UsedSeed() int64       { return b.seed }
+func (b *UniformNoise) Reset()                { b.rng = nil }
+func (b *UniformNoise) InputNames() []string  { return nil }
+func (b *UniformNoise) OutputNames() []string { return []string{"out"} }
+func (b *UniformNoise) Inputs() int           { return 0 }
+func (b *UniformNoise) Outputs() int          { return 1 }
+func (b *UniformNoise) Step(in, out []float64) bool {
+	if b.rng == nil {
+		b.rng, b.seed = newRand(b.Seed, b.seed)
+	}
+	out[0] = b.Min + (b.Max-b.Min)*b.rng.Float64()
+	return true
+}
+
+// UniformRandom is the same block as UniformNoise under another name.
+// It emits uniformly distributed random values in [Min, Max).
+type UniformRandom UniformNoise
+
+func (b *UniformRandom) noise() *UniformNoise { return (*UniformNoise)(b) }
+
+// UsedSeed returns the seed of the random sequence, see UniformNoise.
+func (b *UniformRandom) UsedSeed() int64             { return b.noise().UsedSeed() }
+func (b *UniformRandom) Validate() error             { return b.noise().Validate() }
+func (b *UniformRandom) Reset()                      { b.noise().Reset() }
+func (b *UniformRandom) InputNames() []string        { return b.noise().InputNames() }
+func (b *UniformRandom) OutputNames() []string       { return b.noise().OutputNames() }
+func (b *UniformRandom) Inputs() int                 { return b.noise().Inputs() }
+func (b *UniformRandom) Outputs() int                { return b.noise().Outputs() }
+func (b *UniformRandom) Step(in, out []float64) bool { return b.noise().Step(in, out) }
+
+// GaussianRandom emits normally distributed random values.
+// Other than WhiteNoise, the values are sampled with the Box-Muller
+// transform from pairs of uniform values. Seed is used as for WhiteNoise.
+type GaussianRandom struct {
+	Mean, StdDev float64
+	Seed         int64
+	rng          *rand.Rand
+	seed         int64
+	next         float64 // second value of the last pair
+	hasNext      bool
+}
+
+func (b *GaussianRandom) Validate() error {
+	if b.StdDev < 0 {
+		return fmt.Errorf("gaussian random: negative standard deviation %v", b.StdDev)
+	}
+	return nil
+}
+
+// UsedSeed returns the seed of the random sequence.
+// It is only known after the first step, if Seed is 0.
+func (b *GaussianRandom) UsedSeed() int64       { return b.seed }
+func (b *GaussianRandom) Reset()                { b.rng, b.hasNext = nil, false }
+func (b *GaussianRandom) InputNames() []string  { return nil }
+func (b *GaussianRandom) OutputNames() []string { return []string{"out"} }
+func (b *GaussianRandom) Inputs() int           { return 0 }
+func (b *GaussianRandom) Outputs() int          { return 1 }
+func (b *GaussianRandom) Step(in, out []float64) bool {
+	if b.rng == nil {
+		b.rng, b.seed = newRand(b.Seed, b.seed)
+	}
+	z := b.next
+	if !b.hasNext {
+		// Float64 is in [0, 1), the log needs (0, 1].
+		z, b.next = boxMuller(1-b.rng.Float64(), b.rng.Float64())
+	}
+	b.hasNext = !b.hasNext
+	out[0] = b.Mean + b.StdDev*z
+	return true
+}
+
+// boxMuller transforms the uniform values u1 in (0, 1] and u2 in [0, 1)
+// to two independent standard normal values.
+func boxMuller(u1, u2 float64) (float64, float64) {
+	r := math.Sqrt(-2 * math.Log(u1))
+	s, c := math.Sincos(2 * math.Pi * u2)
+	return r * c, r * s
+}
+
+// newRand returns a random generator for seed.
+// If seed is 0, the previously used seed is taken, such that a reset
+// block repeats it's sequence, or a new random seed, if there is none.
+func newRand(seed, used int64) (*rand.Rand, int64) {
+	if seed == 0 {
+		seed = used
+	}
+	for seed == 0 {
+		seed = rand.Int64()
+	}
+	return rand.New(rand.NewPCG(uint64(seed), 0)), seed
+}
+
+// PRBSSource emits a pseudo-random binary sequence of +Amplitude and -Amplitude,
+// which is generated by a maximal-length linear feedback shift register with
+// Length bits (1..31). The sequence repeats with a period of 2^Length-1 steps.
+// Seed is the initial state of the register; if it's lower Length bits are 0,
+// all bits are set.
+type PRBSSource struct {
+	Length    int
+	Seed      uint32
+	Amplitude float64
+	state     uint32
+}
+
+// prbsTaps are the feedback taps of primitive polynomials for each register length.
+var prbsTaps = [32]uint32{
+	1: 1 << 0, 2: 1<<1 | 1<<0, 3: 1<<2 | 1<<1, 4: 1<<3 | 1<<2, 5: 1<<4 | 1<<2,
+	6: 1<<5 | 1<<4, 7: 1<<6 | 1<<5, 8: 1<<7 | 1<<5 | 1<<4 | 1<<3, 9: 1<<8 | 1<<4,
+	10: 1<<9 | 1<<6, 11: 1<<10 | 1<<8, 12: 1<<11 | 1<<5 | 1<<3 | 1<<0,
+	13: 1<<12 | 1<<3 | 1<<2 | 1<<0, 14: 1<<13 | 1<<4 | 1<<2 | 1<<0, 15: 1<<14 | 1<<13,
+	16: 1<<15 | 1<<14 | 1<<12 | 1<<3, 17: 1<<16 | 1<<13, 18: 1<<17 | 1<<10,
+	19: 1<<18 | 1<<5 | 1<<1 | 1<<0, 20: 1<<19 | 1<<16, 21: 1<<20 | 1<<18, 22: 1<<21 | 1<<20,
+	23: 1<<22 | 1<<17, 24: 1<<23 | 1<<22 | 1<<21 | 1<<16, 25: 1<<24 | 1<<21,
+	26: 1<<25 | 1<<5 | 1<<1 | 1<<0, 27: 1<<26 | 1<<4 | 1<<1 | 1<<0, 28: 1<<27 | 1<<24,
+	29: 1<<28 | 1<<26, 30: 1<<29 | 1<<5 | 1<<3 | 1<<0, 31: 1<<30 | 1<<27,
+}
+
+func (b *PRBSSource) Validate() error {
+	if b.Length < 1 || b.Length > 31 {
+		return fmt.Errorf("prbs source: length %d is not in 1..31", b.Length)
+	}
+	return nil
+}
+func (b *PRBSSource) Reset()                { b.state = 0 }
+func (b *PRBSSource) InputNames() []string  { return nil }
+func (b *PRBSSource) OutputNames() []string { return []string{"out"} }
+func (b *PRBSSource) Inputs() int           { return 0 }
+func (b *PRBSSource) Outputs() int          { return 1 }
+func (b *PRBSSource) Step(in, out []float64) bool {
+	mask := uint32(1)<<b.Length - 1
+	if b.state == 0 {
+		if b.state = b.Seed & mask; b.state == 0 {
+			b.state = mask
+		}
+	}
+	bit := uint32(bits.OnesCount32(b.state&prbsTaps[b.Length]) & 1)
+	b.state = (b.state<<1 | bit) & mask
+	out[0] = b.Amplitude
+	if bit == 0 {
+		out[0] = -b.Amplitude
+	}
+	return true
+}
+
+// CSVSource replays columns of a CSV file (RFC 4180), one row per step.
+// The file is read on the first step. If Header is set, the first
+// row is skipped. The simulation stops, when all rows are used.
+type CSVSource struct {
+	Filename string
+	Columns  []int // Column indexes, starting at 0.
+	Header   bool
+	rows     [][]float64
+	row      int
+	loaded   bool
+}
+
+func (b *CSVSource) Validate() error {
+	if len(b.Columns) == 0 {
+		return fmt.Errorf("csv source: no columns")
+	}
+	return nil
+}
+func (b *CSVSource) Reset()                { b.row = 0 }
+func (b *CSVSource) InputNames() []string  { return nil }
+func (b *CSVSource) OutputNames() []string { return PortNames("out", len(b.Columns)) }
+func (b *CSVSource) Inputs() int           { return 0 }
+func (b *CSVSource) Outputs() int          { return len(b.Columns) }
+func (b *CSVSource) Step(in, out []float64) bool {
+	if !b.loaded {
+		if err := b.load(); err != nil {
+			log.Printf("csv source: %s: %v", b.Filename, err)
+			return false
+		}
+		b.loaded = true
+	}
+	if b.row >= len(b.rows) {
+		return false
+	}
+	copy(out, b.rows[b.row])
+	b.row++
+	return true
+}
+
+// load reads the selected columns of all rows.
+func (b *CSVSource) load() error {
+	f, err := os.Open(b.Filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return err
+	}
+	if b.Header && len(records) > 0 {
+		records = records[1:]
+	}
+	b.rows = make([][]float64, len(records))
+	for i, r := range records {
+		row := make([]float64, len(b.Columns))
+		for k, c := range b.Columns {
+			if c < 0 || c >= len(r) {
+				return fmt.Errorf("row %d has no column %d", i+1, c)
+			}
+			if row[k], err = strconv.ParseFloat(strings.TrimSpace(r[c]), 64); err != nil {
+				return fmt.Errorf("row %d: %v", i+1, err)
+			}
+		}
+		b.rows[i] = row
+	}
+	return nil
+}
+
+// CSVSink writes it's inputs to a CSV file (RFC 4180), one row per step.
+// The format is the same as the one of Scope.CSV: a header followed
+// by the time in the first column and one column per channel.
+// The file is created on the first step and flushed every FlushEvery rows,
+// so partial results are kept, if the program crashes.
+// Within a system, it is closed when the simulation ends.
+type CSVSink struct {
+	Filename    string
+	NumChannels int // Number of input channels.
+	FlushEvery  int // Number of rows between flushes, 100 if 0.
+	f           *os.File
+	w           *csv.Writer
+	row         []string
+	rows        int
+	t, dt       float64
+	clocked
+}
+
+func (b *CSVSink) Validate() error {
+	if b.Filename == "" {
+		return fmt.Errorf("csv sink: no filename")
+	}
+	if b.FlushEvery < 0 {
+		return fmt.Errorf("csv sink: FlushEvery must not be negative: %d", b.FlushEvery)
+	}
+	return nil
+}
+func (b *CSVSink) SetDT(dt float64)      { b.dt = dt }
+func (b *CSVSink) Reset()                { b.Close(); b.t, b.k = 0, 0 }
+func (b *CSVSink) InputNames() []string  { return PortNames("in", b.NumChannels) }
+func (b *CSVSink) OutputNames() []string { return nil }
+func (b *CSVSink) Inputs() int           { return b.NumChannels }
+func (b *CSVSink) Outputs() int          { return 0 }
+func (b *CSVSink) Step(in, out []float64) bool {
+	if b.w == nil {
+		if err := b.create(); err != nil {
+			log.Printf("csv sink: %v", err)
+			return false
+		}
+	}
+	b.row[0] = strconv.FormatFloat(b.t, 'g', -1, 64)
+	for i, v := range in {
+		b.row[1+i] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	b.w.Write(b.row)
+	b.t = b.next(b.dt)
+	b.rows++
+	flush := b.FlushEvery
+	if flush == 0 {
+		flush = 100
+	}
+	if b.rows%flush == 0 {
+		b.w.Flush()
+		if err := b.w.Error(); err != nil {
+			log.Printf("csv sink: %s: %v", b.Filename, err)
+			return false
+		}
+	}
+	return true
+}
+
+// create creates the file and writes the header.
+func (b *CSVSink) create() error {
+	f, err := os.Create(b.Filename)
+	if err != nil {
+		return err
+	}
+	b.f, b.w, b.rows = f, csv.NewWriter(f), 0
+	b.row = make([]string, 1+b.NumChannels)
+	b.row[0] = "t"
+	for i := 0; i < b.NumChannels; i++ {
+		b.row[1+i] = "y" + strconv.Itoa(i)
+	}
+	b.w.Write(b.row)
+	return nil
+}
+
+// Close flushes the remaining rows and closes the file.
+// It is called by the system, when the simulation ends.
+func (b *CSVSink) Close() error {
+	if b.f == nil {
+		return nil
+	}
+	b.w.Flush()
+	err := b.w.Error()
+	if e := b.f.Close(); err == nil {
+		err = e
+	}
+	b.f, b.w = nil, nil
+	return err
+}
+
 // Print prints every input.
 // It is used as a termination block.
 // It keeps track of the global time, in order to print both, time and value.
 type Print struct {
-	time float64
+	time, dt float64
+	clocked
 }
 
-func (b *Print) Inputs() int  { return 1 }
-func (b *Print) Outputs() int { return 0 }
+func (b *Print) SetDT(dt float64)      { b.dt = dt }
+func (b *Print) Reset()                { b.time, b.k = 0, 0 }
+func (b *Print) InputNames() []string  { return []string{"in"} }
+func (b *Print) OutputNames() []string { return nil }
+func (b *Print) Inputs() int           { return 1 }
+func (b *Print) Outputs() int          { return 0 }
 func (b *Print) Step(in, out []float64) bool {
 	fmt.Println(b.time, in[0])
-	b.time += DT
+	b.time = b.next(b.dt)
+	return true
+}
+
+// Scope is a terminal block which records time series in memory.
+// After the simulation Data[i][k] is the value of channel i at step k
+// and Time[k] the corresponding simulation time.
+// Like Print, it starts counting time at 0.
+type Scope struct {
+	NumChannels int         // Number of input channels.
+	Data        [][]float64 `json:"-"` // Recorded samples per channel.
+	Time        []float64   `json:"-"` // Simulation time per sample.
+	t, dt       float64
+	clocked
+}
+
+func (b *Scope) SetDT(dt float64)      { b.dt = dt }
+func (b *Scope) Reset()                { b.Data, b.Time, b.t, b.k = nil, nil, 0, 0 }
+func (b *Scope) InputNames() []string  { return PortNames("in", b.NumChannels) }
+func (b *Scope) OutputNames() []string { return nil }
+func (b *Scope) Inputs() int           { return b.NumChannels }
+func (b *Scope) Outputs() int          { return 0 }
+func (b *Scope) Step(in, out []float64) bool {
+	if b.Data == nil {
+		b.Data = make([][]float64, b.NumChannels)
+	}
+	for i, v := range in {
+		b.Data[i] = append(b.Data[i], v)
+	}
+	b.Time = append(b.Time, b.t)
+	b.t = b.next(b.dt)
+	return true
+}
+
+// CSV writes the recorded data in CSV format (RFC 4180).
+// The header is followed by one row per step with the time
+// in the first column and one column per channel.
+func (b *Scope) CSV(w io.Writer) error {
+	c := csv.NewWriter(w)
+	row := make([]string, 1+b.NumChannels)
+	row[0] = "t"
+	for i := 0; i < b.NumChannels; i++ {
+		row[1+i] = "y" + strconv.Itoa(i)
+	}
+	c.Write(row)
+	for k, t := range b.Time {
+		row[0] = strconv.FormatFloat(t, 'g', -1, 64)
+		for i := range b.Data {
+			row[1+i] = strconv.FormatFloat(b.Data[i][k], 'g', -1, 64)
+		}
+		c.Write(row)
+	}
+	c.Flush()
+	return c.Error()
+}
+
+// ScopeReplay plays back the data of a Scope, one sample per step.
+// It ends the simulation, when all samples have been sent.
+// This can feed a model with recorded signals, e.g. controller outputs
+// which have been recorded on hardware.
+type ScopeReplay struct {
+	Recorded *Scope
+	k        int
+}
+
+func (b *ScopeReplay) Validate() error {
+	if b.Recorded == nil {
+		return fmt.Errorf("scope replay: Recorded is nil")
+	}
+	return nil
+}
+func (b *ScopeReplay) Reset()                { b.k = 0 }
+func (b *ScopeReplay) InputNames() []string  { return nil }
+func (b *ScopeReplay) OutputNames() []string { return PortNames("out", b.Outputs()) }
+func (b *ScopeReplay) Inputs() int           { return 0 }
+func (b *ScopeReplay) Outputs() int {
+	if b.Recorded == nil {
+		return 0
+	}
+	return b.Recorded.NumChannels
+}
+func (b *ScopeReplay) Step(in, out []float64) bool {
+	data := b.Recorded.Data
+	if len(data) == 0 || b.k >= len(data[0]) {
+		return false
+	}
+	for i := range out {
+		out[i] = data[i][b.k]
+	}
+	b.k++
+	return true
+}
+
+// CallbackSink calls Fn with the simulation time and it's inputs on every step.
+// Fn is called synchronously, the values must not be retained after it returns.
+// If Fn panics, the panic is logged and the simulation ends.
+type CallbackSink struct {
+	Fn          func(t float64, values []float64) `json:"-"`
+	NumChannels int                               // Number of input channels.
+	t, dt       float64
+	clocked
+}
+
+func (b *CallbackSink) Validate() error {
+	if b.Fn == nil {
+		return fmt.Errorf("callback sink: Fn is nil")
+	}
+	return nil
+}
+func (b *CallbackSink) SetDT(dt float64)      { b.dt = dt }
+func (b *CallbackSink) Clone() Block          { return &CallbackSink{Fn: b.Fn, NumChannels: b.NumChannels} }
+func (b *CallbackSink) Reset()                { b.t, b.k = 0, 0 }
+func (b *CallbackSink) InputNames() []string  { return PortNames("in", b.NumChannels) }
+func (b *CallbackSink) OutputNames() []string { return nil }
+func (b *CallbackSink) Inputs() int           { return b.NumChannels }
+func (b *CallbackSink) Outputs() int          { return 0 }
+func (b *CallbackSink) Step(in, out []float64) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("callback sink: panic at t=%v: %v", b.t, r)
+			ok = false
+		}
+	}()
+	b.Fn(b.t, in)
+	b.t = b.next(b.dt)
 	return true
 }
 
 // Tee multiplexes it's input to two ouput channels.
 type Tee struct{}
 
-func (b Tee) Inputs() int  { return 1 }
-func (b Tee) Outputs() int { return 2 }
+func (b Tee) InputNames() []string  { return []string{"in"} }
+func (b Tee) OutputNames() []string { return []string{"out0", "out1"} }
+func (b Tee) Inputs() int           { return 1 }
+func (b Tee) Outputs() int          { return 2 }
 func (b Tee) Step(in, out []float64) bool {
 	out[0] = in[0]
 	out[1] = in[0]
 	return true
 }
 
+// TeeN copies it's input to N output channels.
+// TeeN{N: 2} is the same as Tee.
+type TeeN struct {
+	N int // Number of outputs.
+}
+
+func (b TeeN) Validate() error {
+	if b.N < 1 {
+		return fmt.Errorf("teen: N must be positive: %d", b.N)
+	}
+	return nil
+}
+func (b TeeN) InputNames() []string  { return []string{"in"} }
+func (b TeeN) OutputNames() []string { return PortNames("out", b.N) }
+func (b TeeN) Inputs() int           { return 1 }
+func (b TeeN) Outputs() int          { return b.N }
+func (b TeeN) Step(in, out []float64) bool {
+	for i := range out {
+		out[i] = in[0]
+	}
+	return true
+}
+
+// ZeroCrossing passes it's input and detects zero crossings.
+// Rising and Falling select the direction of the crossings.
+// For every crossing, the time is appended to Events and Callback is called
+// if it is set. The time is interpolated linearly between the two samples.
+type ZeroCrossing struct {
+	Rising, Falling bool
+	Events          []float64       `json:"-"` // Crossing times.
+	Callback        func(t float64) `json:"-"`
+	last            float64
+	started         bool
+	t, dt           float64
+	clocked
+}
+
+func (b *ZeroCrossing) Clone() Block {
+	return &ZeroCrossing{Rising: b.Rising, Falling: b.Falling, Callback: b.Callback}
+}
+func (b *ZeroCrossing) SetDT(dt float64)      { b.dt = dt }
+func (b *ZeroCrossing) Reset()                { b.Events, b.last, b.started, b.t, b.k = nil, 0, false, 0, 0 }
+func (b *ZeroCrossing) InputNames() []string  { return []string{"in"} }
+func (b *ZeroCrossing) OutputNames() []string { return []string{"out"} }
+func (b *ZeroCrossing) Inputs() int           { return 1 }
+func (b *ZeroCrossing) Outputs() int          { return 1 }
+func (b *ZeroCrossing) Step(in, out []float64) bool {
+	x, dt := in[0], timeStep(b.dt)
+	if b.started {
+		rising := b.last < 0 && x >= 0
+		falling := b.last > 0 && x <= 0
+		if (rising && b.Rising) || (falling && b.Falling) {
+			t := b.t - dt + dt*b.last/(b.last-x)
+			b.Events = append(b.Events, t)
+			if b.Callback != nil {
+				b.Callback(t)
+			}
+		}
+	}
+	b.last, b.started = x, true
+	out[0] = x
+	b.t = b.next(b.dt)
+	return true
+}
+
+// FrequencyMeter measures the frequency of it's input by counting
+// zero crossings in both directions over a time Window.
+// At the end of each window, the frequency is crossings/(2*Window),
+// as there are two crossings per period, and the counter restarts.
+// The output holds the last measurement and is 0 during the first window.
+type FrequencyMeter struct {
+	Window    float64 // Measurement window in seconds.
+	last      float64
+	started   bool
+	crossings int
+	freq      float64
+	t, start  float64
+	dt        float64
+	clocked
+}
+
+func (b *FrequencyMeter) Validate() error {
+	if !(b.Window > 0) || math.IsInf(b.Window, 1) {
+		return fmt.Errorf("frequencymeter: window %v must be positive and finite", b.Window)
+	}
+	return nil
+}
+func (b *FrequencyMeter) SetDT(dt float64) { b.dt = dt }
+func (b *FrequencyMeter) Reset() {
+	b.last, b.started, b.crossings, b.freq, b.t, b.start, b.k = 0, false, 0, 0, 0, 0, 0
+}
+func (b *FrequencyMeter) InputNames() []string  { return []string{"in"} }
+func (b *FrequencyMeter) OutputNames() []string { return []string{"freq"} }
+func (b *FrequencyMeter) Inputs() int           { return 1 }
+func (b *FrequencyMeter) Outputs() int          { return 1 }
+func (b *FrequencyMeter) Step(in, out []float64) bool {
+	x := in[0]
+	if b.started && (b.last < 0) != (x < 0) {
+		b.crossings++
+	}
+	b.last, b.started = x, true
+	b.t = b.next(b.dt)
+	// Half a step tolerates the rounding of the accumulated time.
+	if dt := timeStep(b.dt); b.t-b.start >= b.Window-dt/2 {
+		b.freq = float64(b.crossings) / (2 * b.Window)
+		b.crossings, b.start = 0, b.t
+	}
+	out[0] = b.freq
+	return true
+}
+
 // A Stop block can be inserted between two other blocks.
 // It transparently copies it's input to the output and terminates
 // the program when a stop time is reached.
 // It can call registered cleanup functions.
 type Stop struct {
 	Time      float64  // Stop time.
-	Callbacks []func() // A slice of callbacks.
+	Callbacks []func() `json:"-"` // A slice of callbacks.
 	t         float64  // current time
+	dt        float64
+	clocked
+	force <-chan struct{} // closed by System.ForceStop
+	once  sync.Once       // calls the callbacks
 }
 
-func (s *Stop) Inputs() int  { return 1 }
-func (s *Stop) Outputs() int { return 1 }
+func (s *Stop) SetDT(dt float64) { s.dt = dt }
+func (s *Stop) Clone() Block {
+	return &Stop{Time: s.Time, Callbacks: append([]func(){}, s.Callbacks...)}
+}
+func (s *Stop) Validate() error {
+	if s.Time <= 0 {
+		return fmt.Errorf("stop: time must be positive: %v", s.Time)
+	}
+	return nil
+}
+func (s *Stop) Reset()                { s.t, s.k, s.once = 0, 0, sync.Once{} }
+func (s *Stop) InputNames() []string  { return []string{"in"} }
+func (s *Stop) OutputNames() []string { return []string{"out"} }
+func (s *Stop) Inputs() int           { return 1 }
+func (s *Stop) Outputs() int          { return 1 }
 func (s *Stop) Step(in, out []float64) bool {
-	if s.t += DT; s.t >= s.Time {
+	select {
+	case <-s.force:
+		return false
+	default:
+	}
+	if s.t = s.next(s.dt); s.t >= s.Time {
+		s.callback()
+		return false
+	}
+	out[0] = in[0]
+	return true
+}
+
+// callback calls the callbacks, once until the next Reset.
+func (s *Stop) callback() {
+	s.once.Do(func() {
 		for _, f := range s.Callbacks {
 			f()
 		}
-		return false
+	})
+}
+
+// Recorder is a terminal block which stores all inputs in memory.
+// After the simulation Data[i] contains the samples of channel i.
+type Recorder struct {
+	NumChannels int         // Number of input channels.
+	Data        [][]float64 `json:"-"` // Recorded samples per channel.
+}
+
+func (r *Recorder) Reset()                { r.Data = nil }
+func (r *Recorder) InputNames() []string  { return PortNames("in", r.NumChannels) }
+func (r *Recorder) OutputNames() []string { return nil }
+func (r *Recorder) Inputs() int           { return r.NumChannels }
+func (r *Recorder) Outputs() int          { return 0 }
+func (r *Recorder) Step(in, out []float64) bool {
+	if r.Data == nil {
+		r.Data = make([][]float64, r.NumChannels)
+	}
+	for i, v := range in {
+		r.Data[i] = append(r.Data[i], v)
+	}
+	return true
+}
+
+// Downsample returns a new Recorder which contains every factor-th
+// sample of each channel, starting with the first one.
+// A factor smaller than 1 is treated as 1.
+func (r *Recorder) Downsample(factor int) *Recorder {
+	if factor < 1 {
+		factor = 1
+	}
+	d := &Recorder{NumChannels: r.NumChannels, Data: make([][]float64, len(r.Data))}
+	for i, c := range r.Data {
+		d.Data[i] = make([]float64, 0, (len(c)+factor-1)/factor)
+		for k := 0; k < len(c); k += factor {
+			d.Data[i] = append(d.Data[i], c[k])
+		}
+	}
+	return d
+}
+
+// Resample returns a new Recorder with samples at newRate, which are
+// linearly interpolated from the data recorded at originalRate.
+// Both rates are given in samples per second.
+// The resampled data covers the same time span as the original.
+func (r *Recorder) Resample(newRate, originalRate float64) (*Recorder, error) {
+	if newRate <= 0 || originalRate <= 0 {
+		return nil, fmt.Errorf("resample: rates must be positive: new %v original %v", newRate, originalRate)
+	}
+	d := &Recorder{NumChannels: r.NumChannels, Data: make([][]float64, len(r.Data))}
+	for i, c := range r.Data {
+		if len(c) == 0 {
+			continue
+		}
+		span := float64(len(c)-1) / originalRate
+		for k := 0; float64(k)/newRate <= span; k++ {
+			x := float64(k) / newRate * originalRate // position in original samples
+			j := int(x)
+			if j >= len(c)-1 {
+				d.Data[i] = append(d.Data[i], c[len(c)-1])
+				continue
+			}
+			f := x - float64(j)
+			d.Data[i] = append(d.Data[i], c[j]+f*(c[j+1]-c[j]))
+		}
+	}
+	return d, nil
+}
+
+// ImpulseResponseCapture excites a system with a unit impulse and records
+// the response.
+// Its output is connected to the system's input and its input
+// to the system's output.
+// On the first step it emits 1, and 0 afterwards.
+// As the block reads its input before it can emit the impulse,
+// the input needs an initial condition, which is not recorded.
+// The next N input values are recorded, they are the response
+// h[k] at the times k*dt.
+type ImpulseResponseCapture struct {
+	N        int // Number of samples to record.
+	fired    bool
+	response []float64
+	dt       float64
+}
+
+func (b *ImpulseResponseCapture) SetDT(dt float64) { b.dt = dt }
+
+func (b *ImpulseResponseCapture) Validate() error {
+	if b.N < 0 {
+		return fmt.Errorf("impulse response: negative number of samples: %d", b.N)
+	}
+	return nil
+}
+func (b *ImpulseResponseCapture) IsDelay() bool         { return true }
+func (b *ImpulseResponseCapture) Reset()                { b.fired, b.response = false, nil }
+func (b *ImpulseResponseCapture) InputNames() []string  { return []string{"in"} }
+func (b *ImpulseResponseCapture) OutputNames() []string { return []string{"out"} }
+func (b *ImpulseResponseCapture) Inputs() int           { return 1 }
+func (b *ImpulseResponseCapture) Outputs() int          { return 1 }
+func (b *ImpulseResponseCapture) Step(in, out []float64) bool {
+	if b.fired && len(b.response) < b.N {
+		b.response = append(b.response, in[0])
+	}
+	out[0] = 0
+	if !b.fired {
+		out[0] = 1
+		b.fired = true
 	}
-	out[0] = in[0]
 	return true
 }
+
+// Response returns the recorded impulse response.
+func (b *ImpulseResponseCapture) Response() []float64 { return b.response }
+
+// Write writes the recorded response as CSV with a time and a value column.
+func (b *ImpulseResponseCapture) Write(w io.Writer) error {
+	c := csv.NewWriter(w)
+	c.Write([]string{"t", "response"})
+	for k, v := range b.response {
+		c.Write([]string{
+			strconv.FormatFloat(float64(k)*timeStep(b.dt), 'g', -1, 64),
+			strconv.FormatFloat(v, 'g', -1, 64),
+		})
+	}
+	c.Flush()
+	return c.Error()
+}
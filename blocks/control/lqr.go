@@ -0,0 +1,119 @@
+package control
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ktye/loops"
+	"github.com/ktye/loops/blocks/internal/mat"
+)
+
+// LQR is a state feedback controller u = -Kx.
+// It's inputs are the states x, it's outputs the plant inputs u.
+// The gain is usually computed with ComputeLQRGain.
+type LQR struct {
+	K [][]float64
+}
+
+func (b LQR) Validate() error {
+	if len(b.K) == 0 || mat.Cols(b.K) == 0 {
+		return fmt.Errorf("lqr: K is empty")
+	}
+	if err := mat.Dims("K", b.K, len(b.K), mat.Cols(b.K)); err != nil {
+		return fmt.Errorf("lqr: %v", err)
+	}
+	return nil
+}
+func (b LQR) InputNames() []string  { return loops.PortNames("x", mat.Cols(b.K)) }
+func (b LQR) OutputNames() []string { return loops.PortNames("u", len(b.K)) }
+func (b LQR) Inputs() int           { return mat.Cols(b.K) }
+func (b LQR) Outputs() int          { return len(b.K) }
+func (b LQR) Step(in, out []float64) bool {
+	mat.Mul(out, b.K, in)
+	for i := range out {
+		out[i] = -out[i]
+	}
+	return true
+}
+
+// ComputeLQRGain returns the optimal state feedback gain K = R⁻¹BᵀX
+// for the continuous-time plant x' = Ax + Bu and the cost ∫ xᵀQx + uᵀRu dt.
+//
+// X is the stabilizing solution of the algebraic Riccati equation
+//
+//	AᵀX + XA - XBR⁻¹BᵀX + Q = 0,
+//
+// which is solved with the structure-preserving doubling algorithm
+// after a Cayley transform with the shift γ = 1 + ‖A‖.
+// The pair (A, B) must be stabilizable and R positive definite.
+func ComputeLQRGain(A, B, Q, R [][]float64) (K [][]float64, err error) {
+	n, m := len(A), mat.Cols(B)
+	for _, v := range []struct {
+		name string
+		m    [][]float64
+		r, c int
+	}{{"A", A, n, n}, {"B", B, n, m}, {"Q", Q, n, n}, {"R", R, m, m}} {
+		if err := mat.Dims(v.name, v.m, v.r, v.c); err != nil {
+			return nil, fmt.Errorf("lqr: %v", err)
+		}
+	}
+	Ri, err := mat.Inverse(R)
+	if err != nil {
+		return nil, fmt.Errorf("lqr: R: %v", err)
+	}
+	G := mat.MatMul(mat.MatMul(B, Ri), mat.Transpose(B))
+	X, err := care(A, G, Q)
+	if err != nil {
+		return nil, fmt.Errorf("lqr: %v", err)
+	}
+	return mat.MatMul(mat.MatMul(Ri, mat.Transpose(B)), X), nil
+}
+
+// care solves AᵀX + XA - XGX + H = 0 with the doubling algorithm.
+func care(A, G, H [][]float64) ([][]float64, error) {
+	n := len(A)
+	I := mat.Identity(n)
+	g := 1 + mat.Norm(A)
+	Ag := mat.Add(A, -g, I)
+	Agi, err := mat.Inverse(Ag)
+	if err != nil {
+		return nil, err
+	}
+	AgTi := mat.Transpose(Agi)
+
+	// Initial values of the doubling iteration.
+	V, err := mat.Inverse(mat.Add(Ag, 1, mat.MatMul(mat.MatMul(G, AgTi), H)))
+	if err != nil {
+		return nil, err
+	}
+	W, err := mat.Inverse(mat.Add(mat.Transpose(Ag), 1, mat.MatMul(mat.MatMul(H, Agi), G)))
+	if err != nil {
+		return nil, err
+	}
+	E := mat.Add(I, 2*g, V)
+	Gk := mat.Scale(2*g, mat.MatMul(mat.MatMul(Agi, G), W))
+	P := mat.Scale(2*g, mat.MatMul(mat.MatMul(W, H), Agi))
+
+	for k := 0; k < 100; k++ {
+		Wi, err := mat.Inverse(mat.Add(I, 1, mat.MatMul(Gk, P)))
+		if err != nil {
+			return nil, err
+		}
+		EW := mat.MatMul(E, Wi)
+		dP := mat.MatMul(mat.MatMul(mat.MatMul(mat.Transpose(E), P), Wi), E)
+		Gk = mat.Add(Gk, 1, mat.MatMul(mat.MatMul(EW, Gk), mat.Transpose(E)))
+		E = mat.MatMul(EW, E)
+		P = mat.Add(P, 1, dP)
+		if mat.Norm(dP) <= 1e-13*mat.Norm(P) {
+			// The residual tells, if the solution is valid.
+			AtX := mat.MatMul(mat.Transpose(A), P)
+			res := mat.Add(mat.Add(AtX, 1, mat.Transpose(AtX)), -1, mat.MatMul(mat.MatMul(P, G), P))
+			res = mat.Add(res, 1, H)
+			if r := mat.Norm(res); r > 1e-8*(1+mat.Norm(H)+mat.Norm(P)) || math.IsNaN(r) {
+				return nil, fmt.Errorf("riccati equation has no stabilizing solution, residual %v", r)
+			}
+			return P, nil
+		}
+	}
+	return nil, fmt.Errorf("riccati equation does not converge")
+}
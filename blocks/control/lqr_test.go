@@ -0,0 +1,62 @@
+package control
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ktye/loops/blocks/internal/mat"
+)
+
+// TestComputeLQRGain checks the closed-loop eigenvalues of 2x2 plants.
+// For a 2x2 matrix, both are in the left half-plane
+// if the trace is negative and the determinant positive.
+func TestComputeLQRGain(t *testing.T) {
+	testCases := []struct {
+		name       string
+		A, B, Q, R [][]float64
+	}{
+		{"double integrator", [][]float64{{0, 1}, {0, 0}}, [][]float64{{0}, {1}}, [][]float64{{1, 0}, {0, 1}}, [][]float64{{1}}},
+		{"unstable", [][]float64{{0, 1}, {2, -1}}, [][]float64{{0}, {1}}, [][]float64{{10, 0}, {0, 1}}, [][]float64{{0.1}}},
+		{"two inputs", [][]float64{{1, 2}, {-3, 4}}, [][]float64{{1, 0}, {0, 1}}, [][]float64{{1, 0}, {0, 1}}, [][]float64{{1, 0}, {0, 2}}},
+	}
+	for _, tc := range testCases {
+		K, err := ComputeLQRGain(tc.A, tc.B, tc.Q, tc.R)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		cl := mat.Add(tc.A, -1, mat.MatMul(tc.B, K))
+		tr, det := cl[0][0]+cl[1][1], cl[0][0]*cl[1][1]-cl[0][1]*cl[1][0]
+		if tr >= 0 || det <= 0 {
+			t.Errorf("%s: closed loop %v is not stable", tc.name, cl)
+		}
+	}
+
+	// The double integrator with Q = I and R = 1 has K = [1 √3].
+	K, _ := ComputeLQRGain(testCases[0].A, testCases[0].B, testCases[0].Q, testCases[0].R)
+	if math.Abs(K[0][0]-1) > 1e-9 || math.Abs(K[0][1]-math.Sqrt(3)) > 1e-9 {
+		t.Errorf("double integrator: K = %v", K)
+	}
+
+	// A mode which cannot be controlled is not stabilizable.
+	if _, err := ComputeLQRGain([][]float64{{1, 0}, {0, 1}}, [][]float64{{1}, {0}}, [][]float64{{1, 0}, {0, 1}}, [][]float64{{1}}); err == nil {
+		t.Error("expected an error for an unstabilizable plant")
+	}
+}
+
+func TestLQR(t *testing.T) {
+	b := LQR{K: [][]float64{{1, 2}, {3, 4}, {5, 6}}}
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Inputs() != 2 || b.Outputs() != 3 {
+		t.Fatalf("inputs %d, outputs %d", b.Inputs(), b.Outputs())
+	}
+	out := make([]float64, 3)
+	b.Step([]float64{1, -1}, out)
+	if out[0] != 1 || out[1] != 1 || out[2] != 1 {
+		t.Fatalf("got %v", out)
+	}
+	if (LQR{K: [][]float64{{1, 2}, {3}}}).Validate() == nil {
+		t.Fatal("expected an error for a ragged K")
+	}
+}
@@ -0,0 +1,164 @@
+package control
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ktye/loops"
+	"github.com/ktye/loops/blocks/internal/mat"
+)
+
+// MPC is a model predictive controller for the discrete-time plant
+//
+//	x[k+1] = Ax[k] + Bu[k]
+//	y[k]   = Cx[k]
+//
+// where k counts the steps of the system.
+// It's inputs are the measured states x followed by the setpoints r
+// for the outputs y, it's outputs are the plant inputs u.
+//
+// On every step, the inputs u[0..Horizon-1] are chosen to minimize
+//
+//	Σ (y[i]-r)ᵀQ(y[i]-r) + u[i-1]ᵀRu[i-1],  i = 1..Horizon,
+//
+// subject to UMin <= u <= UMax. Only the first input is applied,
+// the rest is used to start the next optimization.
+// The quadratic program is solved by a projected gradient method.
+type MPC struct {
+	A, B, C    [][]float64
+	Horizon    int
+	Q, R       [][]float64 // Output and input weights.
+	UMin, UMax []float64   // Input limits, unlimited if nil.
+	Iterations int         // Gradient steps per system step, 200 if 0.
+
+	// The condensed problem: Y = Phi x + Gamma U.
+	phi, gamma [][]float64
+	h          [][]float64 // Hessian Gammaᵀ Qb Gamma + Rb
+	gqb        [][]float64 // Gammaᵀ Qb
+	step       float64     // 1 / Lipschitz constant
+	u          []float64   // last solution, Horizon*m values
+}
+
+func (b *MPC) Validate() error {
+	n, m, p := len(b.A), mat.Cols(b.B), len(b.C)
+	for _, v := range []struct {
+		name string
+		m    [][]float64
+		r, c int
+	}{{"A", b.A, n, n}, {"B", b.B, n, m}, {"C", b.C, p, n}, {"Q", b.Q, p, p}, {"R", b.R, m, m}} {
+		if err := mat.Dims(v.name, v.m, v.r, v.c); err != nil {
+			return fmt.Errorf("mpc: %v", err)
+		}
+	}
+	if n == 0 || m == 0 {
+		return fmt.Errorf("mpc: no states or inputs")
+	}
+	if b.Horizon < 1 {
+		return fmt.Errorf("mpc: horizon must be positive: %d", b.Horizon)
+	}
+	for _, v := range []struct {
+		name string
+		u    []float64
+	}{{"UMin", b.UMin}, {"UMax", b.UMax}} {
+		if v.u != nil && len(v.u) != m {
+			return fmt.Errorf("mpc: %s has %d values, want %d", v.name, len(v.u), m)
+		}
+	}
+	for i := range b.UMin {
+		if b.UMax != nil && b.UMin[i] > b.UMax[i] {
+			return fmt.Errorf("mpc: UMin[%d] %v is larger than UMax %v", i, b.UMin[i], b.UMax[i])
+		}
+	}
+	return nil
+}
+func (b *MPC) Reset() { b.u = nil }
+func (b *MPC) InputNames() []string {
+	return append(loops.PortNames("x", len(b.A)), loops.PortNames("r", len(b.C))...)
+}
+func (b *MPC) OutputNames() []string { return loops.PortNames("u", mat.Cols(b.B)) }
+func (b *MPC) Inputs() int           { return len(b.A) + len(b.C) }
+func (b *MPC) Outputs() int          { return mat.Cols(b.B) }
+func (b *MPC) Step(in, out []float64) bool {
+	if b.h == nil {
+		b.condense()
+	}
+	n, m, p, N := len(b.A), mat.Cols(b.B), len(b.C), b.Horizon
+	x, r := in[:n], in[n:]
+
+	// The gradient of the cost is H U + f with f = Gammaᵀ Qb (Phi x - r).
+	e := make([]float64, N*p)
+	mat.Mul(e, b.phi, x)
+	for i := range e {
+		e[i] -= r[i%p]
+	}
+	f := make([]float64, N*m)
+	mat.Mul(f, b.gqb, e)
+
+	// Warm start with the last solution, shifted by one step.
+	u := make([]float64, N*m)
+	if b.u != nil {
+		copy(u, b.u[m:])
+		copy(u[(N-1)*m:], b.u[(N-1)*m:])
+	}
+	iterations := b.Iterations
+	if iterations == 0 {
+		iterations = 200
+	}
+	g := make([]float64, N*m)
+	for k := 0; k < iterations; k++ {
+		mat.Mul(g, b.h, u)
+		for i := range u {
+			u[i] = b.clip(u[i]-b.step*(g[i]+f[i]), i%m)
+		}
+	}
+	b.u = u
+	copy(out, u[:m])
+	return true
+}
+
+// clip limits the input value v to the range of input i.
+func (b *MPC) clip(v float64, i int) float64 {
+	if b.UMin != nil {
+		v = math.Max(v, b.UMin[i])
+	}
+	if b.UMax != nil {
+		v = math.Min(v, b.UMax[i])
+	}
+	return v
+}
+
+// condense computes the prediction matrices and the Hessian.
+func (b *MPC) condense() {
+	n, m, p, N := len(b.A), mat.Cols(b.B), len(b.C), b.Horizon
+	b.phi, b.gamma = mat.Zeros(N*p, n), mat.Zeros(N*p, N*m)
+	CA := b.C // C A^i
+	CAB := make([][][]float64, N)
+	for i := 0; i < N; i++ {
+		CAB[i] = mat.MatMul(CA, b.B) // C A^i B
+		CA = mat.MatMul(CA, b.A)
+		for r := 0; r < p; r++ {
+			copy(b.phi[i*p+r], CA[r])
+		}
+	}
+	// y[i+1] depends on u[j] for j <= i by C A^(i-j) B.
+	for i := 0; i < N; i++ {
+		for j := 0; j <= i; j++ {
+			for r := 0; r < p; r++ {
+				copy(b.gamma[i*p+r][j*m:], CAB[i-j][r])
+			}
+		}
+	}
+	Qb, Rb := mat.Zeros(N*p, N*p), mat.Zeros(N*m, N*m)
+	for i := 0; i < N; i++ {
+		for r := 0; r < p; r++ {
+			copy(Qb[i*p+r][i*p:], b.Q[r])
+		}
+		for r := 0; r < m; r++ {
+			copy(Rb[i*m+r][i*m:], b.R[r])
+		}
+	}
+	b.gqb = mat.MatMul(mat.Transpose(b.gamma), Qb)
+	b.h = mat.Add(mat.MatMul(b.gqb, b.gamma), 1, Rb)
+	// The largest row sum bounds the largest eigenvalue of H.
+	b.step = 1 / math.Max(mat.Norm(b.h), 1e-300)
+}
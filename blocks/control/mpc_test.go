@@ -0,0 +1,57 @@
+package control
+
+import (
+	"math"
+	"testing"
+)
+
+// TestMPC moves a double integrator from rest at 0 to the position 1.
+// The acceleration is limited to ±1.
+func TestMPC(t *testing.T) {
+	const dt = 0.1
+	A := [][]float64{{1, dt}, {0, 1}}
+	B := [][]float64{{dt * dt / 2}, {dt}}
+	b := &MPC{
+		A: A, B: B, C: [][]float64{{1, 0}},
+		Horizon: 30,
+		Q:       [][]float64{{1}},
+		R:       [][]float64{{0.01}},
+		UMin:    []float64{-1},
+		UMax:    []float64{1},
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Inputs() != 3 || b.Outputs() != 1 {
+		t.Fatalf("inputs %d, outputs %d", b.Inputs(), b.Outputs())
+	}
+	x := []float64{0, 0}
+	u := make([]float64, 1)
+	limited := 0
+	for k := 0; k < 100; k++ {
+		b.Step([]float64{x[0], x[1], 1}, u)
+		if math.Abs(u[0]) > 1 {
+			t.Fatalf("step %d: u = %v violates the limit", k, u[0])
+		}
+		if math.Abs(u[0]) > 0.999 {
+			limited++
+		}
+		x[0], x[1] = x[0]+dt*x[1]+dt*dt/2*u[0], x[1]+dt*u[0]
+	}
+	if limited == 0 {
+		t.Error("the input limit is never active")
+	}
+	if math.Abs(x[0]-1) > 0.01 || math.Abs(x[1]) > 0.01 {
+		t.Fatalf("final state %v, want [1 0]", x)
+	}
+
+	for _, b := range []MPC{
+		{A: A, B: B, C: [][]float64{{1}}, Horizon: 1, Q: [][]float64{{1}}, R: [][]float64{{1}}},
+		{A: A, B: B, C: [][]float64{{1, 0}}, Horizon: 0, Q: [][]float64{{1}}, R: [][]float64{{1}}},
+		{A: A, B: B, C: [][]float64{{1, 0}}, Horizon: 1, Q: [][]float64{{1}}, R: [][]float64{{1}}, UMin: []float64{1}, UMax: []float64{0}},
+	} {
+		if b.Validate() == nil {
+			t.Errorf("expected an error for %+v", b)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package control provides controller blocks
+package control
+
+import (
+	"fmt"
+
+	"github.com/ktye/loops"
+)
+
+// SmithPredictor controls a plant with a deadtime.
+// It's inputs are the setpoint r and the measured plant output y,
+// it's output is the control signal u.
+//
+// The controller gets the error r - ym - (y - ymd), where ym is the output
+// of the plant model and ymd the same output delayed by Delay samples.
+// If the model is exact, the delayed terms cancel and the controller acts
+// on the model without deadtime. The measurement corrects model errors
+// and disturbances.
+//
+// Controller and PlantModel must have a single input and output.
+// They are connected in an embedded System, which runs as a sub-system.
+type SmithPredictor struct {
+	PlantModel loops.Block
+	Delay      int // Plant deadtime in samples.
+	Controller loops.Block
+	sys        *loops.System
+	dt         float64
+}
+
+func (b *SmithPredictor) Validate() error {
+	for _, v := range []struct {
+		name string
+		b    loops.Block
+	}{{"plant model", b.PlantModel}, {"controller", b.Controller}} {
+		if v.b == nil {
+			return fmt.Errorf("smith predictor: %s is nil", v.name)
+		}
+		if v.b.Inputs() != 1 || v.b.Outputs() != 1 {
+			return fmt.Errorf("smith predictor: %s has %d inputs and %d outputs, want 1 and 1", v.name, v.b.Inputs(), v.b.Outputs())
+		}
+	}
+	if b.Delay < 1 {
+		return fmt.Errorf("smith predictor: delay must be positive: %d", b.Delay)
+	}
+	return nil
+}
+func (b *SmithPredictor) SetDT(dt float64) { b.dt = dt }
+func (b *SmithPredictor) Reset() {
+	b.Close()
+	b.sys = nil
+	for _, c := range []loops.Block{b.PlantModel, b.Controller} {
+		if r, ok := c.(loops.Resetter); ok {
+			r.Reset()
+		}
+	}
+}
+
+// Close stops the embedded system.
+func (b *SmithPredictor) Close() error {
+	if b.sys == nil {
+		return nil
+	}
+	return b.sys.Close()
+}
+func (b *SmithPredictor) InputNames() []string  { return []string{"r", "y"} }
+func (b *SmithPredictor) OutputNames() []string { return []string{"u"} }
+func (b *SmithPredictor) Inputs() int           { return 2 }
+func (b *SmithPredictor) Outputs() int          { return 1 }
+func (b *SmithPredictor) Step(in, out []float64) bool {
+	if b.sys == nil {
+		b.sys = b.build()
+	}
+	return b.sys.Step(in, out)
+}
+
+// build connects the blocks of the predictor.
+// Both feedback paths of the model have an initial condition of 0.
+// The model output is sent to the delay first: the subtraction of ym
+// waits for the add block, which waits for the delay.
+func (b *SmithPredictor) build() *loops.System {
+	s := &loops.System{DT: b.dt}
+	r := s.AddInputPort(0)                // 0
+	y := s.AddInputPort(1)                // 1
+	s.Add(loops.Subtract{})               // 2 r - y
+	s.Add(loops.Add{})                    // 3 + ymd
+	s.Add(loops.Subtract{})               // 4 - ym
+	s.Add(b.Controller)                   // 5
+	s.Add(loops.Tee{})                    // 6
+	u := s.AddOutputPort(0)               // 7
+	s.Add(b.PlantModel)                   // 8
+	s.Add(loops.Tee{})                    // 9
+	s.Add(&loops.Delay{Samples: b.Delay}) // 10
+	s.Connect(r, 2, 0, 0)                 // r -> sub
+	s.Connect(y, 2, 0, 1)                 // y -> sub
+	s.Connect(2, 3, 0, 0)                 // sub -> add
+	s.Connect(3, 4, 0, 0)                 // add -> sub
+	s.Connect(4, 5, 0, 0)                 // sub -> controller
+	s.Connect(5, 6, 0, 0)                 // controller -> tee
+	s.Connect(6, u, 0, 0)                 // tee -> u
+	s.Connect(6, 8, 1, 0)                 // tee -> model
+	s.Connect(8, 9, 0, 0)                 // model -> tee
+	s.Connect(9, 10, 0, 0)                // tee -> delay
+	s.Connect(9, 4, 1, 1)                 // ym -> sub
+	s.Connect(10, 3, 0, 1)                // ymd -> add
+	s.AddIC(0, 4, 1)
+	s.AddIC(0, 3, 1)
+	return s
+}
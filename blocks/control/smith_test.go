@@ -0,0 +1,118 @@
+package control
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/ktye/loops"
+)
+
+// pi is a PI controller.
+type pi struct {
+	kp, ki   float64
+	integral float64
+	dt       float64
+}
+
+func (b *pi) SetDT(dt float64) { b.dt = dt }
+func (b *pi) Inputs() int      { return 1 }
+func (b *pi) Outputs() int     { return 1 }
+func (b *pi) Step(in, out []float64) bool {
+	b.integral += in[0] * b.dt
+	out[0] = b.kp*in[0] + b.ki*b.integral
+	return true
+}
+
+// deadtimePlant adds the plant 1/(s+1) with a deadtime of delay samples,
+// and returns the block indexes of it's input and it's output tee,
+// which sends y to output 0 and the scope to output 1.
+func deadtimePlant(s *loops.System, scope *loops.Scope, delay int) (int, int) {
+	n := s.NumBlocks()
+	s.Add(&loops.TransferFunction{Num: []float64{1}, Den: []float64{1, 1}}) // n
+	s.Add(&loops.Delay{Samples: delay})                                     // n+1
+	s.Add(loops.Tee{})                                                      // n+2
+	s.Add(scope)                                                            // n+3
+	s.Connect(n, n+1, 0, 0)
+	s.Connect(n+1, n+2, 0, 0)
+	s.Connect(n+2, n+3, 1, 0)
+	return n, n + 2
+}
+
+// iae returns the integral of the absolute error to the setpoint 1.
+func iae(y []float64, dt float64) float64 {
+	var e float64
+	for _, v := range y {
+		e += math.Abs(1-v) * dt
+	}
+	return e
+}
+
+// TestSmithPredictor tracks a unit step with a PI controller, which is
+// tuned for the plant without deadtime. With the deadtime, the direct
+// loop is unstable, the Smith predictor tracks the setpoint.
+func TestSmithPredictor(t *testing.T) {
+	const dt, delay = 0.01, 100 // 1s deadtime
+
+	// Direct PI control.
+	direct := loops.Scope{NumChannels: 1}
+	var s loops.System
+	s.DT = dt
+	s.Add(loops.Source(1))       // 0
+	s.Add(&loops.Stop{Time: 20}) // 1
+	s.Add(loops.Subtract{})      // 2
+	s.Add(&pi{kp: 2, ki: 2})     // 3
+	s.Connect(0, 1, 0, 0)        // r -> stop
+	s.Connect(1, 2, 0, 0)        // stop -> sub
+	s.Connect(2, 3, 0, 0)        // sub -> pi
+	in, out := deadtimePlant(&s, &direct, delay)
+	s.Connect(3, in, 0, 0)  // pi -> plant
+	s.Connect(out, 2, 0, 1) // y -> sub
+	s.AddIC(0, 2, 1)
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same controller with a Smith predictor and an exact model.
+	smith := loops.Scope{NumChannels: 1}
+	predictor := &SmithPredictor{
+		PlantModel: &loops.TransferFunction{Num: []float64{1}, Den: []float64{1, 1}},
+		Delay:      delay,
+		Controller: &pi{kp: 2, ki: 2},
+	}
+	var p loops.System
+	p.DT = dt
+	p.Add(loops.Source(1))       // 0
+	p.Add(&loops.Stop{Time: 20}) // 1
+	p.Add(predictor)             // 2
+	p.Connect(0, 1, 0, 0)        // r -> stop
+	p.Connect(1, 2, 0, 0)        // stop -> predictor
+	in, out = deadtimePlant(&p, &smith, delay)
+	p.Connect(2, in, 0, 0)  // u -> plant
+	p.Connect(out, 2, 0, 1) // y -> predictor
+	p.AddIC(0, 2, 1)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	d, m := iae(direct.Data[0], dt), iae(smith.Data[0], dt)
+	t.Logf("integral absolute error: direct %.3f, smith predictor %.3f", d, m)
+	if m > d/2 {
+		t.Fatalf("smith predictor %v is not better than direct control %v", m, d)
+	}
+	y := smith.Data[0]
+	if last := y[len(y)-1]; math.Abs(last-1) > 0.01 {
+		t.Fatalf("smith predictor ends at %v", last)
+	}
+	var peak float64
+	for _, v := range y {
+		peak = math.Max(peak, v)
+	}
+	if peak > 1.1 {
+		t.Fatalf("smith predictor overshoots to %v", peak)
+	}
+
+	if (&SmithPredictor{PlantModel: loops.Add{}, Controller: &pi{}, Delay: 1}).Validate() == nil {
+		t.Fatal("expected an error for a plant model with 2 inputs")
+	}
+}
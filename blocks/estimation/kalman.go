@@ -0,0 +1,168 @@
+// Package estimation provides blocks which estimate the state of a system
+package estimation
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/ktye/loops"
+	"github.com/ktye/loops/blocks/internal/mat"
+)
+
+// KalmanFilter estimates the state of a discrete linear system
+// from noisy measurements:
+//
+//	x[k+1] = Ax[k] + Bu[k] + w,  cov(w) = Q
+//	y[k]   = Cx[k] + Du[k] + v,  cov(v) = R
+//
+// The inputs are u followed by y, the outputs are the estimated states.
+// Each step corrects the estimate with the measurement y[k] and outputs it.
+// Then it predicts the state of the next step with u[k].
+// The matrices are dense and stored row-major, B and D may be nil.
+type KalmanFilter struct {
+	A, B, C, D [][]float64
+	Q          [][]float64 // Process noise covariance.
+	R          [][]float64 // Measurement noise covariance.
+	P          [][]float64 // Error covariance, this can be set as the initial covariance.
+	K          [][]float64 // Steady-state gain, if set P is not updated. See NewSteadyStateKalman.
+	State      []float64   // This can be set as the initial estimate.
+}
+
+// NewSteadyStateKalman returns a KalmanFilter with a constant gain.
+// The gain is computed from the solution of the discrete algebraic
+// Riccati equation, which is solved by iterating the covariance update
+// until it converges.
+func NewSteadyStateKalman(A, B, C, D, Q, R [][]float64) (*KalmanFilter, error) {
+	n := len(A)
+	b := &KalmanFilter{A: A, B: B, C: C, D: D, Q: Q, R: R, P: mat.Identity(n)}
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	for i := 0; i < 100000; i++ {
+		K, err := b.gain()
+		if err != nil {
+			return nil, err
+		}
+		P := b.predictCovariance(b.updateCovariance(K))
+		d := 0.0
+		for r := range P {
+			for c := range P[r] {
+				d = math.Max(d, math.Abs(P[r][c]-b.P[r][c]))
+			}
+		}
+		b.P = P
+		if d <= 1e-12*(1+mat.Norm(P)) {
+			b.K, err = b.gain()
+			b.P = nil
+			return b, err
+		}
+	}
+	return nil, fmt.Errorf("kalman: riccati equation does not converge")
+}
+
+// Validate checks that the matrix dimensions are consistent.
+func (b *KalmanFilter) Validate() error {
+	n, m, p := len(b.A), mat.Cols(b.B), len(b.C)
+	if err := mat.Dims("A", b.A, n, n); err != nil {
+		return fmt.Errorf("kalman: %v", err)
+	}
+	if b.B != nil {
+		if err := mat.Dims("B", b.B, n, m); err != nil {
+			return fmt.Errorf("kalman: %v", err)
+		}
+	}
+	if err := mat.Dims("C", b.C, p, n); err != nil {
+		return fmt.Errorf("kalman: %v", err)
+	}
+	if b.D != nil {
+		if err := mat.Dims("D", b.D, p, m); err != nil {
+			return fmt.Errorf("kalman: %v", err)
+		}
+	}
+	if b.K != nil {
+		if err := mat.Dims("K", b.K, n, p); err != nil {
+			return fmt.Errorf("kalman: %v", err)
+		}
+	} else {
+		for _, m := range []struct {
+			name string
+			m    [][]float64
+			n    int
+		}{{"Q", b.Q, n}, {"R", b.R, p}, {"P", b.P, n}} {
+			if err := mat.Dims(m.name, m.m, m.n, m.n); err != nil {
+				return fmt.Errorf("kalman: %v", err)
+			}
+		}
+	}
+	if b.State != nil && len(b.State) != n {
+		return fmt.Errorf("kalman: state has length %d, want %d", len(b.State), n)
+	}
+	return nil
+}
+func (b *KalmanFilter) InputNames() []string {
+	return append(loops.PortNames("u", mat.Cols(b.B)), loops.PortNames("y", len(b.C))...)
+}
+func (b *KalmanFilter) OutputNames() []string { return loops.PortNames("x", len(b.A)) }
+func (b *KalmanFilter) Inputs() int           { return mat.Cols(b.B) + len(b.C) }
+func (b *KalmanFilter) Outputs() int          { return len(b.A) }
+func (b *KalmanFilter) Step(in, out []float64) bool {
+	if b.State == nil {
+		b.State = make([]float64, len(b.A))
+	}
+	u, y := in[:mat.Cols(b.B)], in[mat.Cols(b.B):]
+
+	// Update with the measurement.
+	K := b.K
+	if K == nil {
+		var err error
+		if K, err = b.gain(); err != nil {
+			log.Print(err)
+			return false
+		}
+		b.P = b.updateCovariance(K)
+	}
+	e := make([]float64, len(y))
+	mat.Mul(e, b.C, b.State)
+	if b.D != nil {
+		mat.MulAdd(e, b.D, u)
+	}
+	for i := range e {
+		e[i] = y[i] - e[i]
+	}
+	mat.MulAdd(b.State, K, e)
+	copy(out, b.State)
+
+	// Predict the next state.
+	x := make([]float64, len(b.State))
+	mat.Mul(x, b.A, b.State)
+	if b.B != nil {
+		mat.MulAdd(x, b.B, u)
+	}
+	b.State = x
+	if b.K == nil {
+		b.P = b.predictCovariance(b.P)
+	}
+	return true
+}
+
+// gain returns K = PC'(CPC' + R)^-1.
+func (b *KalmanFilter) gain() ([][]float64, error) {
+	PCt := mat.MatMul(b.P, mat.Transpose(b.C))
+	S := mat.Add(mat.MatMul(b.C, PCt), 1, b.R)
+	Si, err := mat.Inverse(S)
+	if err != nil {
+		return nil, fmt.Errorf("kalman: innovation covariance: %v", err)
+	}
+	return mat.MatMul(PCt, Si), nil
+}
+
+// updateCovariance returns (I - KC)P.
+func (b *KalmanFilter) updateCovariance(K [][]float64) [][]float64 {
+	return mat.Add(b.P, -1, mat.MatMul(mat.MatMul(K, b.C), b.P))
+}
+
+// predictCovariance returns APA' + Q.
+func (b *KalmanFilter) predictCovariance(P [][]float64) [][]float64 {
+	return mat.Add(mat.MatMul(mat.MatMul(b.A, P), mat.Transpose(b.A)), 1, b.Q)
+}
@@ -0,0 +1,97 @@
+package estimation
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// TestDoubleIntegrator estimates position and velocity of a double
+// integrator, which is driven by a sine and random accelerations.
+// Only the position is measured with noise.
+func TestDoubleIntegrator(t *testing.T) {
+	const dt, q, r = 0.01, 0.1, 0.05
+	A := [][]float64{{1, dt}, {0, 1}}
+	B := [][]float64{{dt * dt / 2}, {dt}}
+	C := [][]float64{{1, 0}}
+	Q := [][]float64{{q * q * dt * dt * dt * dt / 4, q * q * dt * dt * dt / 2}, {q * q * dt * dt * dt / 2, q * q * dt * dt}}
+	R := [][]float64{{r * r}}
+
+	steady, err := NewSteadyStateKalman(A, B, C, nil, Q, R)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filters := []*KalmanFilter{
+		{A: A, B: B, C: C, Q: Q, R: R, P: [][]float64{{1, 0}, {0, 1}}},
+		steady,
+	}
+	for _, f := range filters {
+		if err := f.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if f.Inputs() != 2 || f.Outputs() != 2 {
+			t.Fatalf("inputs %d, outputs %d", f.Inputs(), f.Outputs())
+		}
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	x := []float64{0, 0}
+	var measErr, estErr [2]float64
+	out := make([]float64, 2)
+	const n = 5000
+	for k := 0; k < n; k++ {
+		u := math.Sin(float64(k) * dt)
+		y := x[0] + r*rng.NormFloat64()
+		for i, f := range filters {
+			f.Step([]float64{u, y}, out)
+			if k >= n/2 {
+				estErr[i] += (out[0] - x[0]) * (out[0] - x[0])
+			}
+		}
+		if k >= n/2 {
+			measErr[0] += (y - x[0]) * (y - x[0])
+		}
+		a := u + q*rng.NormFloat64()
+		x[0], x[1] = x[0]+dt*x[1]+dt*dt/2*a, x[1]+dt*a
+	}
+	meas := math.Sqrt(measErr[0] / (n / 2))
+	for i := range filters {
+		est := math.Sqrt(estErr[i] / (n / 2))
+		t.Logf("filter %d: rms estimation error %.4f, measurement error %.4f", i, est, meas)
+		if est > meas/3 {
+			t.Errorf("filter %d: rms error %v is not much smaller than the measurement error %v", i, est, meas)
+		}
+	}
+
+	// The time-varying gain converges to the steady-state gain.
+	K, err := filters[0].gain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range K {
+		if math.Abs(K[i][0]-steady.K[i][0]) > 1e-6*math.Abs(steady.K[i][0]) {
+			t.Errorf("K[%d] = %v, steady-state %v", i, K[i][0], steady.K[i][0])
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	A := [][]float64{{1, 0.1}, {0, 1}}
+	C := [][]float64{{1, 0}}
+	I := [][]float64{{1, 0}, {0, 1}}
+	for _, b := range []KalmanFilter{
+		{A: [][]float64{{1, 0.1}}, C: C, Q: I, R: [][]float64{{1}}, P: I},
+		{A: A, C: [][]float64{{1}}, Q: I, R: [][]float64{{1}}, P: I},
+		{A: A, C: C, Q: I, R: I, P: I},
+		{A: A, C: C, Q: I, R: [][]float64{{1}}},
+		{A: A, C: C, K: I},
+		{A: A, C: C, K: [][]float64{{1}, {1}}, State: []float64{1}},
+	} {
+		if b.Validate() == nil {
+			t.Errorf("expected an error for %+v", b)
+		}
+	}
+	if _, err := NewSteadyStateKalman(A, nil, [][]float64{{0, 0}}, nil, I, [][]float64{{0}}); err == nil {
+		t.Error("expected an error for a singular innovation covariance")
+	}
+}
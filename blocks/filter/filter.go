@@ -0,0 +1,50 @@
+// Package filter designs digital filters from loops blocks
+package filter
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ktye/loops"
+)
+
+// NewButterworthLowPass returns the sections of a Butterworth low pass filter
+// of the given order, which must be connected in series.
+// The attenuation at cutoffHz is 3 dB.
+//
+// The filter is designed with the bilinear transform with frequency prewarping.
+// Each pair of poles is a biquad section, an odd order adds a first order section.
+func NewButterworthLowPass(order int, cutoffHz, sampleRate float64) ([]*loops.BiquadIIR, error) {
+	if order < 1 {
+		return nil, fmt.Errorf("butterworth: order must be positive: %d", order)
+	}
+	if cutoffHz <= 0 || cutoffHz >= sampleRate/2 {
+		return nil, fmt.Errorf("butterworth: cutoff %v must be within (0, %v)", cutoffHz, sampleRate/2)
+	}
+	w0 := 2 * math.Pi * cutoffHz / sampleRate
+	var r []*loops.BiquadIIR
+	for k := 0; k < order/2; k++ {
+		// The quality factor of the k'th pole pair.
+		q := 1 / (2 * math.Sin(math.Pi*float64(2*k+1)/float64(2*order)))
+		alpha := math.Sin(w0) / (2 * q)
+		c := math.Cos(w0)
+		r = append(r, &loops.BiquadIIR{
+			B0: (1 - c) / 2,
+			B1: 1 - c,
+			B2: (1 - c) / 2,
+			A0: 1 + alpha,
+			A1: -2 * c,
+			A2: 1 - alpha,
+		})
+	}
+	if order%2 == 1 {
+		K := math.Tan(w0 / 2)
+		r = append(r, &loops.BiquadIIR{
+			B0: K,
+			B1: K,
+			A0: 1 + K,
+			A1: K - 1,
+		})
+	}
+	return r, nil
+}
@@ -0,0 +1,42 @@
+package filter
+
+import (
+	"math"
+	"testing"
+)
+
+// TestButterworthLowPass filters a sine at the cutoff frequency
+// and checks that it is attenuated by 3 dB.
+func TestButterworthLowPass(t *testing.T) {
+	const fs, fc = 1000.0, 50.0
+	for order := 1; order <= 6; order++ {
+		sections, err := NewButterworthLowPass(order, fc, fs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(sections); n != (order+1)/2 {
+			t.Fatalf("order %d: %d sections", order, n)
+		}
+		peak := 0.0
+		y := make([]float64, 1)
+		for k := 0; k < 2000; k++ {
+			y[0] = math.Sin(2 * math.Pi * fc * float64(k) / fs)
+			for _, s := range sections {
+				s.Step(y, y)
+			}
+			if k >= 1000 { // after the transient
+				peak = math.Max(peak, math.Abs(y[0]))
+			}
+		}
+		if db := 20 * math.Log10(peak); math.Abs(db+3) > 0.5 {
+			t.Errorf("order %d: %.2f dB at the cutoff frequency", order, db)
+		}
+	}
+
+	if _, err := NewButterworthLowPass(0, fc, fs); err == nil {
+		t.Error("expected an error for order 0")
+	}
+	if _, err := NewButterworthLowPass(2, fs/2, fs); err == nil {
+		t.Error("expected an error for a cutoff at the Nyquist frequency")
+	}
+}
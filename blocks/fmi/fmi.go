@@ -0,0 +1,213 @@
+// Package fmi runs FMI 2.0 co-simulation units (FMUs) as loops blocks
+//
+// An FMU is a zip file with a model description and a shared library,
+// which is exported by many modelling tools, such as Modelica environments.
+// Loading the shared library needs cgo.
+package fmi
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ktye/loops"
+)
+
+// FMUBlock is a block which runs an FMU for co-simulation.
+// The inputs and outputs are the real valued variables with causality
+// input and output, in the order of the model description.
+// Each step sets the inputs, advances the FMU by the time step
+// with fmi2DoStep and reads the outputs.
+type FMUBlock struct {
+	Model   ModelDescription
+	dir     string // extracted fmu
+	fmu     *instance
+	in, out []uint32 // value references
+	t, dt   float64
+}
+
+// ModelDescription is the part of modelDescription.xml which is used by FMUBlock.
+type ModelDescription struct {
+	ModelName string `xml:"modelName,attr"`
+	GUID      string `xml:"guid,attr"`
+	CoSim     struct {
+		ModelIdentifier string `xml:"modelIdentifier,attr"`
+	} `xml:"CoSimulation"`
+	Variables []ScalarVariable `xml:"ModelVariables>ScalarVariable"`
+}
+
+// ScalarVariable is a model variable. Only real variables have Real set.
+type ScalarVariable struct {
+	Name           string    `xml:"name,attr"`
+	ValueReference uint32    `xml:"valueReference,attr"`
+	Causality      string    `xml:"causality,attr"`
+	Real           *struct{} `xml:"Real"`
+}
+
+// ParseModelDescription parses modelDescription.xml.
+func ParseModelDescription(r io.Reader) (ModelDescription, error) {
+	var m ModelDescription
+	if err := xml.NewDecoder(r).Decode(&m); err != nil {
+		return m, fmt.Errorf("fmi: model description: %w", err)
+	}
+	if m.CoSim.ModelIdentifier == "" {
+		return m, fmt.Errorf("fmi: %s does not support co-simulation", m.ModelName)
+	}
+	return m, nil
+}
+
+// references returns the value references of the real variables with the given causality.
+func (m ModelDescription) references(causality string) []uint32 {
+	var r []uint32
+	for _, v := range m.Variables {
+		if v.Real != nil && v.Causality == causality {
+			r = append(r, v.ValueReference)
+		}
+	}
+	return r
+}
+
+// NewFMUBlock extracts the FMU at path to a temporary directory,
+// loads the shared library for the current platform and initializes
+// the FMU at time 0.
+// Close must be called to unload it, when the block is not needed anymore.
+func NewFMUBlock(path string) (*FMUBlock, error) {
+	dir, err := os.MkdirTemp("", "fmu")
+	if err != nil {
+		return nil, err
+	}
+	b, err := newFMUBlock(path, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return b, nil
+}
+
+func newFMUBlock(path, dir string) (*FMUBlock, error) {
+	if err := unzip(path, dir); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, "modelDescription.xml"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := ParseModelDescription(f)
+	if err != nil {
+		return nil, err
+	}
+	lib := filepath.Join(dir, "binaries", platform(), m.CoSim.ModelIdentifier+libExt())
+	resources := "file://" + filepath.ToSlash(filepath.Join(dir, "resources"))
+	fmu, err := instantiate(lib, m.CoSim.ModelIdentifier, m.GUID, resources)
+	if err != nil {
+		return nil, err
+	}
+	return &FMUBlock{
+		Model: m,
+		dir:   dir,
+		fmu:   fmu,
+		in:    m.references("input"),
+		out:   m.references("output"),
+	}, nil
+}
+
+// Close terminates the FMU, unloads the library and removes the extracted files.
+func (b *FMUBlock) Close() error {
+	if b.fmu != nil {
+		b.fmu.free()
+		b.fmu = nil
+	}
+	return os.RemoveAll(b.dir)
+}
+
+func (b *FMUBlock) SetDT(dt float64) { b.dt = dt }
+func (b *FMUBlock) Inputs() int      { return len(b.in) }
+func (b *FMUBlock) Outputs() int     { return len(b.out) }
+func (b *FMUBlock) Step(in, out []float64) bool {
+	if b.fmu == nil {
+		log.Print("fmi: step after close")
+		return false
+	}
+	dt := b.dt
+	if dt == 0 {
+		dt = loops.DefaultDT
+	}
+	if err := b.fmu.step(b.in, in, b.out, out, b.t, dt); err != nil {
+		log.Print(err)
+		return false
+	}
+	b.t += dt
+	return true
+}
+
+// platform returns the name of the binaries directory of the FMI standard.
+func platform() string {
+	name := map[string]string{"linux": "linux", "darwin": "darwin", "windows": "win"}[runtime.GOOS]
+	if strings.HasSuffix(runtime.GOARCH, "64") {
+		return name + "64"
+	}
+	return name + "32"
+}
+
+// libExt returns the file extension of a shared library.
+func libExt() string {
+	switch runtime.GOOS {
+	case "windows":
+		return ".dll"
+	case "darwin":
+		return ".dylib"
+	}
+	return ".so"
+}
+
+// unzip extracts the zip file to dir.
+func unzip(path, dir string) error {
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+	for _, f := range z.File {
+		name := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if !strings.HasPrefix(name, filepath.Clean(dir)+string(filepath.Separator)) {
+			return fmt.Errorf("fmi: invalid file name in zip: %s", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(name, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := extract(f, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extract(f *zip.File, name string) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
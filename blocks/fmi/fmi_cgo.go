@@ -0,0 +1,144 @@
+//go:build cgo
+
+package fmi
+
+/*
+#cgo linux LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdarg.h>
+#include <stdio.h>
+#include <stdlib.h>
+
+// Types of the FMI 2.0 standard, see fmi2FunctionTypes.h.
+typedef void*        fmi2Component;
+typedef void*        fmi2ComponentEnvironment;
+typedef unsigned int fmi2ValueReference;
+typedef double       fmi2Real;
+typedef int          fmi2Boolean;
+typedef const char*  fmi2String;
+typedef int          fmi2Status; // fmi2OK is 0, fmi2Warning 1
+typedef int          fmi2Type;   // fmi2CoSimulation is 1
+
+typedef struct {
+	void  (*logger)(fmi2ComponentEnvironment, fmi2String, fmi2Status, fmi2String, fmi2String, ...);
+	void* (*allocateMemory)(size_t, size_t);
+	void  (*freeMemory)(void*);
+	void  (*stepFinished)(fmi2ComponentEnvironment, fmi2Status);
+	fmi2ComponentEnvironment componentEnvironment;
+} fmi2CallbackFunctions;
+
+typedef fmi2Component (*fmi2InstantiateTYPE)(fmi2String, fmi2Type, fmi2String, fmi2String, const fmi2CallbackFunctions*, fmi2Boolean, fmi2Boolean);
+typedef fmi2Status (*fmi2SetupExperimentTYPE)(fmi2Component, fmi2Boolean, fmi2Real, fmi2Real, fmi2Boolean, fmi2Real);
+typedef fmi2Status (*fmi2ComponentTYPE)(fmi2Component);
+typedef fmi2Status (*fmi2RealTYPE)(fmi2Component, const fmi2ValueReference*, size_t, fmi2Real*);
+typedef fmi2Status (*fmi2DoStepTYPE)(fmi2Component, fmi2Real, fmi2Real, fmi2Boolean);
+typedef void (*fmi2FreeInstanceTYPE)(fmi2Component);
+
+static void logger(fmi2ComponentEnvironment env, fmi2String name, fmi2Status status, fmi2String category, fmi2String message, ...) {
+	va_list args;
+	va_start(args, message);
+	fprintf(stderr, "fmi: %s [%s]: ", name, category);
+	vfprintf(stderr, message, args);
+	fprintf(stderr, "\n");
+	va_end(args);
+}
+
+static fmi2CallbackFunctions callbacks = {logger, calloc, free, NULL, NULL};
+
+typedef struct {
+	void*                   lib;
+	fmi2Component           c;
+	fmi2SetupExperimentTYPE setup;
+	fmi2ComponentTYPE       enterInit, exitInit, terminate;
+	fmi2RealTYPE            setReal, getReal;
+	fmi2DoStepTYPE          doStep;
+	fmi2FreeInstanceTYPE    freeInstance;
+} fmu;
+
+// load opens the library and instantiates the fmu.
+// It returns the name of a missing symbol or a message on failure.
+static const char* load(fmu* f, const char* path, const char* name, const char* guid, const char* resources) {
+	f->lib = dlopen(path, RTLD_NOW|RTLD_LOCAL);
+	if (!f->lib) return dlerror();
+	fmi2InstantiateTYPE inst = (fmi2InstantiateTYPE)dlsym(f->lib, "fmi2Instantiate");
+	if (!inst) return "fmi2Instantiate";
+#define SYM(field, type, sym) f->field = (type)dlsym(f->lib, sym); if (!f->field) return sym;
+	SYM(setup, fmi2SetupExperimentTYPE, "fmi2SetupExperiment")
+	SYM(enterInit, fmi2ComponentTYPE, "fmi2EnterInitializationMode")
+	SYM(exitInit, fmi2ComponentTYPE, "fmi2ExitInitializationMode")
+	SYM(terminate, fmi2ComponentTYPE, "fmi2Terminate")
+	SYM(setReal, fmi2RealTYPE, "fmi2SetReal")
+	SYM(getReal, fmi2RealTYPE, "fmi2GetReal")
+	SYM(doStep, fmi2DoStepTYPE, "fmi2DoStep")
+	SYM(freeInstance, fmi2FreeInstanceTYPE, "fmi2FreeInstance")
+#undef SYM
+	f->c = inst(name, 1, guid, resources, &callbacks, 0, 0);
+	if (!f->c) return "fmi2Instantiate failed";
+	if (f->setup(f->c, 0, 0, 0, 0, 0) > 1) return "fmi2SetupExperiment failed";
+	if (f->enterInit(f->c) > 1) return "fmi2EnterInitializationMode failed";
+	if (f->exitInit(f->c) > 1) return "fmi2ExitInitializationMode failed";
+	return NULL;
+}
+
+// The status of a call is an error, if it is larger than fmi2Warning.
+static int setReal(fmu* f, const fmi2ValueReference* vr, size_t n, fmi2Real* v) { return f->setReal(f->c, vr, n, v); }
+static int getReal(fmu* f, const fmi2ValueReference* vr, size_t n, fmi2Real* v) { return f->getReal(f->c, vr, n, v); }
+static int doStep(fmu* f, fmi2Real t, fmi2Real dt) { return f->doStep(f->c, t, dt, 1); }
+
+static void unload(fmu* f) {
+	if (f->c) {
+		f->terminate(f->c);
+		f->freeInstance(f->c);
+	}
+	if (f->lib) dlclose(f->lib);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// instance is a loaded and initialized fmu.
+type instance struct {
+	f *C.fmu
+}
+
+func instantiate(lib, name, guid, resources string) (*instance, error) {
+	f := (*C.fmu)(C.calloc(1, C.sizeof_fmu))
+	cs := make([]*C.char, 4)
+	for i, s := range []string{lib, name, guid, resources} {
+		cs[i] = C.CString(s)
+		defer C.free(unsafe.Pointer(cs[i]))
+	}
+	if msg := C.load(f, cs[0], cs[1], cs[2], cs[3]); msg != nil {
+		err := fmt.Errorf("fmi: load %s: %s", lib, C.GoString(msg))
+		C.unload(f)
+		C.free(unsafe.Pointer(f))
+		return nil, err
+	}
+	return &instance{f: f}, nil
+}
+
+func (fmu *instance) step(inRef []uint32, in []float64, outRef []uint32, out []float64, t, dt float64) error {
+	if len(in) > 0 {
+		if C.setReal(fmu.f, (*C.fmi2ValueReference)(unsafe.Pointer(&inRef[0])), C.size_t(len(in)), (*C.fmi2Real)(unsafe.Pointer(&in[0]))) > 1 {
+			return fmt.Errorf("fmi: fmi2SetReal failed at t=%v", t)
+		}
+	}
+	if C.doStep(fmu.f, C.fmi2Real(t), C.fmi2Real(dt)) > 1 {
+		return fmt.Errorf("fmi: fmi2DoStep failed at t=%v", t)
+	}
+	if len(out) > 0 {
+		if C.getReal(fmu.f, (*C.fmi2ValueReference)(unsafe.Pointer(&outRef[0])), C.size_t(len(out)), (*C.fmi2Real)(unsafe.Pointer(&out[0]))) > 1 {
+			return fmt.Errorf("fmi: fmi2GetReal failed at t=%v", t)
+		}
+	}
+	return nil
+}
+
+func (fmu *instance) free() {
+	C.unload(fmu.f)
+	C.free(unsafe.Pointer(fmu.f))
+}
@@ -0,0 +1,18 @@
+//go:build !cgo
+
+package fmi
+
+import "fmt"
+
+// instance is not available without cgo.
+type instance struct{}
+
+func instantiate(lib, name, guid, resources string) (*instance, error) {
+	return nil, fmt.Errorf("fmi: loading %s needs cgo", lib)
+}
+
+func (fmu *instance) step(inRef []uint32, in []float64, outRef []uint32, out []float64, t, dt float64) error {
+	return fmt.Errorf("fmi: fmus need cgo")
+}
+
+func (fmu *instance) free() {}
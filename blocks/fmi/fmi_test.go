@@ -0,0 +1,35 @@
+package fmi
+
+import (
+	"strings"
+	"testing"
+)
+
+const integratorXML = `<?xml version="1.0" encoding="UTF-8"?>
+<fmiModelDescription fmiVersion="2.0" modelName="integrator" guid="{8c4e810f-3df3-4a00-8276-176fa3c9f000}">
+  <CoSimulation modelIdentifier="integrator"/>
+  <ModelVariables>
+    <ScalarVariable name="u" valueReference="0" causality="input"><Real start="0"/></ScalarVariable>
+    <ScalarVariable name="k" valueReference="2" causality="parameter"><Real start="1"/></ScalarVariable>
+    <ScalarVariable name="on" valueReference="0" causality="input"><Boolean start="true"/></ScalarVariable>
+    <ScalarVariable name="y" valueReference="1" causality="output"><Real/></ScalarVariable>
+  </ModelVariables>
+</fmiModelDescription>`
+
+// TestParseModelDescription counts the real inputs and outputs.
+func TestParseModelDescription(t *testing.T) {
+	m, err := ParseModelDescription(strings.NewReader(integratorXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.CoSim.ModelIdentifier != "integrator" || m.GUID == "" {
+		t.Fatalf("%+v", m)
+	}
+	in, out := m.references("input"), m.references("output")
+	if len(in) != 1 || in[0] != 0 || len(out) != 1 || out[0] != 1 {
+		t.Fatalf("inputs %v, outputs %v", in, out)
+	}
+	if _, err := ParseModelDescription(strings.NewReader(`<fmiModelDescription modelName="me"/>`)); err == nil {
+		t.Fatal("expected an error for a model without co-simulation")
+	}
+}
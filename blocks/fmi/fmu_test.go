@@ -0,0 +1,117 @@
+//go:build cgo
+
+package fmi
+
+import (
+	"archive/zip"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// integratorC is a minimal FMU which integrates it's input: y' = k*u.
+const integratorC = `
+#include <stdlib.h>
+typedef struct { double u, y, k; } model;
+void* fmi2Instantiate(const char* name, int type, const char* guid, const char* res, const void* cb, int vis, int log) {
+	model* m = calloc(1, sizeof(model));
+	m->k = 1;
+	return m;
+}
+int fmi2SetupExperiment(void* c, int td, double tol, double t0, int sd, double t1) { return 0; }
+int fmi2EnterInitializationMode(void* c) { return 0; }
+int fmi2ExitInitializationMode(void* c) { return 0; }
+int fmi2Terminate(void* c) { return 0; }
+void fmi2FreeInstance(void* c) { free(c); }
+int fmi2SetReal(void* c, const unsigned int* vr, size_t n, const double* v) {
+	model* m = c;
+	for (size_t i = 0; i < n; i++) {
+		if (vr[i] == 0) m->u = v[i];
+		else if (vr[i] == 2) m->k = v[i];
+		else return 3;
+	}
+	return 0;
+}
+int fmi2GetReal(void* c, const unsigned int* vr, size_t n, double* v) {
+	model* m = c;
+	for (size_t i = 0; i < n; i++) v[i] = vr[i] == 1 ? m->y : 0;
+	return 0;
+}
+int fmi2DoStep(void* c, double t, double dt, int noSet) {
+	model* m = c;
+	m->y += m->k * m->u * dt;
+	return 0;
+}
+`
+
+// buildFMU compiles the integrator and packs it into an fmu.
+func buildFMU(t *testing.T) string {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc is not installed")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "integrator.c")
+	lib := filepath.Join(dir, "integrator.so")
+	if err := os.WriteFile(src, []byte(integratorC), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("gcc", "-shared", "-fPIC", "-o", lib, src).CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	so, err := os.ReadFile(lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "integrator.fmu")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	z := zip.NewWriter(f)
+	for name, data := range map[string][]byte{
+		"modelDescription.xml":                              []byte(integratorXML),
+		"binaries/" + platform() + "/integrator" + libExt(): so,
+	} {
+		w, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestFMUBlock integrates a constant input with the compiled FMU.
+func TestFMUBlock(t *testing.T) {
+	b, err := NewFMUBlock(buildFMU(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if b.Inputs() != 1 || b.Outputs() != 1 {
+		t.Fatalf("inputs %d, outputs %d", b.Inputs(), b.Outputs())
+	}
+	b.SetDT(0.1)
+	out := make([]float64, 1)
+	for k := 1; k <= 10; k++ {
+		if !b.Step([]float64{2}, out) {
+			t.Fatalf("step %d failed", k)
+		}
+		if want := 0.2 * float64(k); math.Abs(out[0]-want) > 1e-12 {
+			t.Fatalf("step %d: got %v, want %v", k, out[0], want)
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Step([]float64{2}, out) {
+		t.Fatal("step after close succeeds")
+	}
+}
@@ -0,0 +1,110 @@
+// Package fsm provides a state machine block for loops
+package fsm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A Transition changes the state from From to To, if Cond is true for
+// the current inputs. Label describes the condition in the DOT graph.
+type Transition struct {
+	From, To int
+	Cond     func(in []float64) bool
+	Label    string
+}
+
+// StateMachine is a block with discrete states, which are identified by integers.
+//
+// On each step, the transitions from the current state are evaluated
+// in the order of Transitions, and the first one whose condition is true
+// is taken. At most one transition is taken per step.
+// Then the output function of the new state computes the outputs.
+// An output function which only uses the state gives a Moore machine,
+// one which also uses the inputs a Mealy machine.
+// States without an output function have zero outputs.
+type StateMachine struct {
+	NumInputs, NumOutputs int
+	Initial               int // Initial state.
+	Transitions           []Transition
+	Output                map[int]func(in, out []float64) // Output functions by state.
+	Names                 map[int]string                  // Optional state names for Dot.
+	state                 int
+	started               bool
+}
+
+// State returns the current state.
+func (m *StateMachine) State() int {
+	if !m.started {
+		return m.Initial
+	}
+	return m.state
+}
+
+// Validate checks that all transitions have a condition.
+func (m *StateMachine) Validate() error {
+	for i, t := range m.Transitions {
+		if t.Cond == nil {
+			return fmt.Errorf("fsm: transition %d from %d to %d has no condition", i, t.From, t.To)
+		}
+	}
+	return nil
+}
+func (m *StateMachine) Reset()       { m.started = false }
+func (m *StateMachine) Inputs() int  { return m.NumInputs }
+func (m *StateMachine) Outputs() int { return m.NumOutputs }
+func (m *StateMachine) Step(in, out []float64) bool {
+	s := m.State()
+	for _, t := range m.Transitions {
+		if t.From == s && t.Cond(in) {
+			s = t.To
+			break
+		}
+	}
+	m.state, m.started = s, true
+	if f := m.Output[s]; f != nil {
+		f(in, out)
+	} else {
+		for i := range out {
+			out[i] = 0
+		}
+	}
+	return true
+}
+
+// Dot returns the state machine as a graph in the DOT language.
+// The initial state is drawn as a double circle.
+func (m *StateMachine) Dot() string {
+	states := map[int]bool{m.Initial: true}
+	for _, t := range m.Transitions {
+		states[t.From], states[t.To] = true, true
+	}
+	for s := range m.Output {
+		states[s] = true
+	}
+	ids := make([]int, 0, len(states))
+	for s := range states {
+		ids = append(ids, s)
+	}
+	sort.Ints(ids)
+
+	var b strings.Builder
+	b.WriteString("digraph fsm {\n\trankdir=LR;\n\tnode [shape=circle];\n")
+	for _, s := range ids {
+		label, ok := m.Names[s]
+		if !ok {
+			label = fmt.Sprint(s)
+		}
+		fmt.Fprintf(&b, "\ts%d [label=%q", s, label)
+		if s == m.Initial {
+			b.WriteString(", shape=doublecircle")
+		}
+		b.WriteString("];\n")
+	}
+	for _, t := range m.Transitions {
+		fmt.Fprintf(&b, "\ts%d -> s%d [label=%q];\n", t.From, t.To, t.Label)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
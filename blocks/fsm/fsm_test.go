@@ -0,0 +1,62 @@
+package fsm
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestThermostat switches a heater on below 19 degrees and off above 21.
+func TestThermostat(t *testing.T) {
+	const off, heating = 0, 1
+	m := StateMachine{
+		NumInputs:  1,
+		NumOutputs: 1,
+		Initial:    off,
+		Transitions: []Transition{
+			{From: off, To: heating, Cond: func(in []float64) bool { return in[0] < 19 }, Label: "T < 19"},
+			{From: heating, To: off, Cond: func(in []float64) bool { return in[0] > 21 }, Label: "T > 21"},
+		},
+		Output: map[int]func(in, out []float64){
+			heating: func(in, out []float64) { out[0] = 1 },
+		},
+		Names: map[int]string{off: "off", heating: "heating"},
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	out := make([]float64, 1)
+	for k, c := range []struct {
+		temp  float64
+		state int
+	}{
+		{20, off},
+		{19, off},
+		{18.5, heating},
+		{20, heating},
+		{21, heating},
+		{21.5, off},
+		{20, off},
+		{10, heating},
+	} {
+		m.Step([]float64{c.temp}, out)
+		if m.State() != c.state || out[0] != float64(c.state) {
+			t.Fatalf("step %d at %v: state %d, output %v, want %d", k, c.temp, m.State(), out[0], c.state)
+		}
+	}
+	m.Reset()
+	if m.State() != off {
+		t.Fatalf("state after reset is %d", m.State())
+	}
+
+	dot := m.Dot()
+	for _, s := range []string{
+		`s0 [label="off", shape=doublecircle];`,
+		`s1 [label="heating"];`,
+		`s0 -> s1 [label="T < 19"];`,
+		`s1 -> s0 [label="T > 21"];`,
+	} {
+		if !strings.Contains(dot, s) {
+			t.Fatalf("%s is missing:\n%s", s, dot)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package mat provides the dense matrix operations of the lti, estimation and control blocks
+package mat
+
+import (
+	"fmt"
+	"math"
+)
+
+// Dense matrices are stored row-major as [][]float64.
+
+// Mul computes y = M*x.
+func Mul(y []float64, m [][]float64, x []float64) {
+	for i, row := range m {
+		y[i] = dot(row, x)
+	}
+}
+
+// MulAdd computes y += M*x.
+func MulAdd(y []float64, m [][]float64, x []float64) {
+	for i, row := range m {
+		y[i] += dot(row, x)
+	}
+}
+
+func dot(a, b []float64) float64 {
+	s := 0.0
+	for j, v := range a {
+		s += v * b[j]
+	}
+	return s
+}
+
+// MatMul returns a*b.
+func MatMul(a, b [][]float64) [][]float64 {
+	r := Zeros(len(a), Cols(b))
+	for i := range r {
+		for j := range r[i] {
+			for k := range b {
+				r[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return r
+}
+
+// Transpose returns a'.
+func Transpose(a [][]float64) [][]float64 {
+	r := Zeros(Cols(a), len(a))
+	for i := range r {
+		for j := range a {
+			r[i][j] = a[j][i]
+		}
+	}
+	return r
+}
+
+// Add returns a + s*b.
+func Add(a [][]float64, s float64, b [][]float64) [][]float64 {
+	r := make([][]float64, len(a))
+	for i := range r {
+		r[i] = make([]float64, len(a[i]))
+		for j := range r[i] {
+			r[i][j] = a[i][j] + s*b[i][j]
+		}
+	}
+	return r
+}
+
+// Scale returns s*a.
+func Scale(s float64, a [][]float64) [][]float64 {
+	return Add(Zeros(len(a), Cols(a)), s, a)
+}
+
+// Zeros returns a zero matrix with r rows and c columns.
+func Zeros(r, c int) [][]float64 {
+	m := make([][]float64, r)
+	for i := range m {
+		m[i] = make([]float64, c)
+	}
+	return m
+}
+
+// Identity returns the n x n identity matrix.
+func Identity(n int) [][]float64 {
+	m := Zeros(n, n)
+	for i := range m {
+		m[i][i] = 1
+	}
+	return m
+}
+
+// Norm returns the largest absolute row sum of a.
+// It bounds the magnitude of the eigenvalues of a.
+func Norm(a [][]float64) float64 {
+	var m float64
+	for _, row := range a {
+		s := 0.0
+		for _, v := range row {
+			s += math.Abs(v)
+		}
+		m = math.Max(m, s)
+	}
+	return m
+}
+
+// Inverse inverts a by Gauss-Jordan elimination with partial pivoting.
+func Inverse(a [][]float64) ([][]float64, error) {
+	n := len(a)
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, 2*n)
+		copy(m[i], a[i])
+		m[i][n+i] = 1
+	}
+	for c := 0; c < n; c++ {
+		p := c
+		for r := c + 1; r < n; r++ {
+			if math.Abs(m[r][c]) > math.Abs(m[p][c]) {
+				p = r
+			}
+		}
+		if m[p][c] == 0 {
+			return nil, fmt.Errorf("matrix is singular")
+		}
+		m[c], m[p] = m[p], m[c]
+		d := m[c][c]
+		for j := range m[c] {
+			m[c][j] /= d
+		}
+		for r := range m {
+			if f := m[r][c]; r != c && f != 0 {
+				for j := range m[r] {
+					m[r][j] -= f * m[c][j]
+				}
+			}
+		}
+	}
+	for i := range m {
+		m[i] = m[i][n:]
+	}
+	return m, nil
+}
+
+// Cols returns the number of columns of m.
+func Cols(m [][]float64) int {
+	if len(m) == 0 {
+		return 0
+	}
+	return len(m[0])
+}
+
+// Dims checks that m has r rows and c columns.
+// The error does not have a prefix, the caller adds the block name.
+func Dims(name string, m [][]float64, r, c int) error {
+	if len(m) != r {
+		return fmt.Errorf("%s has %d rows, want %d", name, len(m), r)
+	}
+	for i, row := range m {
+		if len(row) != c {
+			return fmt.Errorf("%s row %d has %d columns, want %d", name, i, len(row), c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package mat
+
+import "testing"
+
+func TestInverse(t *testing.T) {
+	a := [][]float64{{0, 2, 1}, {1, 1, 0}, {3, 0, 4}}
+	ai, err := Inverse(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := Add(MatMul(a, ai), -1, Identity(3))
+	if r := Norm(d); r > 1e-14 {
+		t.Errorf("a*inv(a)-I has the norm %v", r)
+	}
+	if _, err := Inverse([][]float64{{1, 2}, {2, 4}}); err == nil {
+		t.Error("expected an error for a singular matrix")
+	}
+}
+
+func TestMul(t *testing.T) {
+	m := [][]float64{{1, 2}, {3, 4}, {5, 6}}
+	y := []float64{1, 1, 1}
+	MulAdd(y, m, []float64{1, -1})
+	if y[0] != 0 || y[1] != 0 || y[2] != 0 {
+		t.Errorf("MulAdd: got %v", y)
+	}
+	Mul(y, Transpose(m), []float64{1, 0, -1})
+	if y[0] != -4 || y[1] != -4 {
+		t.Errorf("Mul: got %v", y[:2])
+	}
+	if n := Norm(Scale(-1, m)); n != 11 {
+		t.Errorf("Norm: got %v, want 11", n)
+	}
+	if err := Dims("M", m, 3, 2); err != nil {
+		t.Error(err)
+	}
+	if err := Dims("M", m, 2, 3); err == nil || err.Error() != "M has 3 rows, want 2" {
+		t.Errorf("Dims: got %v", err)
+	}
+}
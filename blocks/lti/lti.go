@@ -0,0 +1,100 @@
+// Package lti provides blocks for linear time-invariant systems
+package lti
+
+import (
+	"fmt"
+
+	"github.com/ktye/loops"
+	"github.com/ktye/loops/blocks/internal/mat"
+)
+
+// An Integrator advances the state x by one time step dt.
+// f computes the derivative dx of a state x.
+type Integrator func(x []float64, dt float64, f func(x, dx []float64))
+
+// Euler is the forward Euler method: x += dt * f(x).
+func Euler(x []float64, dt float64, f func(x, dx []float64)) {
+	dx := make([]float64, len(x))
+	f(x, dx)
+	for i := range x {
+		x[i] += dt * dx[i]
+	}
+}
+
+// StateSpace simulates a MIMO system in state-space form:
+//
+//	x' = Ax + Bu
+//	y  = Cx + Du
+//
+// The matrices are dense and stored row-major.
+// The state is advanced first, then the output is computed from
+// the new state, like loops.Integrate.
+type StateSpace struct {
+	A, B, C, D [][]float64
+	State      []float64  // This can be set as the initial state.
+	Integrator Integrator // Defaults to Euler.
+	dt         float64
+}
+
+// Validate checks that the matrix dimensions are consistent.
+func (b *StateSpace) Validate() error {
+	n, m, p := len(b.A), mat.Cols(b.B), len(b.C)
+	if err := mat.Dims("A", b.A, n, n); err != nil {
+		return fmt.Errorf("statespace: %v", err)
+	}
+	if err := mat.Dims("B", b.B, n, m); err != nil {
+		return fmt.Errorf("statespace: %v", err)
+	}
+	if err := mat.Dims("C", b.C, p, n); err != nil {
+		return fmt.Errorf("statespace: %v", err)
+	}
+	if b.D != nil {
+		if err := mat.Dims("D", b.D, p, m); err != nil {
+			return fmt.Errorf("statespace: %v", err)
+		}
+	}
+	if b.State != nil && len(b.State) != n {
+		return fmt.Errorf("statespace: state has length %d, want %d", len(b.State), n)
+	}
+	return nil
+}
+
+// IsDelay returns true, if there is no direct feedthrough:
+// the output only depends on the state if D is zero.
+func (b *StateSpace) IsDelay() bool {
+	for _, row := range b.D {
+		for _, v := range row {
+			if v != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+func (b *StateSpace) InputNames() []string  { return loops.PortNames("u", mat.Cols(b.B)) }
+func (b *StateSpace) OutputNames() []string { return loops.PortNames("y", len(b.C)) }
+func (b *StateSpace) SetDT(dt float64)      { b.dt = dt }
+func (b *StateSpace) Inputs() int           { return mat.Cols(b.B) }
+func (b *StateSpace) Outputs() int          { return len(b.C) }
+func (b *StateSpace) Step(in, out []float64) bool {
+	if b.State == nil {
+		b.State = make([]float64, len(b.A))
+	}
+	dt := b.dt
+	if dt == 0 {
+		dt = loops.DefaultDT
+	}
+	integrate := b.Integrator
+	if integrate == nil {
+		integrate = Euler
+	}
+	integrate(b.State, dt, func(x, dx []float64) {
+		mat.Mul(dx, b.A, x)
+		mat.MulAdd(dx, b.B, in)
+	})
+	mat.Mul(out, b.C, b.State)
+	if b.D != nil {
+		mat.MulAdd(out, b.D, in)
+	}
+	return true
+}
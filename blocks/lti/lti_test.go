@@ -0,0 +1,44 @@
+package lti
+
+import (
+	"math"
+	"testing"
+)
+
+// TestDoubleIntegrator drives a double integrator with a unit step.
+// With forward Euler the velocity is k*dt and the position
+// dt²*k*(k-1)/2 after k steps.
+func TestDoubleIntegrator(t *testing.T) {
+	b := StateSpace{
+		A: [][]float64{{0, 1}, {0, 0}},
+		B: [][]float64{{0}, {1}},
+		C: [][]float64{{1, 0}, {0, 1}},
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Inputs() != 1 || b.Outputs() != 2 || !b.IsDelay() {
+		t.Fatalf("inputs %d, outputs %d, delay %v", b.Inputs(), b.Outputs(), b.IsDelay())
+	}
+	const dt = 0.1
+	b.SetDT(dt)
+	out := make([]float64, 2)
+	for k := 1; k <= 10; k++ {
+		b.Step([]float64{1}, out)
+		pos, vel := dt*dt*float64(k*(k-1))/2, dt*float64(k)
+		if math.Abs(out[0]-pos) > 1e-12 || math.Abs(out[1]-vel) > 1e-12 {
+			t.Fatalf("step %d: got %v, want [%v %v]", k, out, pos, vel)
+		}
+	}
+
+	for _, b := range []StateSpace{
+		{A: [][]float64{{0, 1}}, B: [][]float64{{0}, {1}}, C: [][]float64{{1, 0}}},
+		{A: [][]float64{{0, 1}, {0, 0}}, B: [][]float64{{0}}, C: [][]float64{{1, 0}}},
+		{A: [][]float64{{0, 1}, {0, 0}}, B: [][]float64{{0}, {1}}, C: [][]float64{{1}}},
+		{A: [][]float64{{0, 1}, {0, 0}}, B: [][]float64{{0}, {1}}, C: [][]float64{{1, 0}}, D: [][]float64{{0, 0}}},
+	} {
+		if b.Validate() == nil {
+			t.Errorf("expected an error for %v", b)
+		}
+	}
+}
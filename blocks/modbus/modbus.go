@@ -0,0 +1,257 @@
+// Package modbus provides blocks which read and write holding registers of a Modbus TCP server
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// timeout limits the time for a single request.
+const timeout = time.Second
+
+// Each value is encoded as an IEEE 754 single precision float in two
+// consecutive registers, starting with the high word at the register address.
+func encode(v float64) [2]uint16 {
+	u := math.Float32bits(float32(v))
+	return [2]uint16{uint16(u >> 16), uint16(u)}
+}
+func decode(r [2]uint16) float64 {
+	return float64(math.Float32frombits(uint32(r[0])<<16 | uint32(r[1])))
+}
+
+// client is a connection to a Modbus TCP server, which is shared
+// between all blocks with the same address.
+type client struct {
+	sync.Mutex
+	addr string
+	refs int
+	conn net.Conn
+	tid  uint16
+}
+
+var pool = struct {
+	sync.Mutex
+	clients map[string]*client
+}{clients: make(map[string]*client)}
+
+// connect returns the pooled client for addr.
+func connect(addr string) *client {
+	pool.Lock()
+	defer pool.Unlock()
+	c := pool.clients[addr]
+	if c == nil {
+		c = &client{addr: addr}
+		pool.clients[addr] = c
+	}
+	c.refs++
+	return c
+}
+
+// release closes the connection when the last block releases it.
+func (c *client) release() error {
+	pool.Lock()
+	defer pool.Unlock()
+	if c.refs--; c.refs > 0 {
+		return nil
+	}
+	delete(pool.clients, c.addr)
+	c.Lock()
+	defer c.Unlock()
+	return c.close()
+}
+
+func (c *client) close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// request sends a pdu and returns the response pdu.
+// A broken connection is reopened once, before the error is returned.
+func (c *client) request(unit byte, pdu []byte) ([]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+	r, err := c.roundtrip(unit, pdu)
+	if err != nil {
+		if _, ok := err.(exception); ok {
+			return nil, err
+		}
+		c.close()
+		r, err = c.roundtrip(unit, pdu)
+	}
+	if err != nil {
+		c.close()
+	}
+	return r, err
+}
+
+func (c *client) roundtrip(unit byte, pdu []byte) ([]byte, error) {
+	if c.conn == nil {
+		conn, err := net.DialTimeout("tcp", c.addr, timeout)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	c.tid++
+
+	// MBAP header: transaction id, protocol 0, length, unit id.
+	adu := make([]byte, 7, 7+len(pdu))
+	binary.BigEndian.PutUint16(adu, c.tid)
+	binary.BigEndian.PutUint16(adu[4:], uint16(1+len(pdu)))
+	adu[6] = unit
+	if _, err := c.conn.Write(append(adu, pdu...)); err != nil {
+		return nil, err
+	}
+	var h [7]byte
+	if _, err := io.ReadFull(c.conn, h[:]); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint16(h[4:]))
+	if tid := binary.BigEndian.Uint16(h[:]); tid != c.tid || n < 2 {
+		return nil, fmt.Errorf("bad response header %x", h)
+	}
+	r := make([]byte, n-1)
+	if _, err := io.ReadFull(c.conn, r); err != nil {
+		return nil, err
+	}
+	if r[0] == pdu[0]|0x80 {
+		return nil, exception(r[1])
+	} else if r[0] != pdu[0] {
+		return nil, fmt.Errorf("response has function code %d, want %d", r[0], pdu[0])
+	}
+	return r, nil
+}
+
+// exception is a Modbus exception code returned by the server.
+type exception byte
+
+func (e exception) Error() string { return fmt.Sprintf("modbus exception %d", byte(e)) }
+
+// readFloat reads the value at the register address a.
+func (c *client) readFloat(unit byte, a uint16) (float64, error) {
+	r, err := c.request(unit, []byte{3, byte(a >> 8), byte(a), 0, 2})
+	if err != nil {
+		return 0, err
+	}
+	if len(r) != 6 || r[1] != 4 {
+		return 0, fmt.Errorf("short response for register %d", a)
+	}
+	return decode([2]uint16{binary.BigEndian.Uint16(r[2:]), binary.BigEndian.Uint16(r[4:])}), nil
+}
+
+// writeFloat writes v to the register address a.
+func (c *client) writeFloat(unit byte, a uint16, v float64) error {
+	w := encode(v)
+	_, err := c.request(unit, []byte{16, byte(a >> 8), byte(a), 0, 2, 4,
+		byte(w[0] >> 8), byte(w[0]), byte(w[1] >> 8), byte(w[1])})
+	return err
+}
+
+// ModbusTCPSource reads one value for each of the Registers per step
+// from the server at Addr.
+type ModbusTCPSource struct {
+	Addr      string
+	UnitID    byte
+	Registers []uint16
+	client    *client
+}
+
+func (b *ModbusTCPSource) Validate() error       { return validate("modbus source", b.Addr, b.Registers) }
+func (b *ModbusTCPSource) Reset()                { b.Close() }
+func (b *ModbusTCPSource) InputNames() []string  { return nil }
+func (b *ModbusTCPSource) OutputNames() []string { return names(b.Registers) }
+func (b *ModbusTCPSource) Inputs() int           { return 0 }
+func (b *ModbusTCPSource) Outputs() int          { return len(b.Registers) }
+func (b *ModbusTCPSource) Step(in, out []float64) bool {
+	if b.client == nil {
+		b.client = connect(b.Addr)
+	}
+	for i, a := range b.Registers {
+		v, err := b.client.readFloat(b.UnitID, a)
+		if err != nil {
+			log.Printf("modbus source: %v", err)
+			return false
+		}
+		out[i] = v
+	}
+	return true
+}
+
+// Close releases the connection, the system calls it at the end of the simulation.
+func (b *ModbusTCPSource) Close() error {
+	if b.client == nil {
+		return nil
+	}
+	err := b.client.release()
+	b.client = nil
+	return err
+}
+
+// ModbusTCPSink writes it's inputs to the Registers of the server at Addr.
+type ModbusTCPSink struct {
+	Addr      string
+	UnitID    byte
+	Registers []uint16
+	client    *client
+}
+
+func (b *ModbusTCPSink) Validate() error       { return validate("modbus sink", b.Addr, b.Registers) }
+func (b *ModbusTCPSink) Reset()                { b.Close() }
+func (b *ModbusTCPSink) InputNames() []string  { return names(b.Registers) }
+func (b *ModbusTCPSink) OutputNames() []string { return nil }
+func (b *ModbusTCPSink) Inputs() int           { return len(b.Registers) }
+func (b *ModbusTCPSink) Outputs() int          { return 0 }
+func (b *ModbusTCPSink) Step(in, out []float64) bool {
+	if b.client == nil {
+		b.client = connect(b.Addr)
+	}
+	for i, a := range b.Registers {
+		if err := b.client.writeFloat(b.UnitID, a, in[i]); err != nil {
+			log.Printf("modbus sink: %v", err)
+			return false
+		}
+	}
+	return true
+}
+
+// Close releases the connection, the system calls it at the end of the simulation.
+func (b *ModbusTCPSink) Close() error {
+	if b.client == nil {
+		return nil
+	}
+	err := b.client.release()
+	b.client = nil
+	return err
+}
+
+func validate(name, addr string, registers []uint16) error {
+	if addr == "" {
+		return fmt.Errorf("%s: no address", name)
+	}
+	for _, a := range registers {
+		if a == math.MaxUint16 {
+			return fmt.Errorf("%s: register %d has no successor", name, a)
+		}
+	}
+	return nil
+}
+
+// names returns the port names r<address> for the registers.
+func names(registers []uint16) []string {
+	r := make([]string, len(registers))
+	for i, a := range registers {
+		r[i] = fmt.Sprintf("r%d", a)
+	}
+	return r
+}
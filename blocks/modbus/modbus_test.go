@@ -0,0 +1,148 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+)
+
+// server is a mock Modbus TCP server with a register map,
+// which supports read holding registers and write multiple registers.
+// It drops each connection after maxRequests, if it is positive.
+type server struct {
+	sync.Mutex
+	net.Listener
+	registers   map[uint16]uint16
+	maxRequests int
+	conns       int
+}
+
+func newServer(t *testing.T, maxRequests int) *server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{Listener: l, registers: make(map[uint16]uint16), maxRequests: maxRequests}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.Lock()
+			s.conns++
+			s.Unlock()
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *server) serve(c net.Conn) {
+	defer c.Close()
+	for n := 0; s.maxRequests <= 0 || n < s.maxRequests; n++ {
+		var h [7]byte
+		if _, err := io.ReadFull(c, h[:]); err != nil {
+			return
+		}
+		pdu := make([]byte, binary.BigEndian.Uint16(h[4:])-1)
+		if _, err := io.ReadFull(c, pdu); err != nil {
+			return
+		}
+		a, m := binary.BigEndian.Uint16(pdu[1:]), binary.BigEndian.Uint16(pdu[3:])
+		var r []byte
+		s.Lock()
+		switch pdu[0] {
+		case 3:
+			r = []byte{3, byte(2 * m)}
+			for i := uint16(0); i < m; i++ {
+				r = binary.BigEndian.AppendUint16(r, s.registers[a+i])
+			}
+		case 16:
+			for i := uint16(0); i < m; i++ {
+				s.registers[a+i] = binary.BigEndian.Uint16(pdu[6+2*i:])
+			}
+			r = pdu[:5]
+		default:
+			r = []byte{pdu[0] | 0x80, 1}
+		}
+		s.Unlock()
+		binary.BigEndian.PutUint16(h[4:], uint16(1+len(r)))
+		c.Write(append(h[:], r...))
+	}
+}
+
+func TestModbus(t *testing.T) {
+	srv := newServer(t, 0)
+	defer srv.Close()
+	addr := srv.Addr().String()
+
+	sink := &ModbusTCPSink{Addr: addr, UnitID: 1, Registers: []uint16{100, 102, 200}}
+	src := &ModbusTCPSource{Addr: addr, UnitID: 1, Registers: []uint16{200, 100, 102}}
+	for _, b := range []interface{ Validate() error }{sink, src} {
+		if err := b.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := make([]float64, 3)
+	for _, in := range [][]float64{
+		{1.5, -2.25, 0},
+		{-1e6, 3.75, -0.125},
+	} {
+		if !sink.Step(in, nil) {
+			t.Fatal("write failed")
+		}
+		if !src.Step(nil, out) {
+			t.Fatal("read failed")
+		}
+		if out[0] != in[2] || out[1] != in[0] || out[2] != in[1] {
+			t.Fatalf("got %v for %v", out, in)
+		}
+	}
+	// Registers hold the high word first.
+	srv.Lock()
+	defer srv.Unlock()
+	if r := srv.registers[100]; r != 0xc974 {
+		t.Errorf("high word of -1e6 is %#x", r)
+	}
+	if sink.client != src.client || srv.conns != 1 {
+		t.Errorf("connection is not shared: %d connections", srv.conns)
+	}
+	sink.Close()
+	src.Close()
+	if len(pool.clients) != 0 {
+		t.Error("pool is not empty")
+	}
+}
+
+func TestModbusReconnect(t *testing.T) {
+	srv := newServer(t, 2)
+	defer srv.Close()
+
+	sink := &ModbusTCPSink{Addr: srv.Addr().String(), Registers: []uint16{0}}
+	defer sink.Close()
+	for i := 0; i < 5; i++ {
+		if !sink.Step([]float64{float64(i)}, nil) {
+			t.Fatalf("step %d failed", i)
+		}
+	}
+	srv.Lock()
+	defer srv.Unlock()
+	if v := decode([2]uint16{srv.registers[0], srv.registers[1]}); v != 4 {
+		t.Errorf("got %v, want 4", v)
+	}
+	if srv.conns != 3 {
+		t.Errorf("got %d connections, want 3", srv.conns)
+	}
+}
+
+func TestModbusValidate(t *testing.T) {
+	if (&ModbusTCPSource{Registers: []uint16{1}}).Validate() == nil {
+		t.Error("expected an error for a missing address")
+	}
+	if (&ModbusTCPSink{Addr: "x", Registers: []uint16{65535}}).Validate() == nil {
+		t.Error("expected an error for the last register")
+	}
+}
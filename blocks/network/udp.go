@@ -0,0 +1,165 @@
+// Package network provides blocks which exchange signals over the network
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"time"
+
+	"github.com/ktye/loops"
+)
+
+// Magic is the first word of each frame, "LOOP" in little-endian byte order.
+const Magic uint32 = 0x504f4f4c
+
+// A frame is the little-endian encoding of the magic number, the number
+// of channels as uint32 and one float64 per channel.
+func encode(buf []byte, values []float64) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf[:0], Magic)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(values)))
+	for _, v := range values {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+	}
+	return buf
+}
+
+// decode reads a frame with n channels into values.
+func decode(frame []byte, values []float64) error {
+	n := len(values)
+	if len(frame) < 8 {
+		return fmt.Errorf("short frame: %d bytes", len(frame))
+	}
+	if m := binary.LittleEndian.Uint32(frame); m != Magic {
+		return fmt.Errorf("wrong magic number: %#x", m)
+	}
+	if c := binary.LittleEndian.Uint32(frame[4:]); c != uint32(n) || len(frame) != 8+8*n {
+		return fmt.Errorf("frame has %d channels and %d bytes, want %d channels", c, len(frame), n)
+	}
+	for i := range values {
+		values[i] = math.Float64frombits(binary.LittleEndian.Uint64(frame[8+8*i:]))
+	}
+	return nil
+}
+
+// UDPSink sends one frame with it's inputs per step to Addr.
+type UDPSink struct {
+	Addr        string
+	NumChannels int
+	conn        net.Conn
+	buf         []byte
+}
+
+func (b *UDPSink) Validate() error {
+	if b.Addr == "" {
+		return fmt.Errorf("udp sink: no address")
+	}
+	return nil
+}
+func (b *UDPSink) Reset()                { b.Close() }
+func (b *UDPSink) InputNames() []string  { return loops.PortNames("in", b.NumChannels) }
+func (b *UDPSink) OutputNames() []string { return nil }
+func (b *UDPSink) Inputs() int           { return b.NumChannels }
+func (b *UDPSink) Outputs() int          { return 0 }
+func (b *UDPSink) Step(in, out []float64) bool {
+	if b.conn == nil {
+		c, err := net.Dial("udp", b.Addr)
+		if err != nil {
+			log.Printf("udp sink: %v", err)
+			return false
+		}
+		b.conn = c
+	}
+	b.buf = encode(b.buf, in)
+	if _, err := b.conn.Write(b.buf); err != nil {
+		log.Printf("udp sink: %v", err)
+		return false
+	}
+	return true
+}
+
+// Close closes the connection, the system calls it at the end of the simulation.
+func (b *UDPSink) Close() error {
+	if b.conn == nil {
+		return nil
+	}
+	err := b.conn.Close()
+	b.conn = nil
+	return err
+}
+
+// UDPSource listens on Addr and outputs one received frame per step.
+// If no frame arrives within Timeout, or a frame is malformed,
+// the simulation ends. Without a Timeout it waits forever.
+type UDPSource struct {
+	Addr        string
+	NumChannels int
+	Timeout     time.Duration
+	conn        net.PacketConn
+	buf         []byte
+}
+
+func (b *UDPSource) Validate() error {
+	if b.Timeout < 0 {
+		return fmt.Errorf("udp source: negative timeout %v", b.Timeout)
+	}
+	return nil
+}
+func (b *UDPSource) Reset()                { b.Close() }
+func (b *UDPSource) InputNames() []string  { return nil }
+func (b *UDPSource) OutputNames() []string { return loops.PortNames("out", b.NumChannels) }
+func (b *UDPSource) Inputs() int           { return 0 }
+func (b *UDPSource) Outputs() int          { return b.NumChannels }
+func (b *UDPSource) Step(in, out []float64) bool {
+	if b.conn == nil {
+		if err := b.Listen(); err != nil {
+			log.Printf("udp source: %v", err)
+			return false
+		}
+	}
+	if b.Timeout > 0 {
+		b.conn.SetReadDeadline(time.Now().Add(b.Timeout))
+	}
+	n, _, err := b.conn.ReadFrom(b.buf)
+	if err == nil {
+		err = decode(b.buf[:n], out)
+	}
+	if err != nil {
+		log.Printf("udp source: %v", err)
+		return false
+	}
+	return true
+}
+
+// Listen opens the socket, which is otherwise done on the first step.
+// It can be called before the simulation, e.g. to find the port
+// with ListenAddr, if Addr has port 0.
+func (b *UDPSource) Listen() error {
+	c, err := net.ListenPacket("udp", b.Addr)
+	if err != nil {
+		return err
+	}
+	// One more byte than a valid frame detects frames which are too long.
+	b.conn, b.buf = c, make([]byte, 9+8*b.NumChannels)
+	return nil
+}
+
+// ListenAddr returns the address of the socket, or "" if it is not open.
+func (b *UDPSource) ListenAddr() string {
+	if b.conn == nil {
+		return ""
+	}
+	return b.conn.LocalAddr().String()
+}
+
+// Close closes the socket, the system calls it at the end of the simulation.
+func (b *UDPSource) Close() error {
+	if b.conn == nil {
+		return nil
+	}
+	err := b.conn.Close()
+	b.conn = nil
+	return err
+}
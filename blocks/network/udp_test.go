@@ -0,0 +1,61 @@
+package network
+
+import (
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestUDP sends frames from a UDPSink to a UDPSource over the loopback interface.
+func TestUDP(t *testing.T) {
+	src := &UDPSource{Addr: "127.0.0.1:0", NumChannels: 3, Timeout: time.Second}
+	if err := src.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	sink := &UDPSink{Addr: src.ListenAddr(), NumChannels: 3}
+	defer sink.Close()
+
+	out := make([]float64, 3)
+	for _, in := range [][]float64{
+		{1, 2, 3},
+		{-0.5, math.Pi, 1e-300},
+		{math.Inf(1), math.MaxFloat64, -0},
+	} {
+		if !sink.Step(in, nil) {
+			t.Fatal("send failed")
+		}
+		if !src.Step(nil, out) {
+			t.Fatal("receive failed")
+		}
+		for i := range in {
+			if math.Float64bits(out[i]) != math.Float64bits(in[i]) {
+				t.Fatalf("got %v, want %v", out, in)
+			}
+		}
+	}
+
+	// Frames with other channel counts or magic numbers end the simulation.
+	c, err := net.Dial("udp", src.ListenAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, frame := range [][]byte{
+		encode(nil, []float64{1, 2}),
+		append(encode(nil, []float64{1, 2, 3}), 0),
+		append([]byte{0, 0, 0, 0}, encode(nil, []float64{1, 2, 3})[4:]...),
+	} {
+		c.Write(frame)
+		if src.Step(nil, out) {
+			t.Errorf("expected an error for the frame %x", frame)
+		}
+	}
+
+	// Nothing is received within the timeout.
+	src.Timeout = 10 * time.Millisecond
+	if src.Step(nil, out) {
+		t.Error("expected a timeout")
+	}
+}
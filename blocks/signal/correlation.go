@@ -0,0 +1,81 @@
+package signal
+
+import (
+	"fmt"
+
+	"github.com/ktye/loops"
+)
+
+// CrossCorrelation estimates the cross-correlation of it's inputs x and y
+// R[l] = mean(x[n+l]*y[n]) for the lags l = -MaxLag..MaxLag, which are
+// the outputs 0..2*MaxLag. The mean is taken over the products within
+// a block of NumSamples steps.
+// After each block, the correlation is computed and held until the next
+// block is complete. Before the first one, the outputs are 0.
+type CrossCorrelation struct {
+	MaxLag     int
+	NumSamples int
+	x, y       []float64
+	r          []float64
+}
+
+func (b *CrossCorrelation) Validate() error {
+	if b.MaxLag < 0 {
+		return fmt.Errorf("cross correlation: negative max lag %d", b.MaxLag)
+	}
+	if b.NumSamples <= b.MaxLag {
+		return fmt.Errorf("cross correlation: %d samples are not more than the max lag %d", b.NumSamples, b.MaxLag)
+	}
+	return nil
+}
+func (b *CrossCorrelation) Reset()                { b.x, b.y, b.r = b.x[:0], b.y[:0], nil }
+func (b *CrossCorrelation) InputNames() []string  { return []string{"x", "y"} }
+func (b *CrossCorrelation) OutputNames() []string { return loops.PortNames("lag", 2*b.MaxLag+1) }
+func (b *CrossCorrelation) Inputs() int           { return 2 }
+func (b *CrossCorrelation) Outputs() int          { return 2*b.MaxLag + 1 }
+func (b *CrossCorrelation) Step(in, out []float64) bool {
+	b.x, b.y = append(b.x, in[0]), append(b.y, in[1])
+	if len(b.x) == b.NumSamples {
+		b.r = correlate(b.x, b.y, b.MaxLag, b.r)
+		b.x, b.y = b.x[:0], b.y[:0]
+	}
+	copy(out, b.r)
+	return true
+}
+
+// correlate returns the cross-correlation of x and y for the lags
+// -maxLag..maxLag in r, which is reused.
+func correlate(x, y []float64, maxLag int, r []float64) []float64 {
+	n := len(x)
+	if r == nil {
+		r = make([]float64, 2*maxLag+1)
+	}
+	for l := -maxLag; l <= maxLag; l++ {
+		s := 0.0
+		for k := max(0, -l); k < min(n, n-l); k++ {
+			s += x[k+l] * y[k]
+		}
+		r[l+maxLag] = s / float64(n-abs(l))
+	}
+	return r
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// AutoCorrelation is a CrossCorrelation of it's single input with itself.
+type AutoCorrelation struct {
+	CrossCorrelation
+	xy [2]float64
+}
+
+func (b *AutoCorrelation) InputNames() []string { return []string{"in"} }
+func (b *AutoCorrelation) Inputs() int          { return 1 }
+func (b *AutoCorrelation) Step(in, out []float64) bool {
+	b.xy = [2]float64{in[0], in[0]}
+	return b.CrossCorrelation.Step(b.xy[:], out)
+}
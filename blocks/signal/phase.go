@@ -0,0 +1,63 @@
+package signal
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ktye/loops"
+)
+
+// PhaseDetector estimates the instantaneous phase of a sinusoidal input
+// in radians. The quadrature signal is approximated by a first order
+// allpass filter, which shifts the phase by -90 degrees at NominalFreq:
+//
+//	y[n] = c*x[n] + x[n-1] - c*y[n-1],  c = (tan(pi*f*dt)-1)/(tan(pi*f*dt)+1)
+//
+// For x = sin(phi), the filter output is -cos(phi) and the phase is
+// atan2(x, -y). The output is unwrapped, so it increases continuously
+// at 2*pi*NominalFreq rad/s for an input at the nominal frequency.
+// Away from it, the quadrature is not exact and the phase ripples.
+type PhaseDetector struct {
+	NominalFreq float64
+	c           float64
+	x, y        float64 // previous input and filter output
+	phase       float64
+	started     bool
+	dt          float64
+}
+
+func (b *PhaseDetector) Validate() error {
+	if !(b.NominalFreq > 0) || math.IsInf(b.NominalFreq, 1) {
+		return fmt.Errorf("phase detector: nominal frequency %v must be positive and finite", b.NominalFreq)
+	}
+	return nil
+}
+func (b *PhaseDetector) SetDT(dt float64)      { b.dt = dt }
+func (b *PhaseDetector) Reset()                { b.x, b.y, b.phase, b.started = 0, 0, 0, false }
+func (b *PhaseDetector) InputNames() []string  { return []string{"in"} }
+func (b *PhaseDetector) OutputNames() []string { return []string{"phase"} }
+func (b *PhaseDetector) Inputs() int           { return 1 }
+func (b *PhaseDetector) Outputs() int          { return 1 }
+func (b *PhaseDetector) Step(in, out []float64) bool {
+	if !b.started {
+		dt := b.dt
+		if dt == 0 {
+			dt = loops.DefaultDT
+		}
+		t := math.Tan(math.Pi * b.NominalFreq * dt)
+		b.c = (t - 1) / (t + 1)
+	}
+	x := in[0]
+	y := b.c*x + b.x - b.c*b.y
+	b.x, b.y = x, y
+
+	p := math.Atan2(x, -y)
+	if b.started {
+		// Add the wrapped difference to the previous, unwrapped phase.
+		d := p - math.Remainder(b.phase, 2*math.Pi)
+		p = b.phase + math.Remainder(d, 2*math.Pi)
+	}
+	b.phase, b.started = p, true
+	out[0] = p
+	return true
+}
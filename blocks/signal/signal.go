@@ -0,0 +1,151 @@
+// Package signal provides blocks for signal analysis
+package signal
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	"github.com/ktye/loops"
+)
+
+// FFTSink records the last WindowSize samples of it's inputs.
+// After the simulation, Spectrum returns their discrete Fourier transform.
+// WindowSize must be a power of 2.
+// Window is "hann", "hamming" or "rectangular", which is the default.
+type FFTSink struct {
+	NumChannels int
+	WindowSize  int
+	Window      string
+	buf         [][]float64 // ring buffer per channel
+	pos, n      int
+	dt          float64
+}
+
+func (b *FFTSink) Validate() error {
+	if b.WindowSize < 2 || b.WindowSize&(b.WindowSize-1) != 0 {
+		return fmt.Errorf("fft sink: window size must be a power of 2: %d", b.WindowSize)
+	}
+	if _, err := window(b.Window, 2); err != nil {
+		return err
+	}
+	return nil
+}
+func (b *FFTSink) SetDT(dt float64)      { b.dt = dt }
+func (b *FFTSink) Reset()                { b.buf, b.pos, b.n = nil, 0, 0 }
+func (b *FFTSink) InputNames() []string  { return loops.PortNames("in", b.NumChannels) }
+func (b *FFTSink) OutputNames() []string { return nil }
+func (b *FFTSink) Inputs() int           { return b.NumChannels }
+func (b *FFTSink) Outputs() int          { return 0 }
+func (b *FFTSink) Step(in, out []float64) bool {
+	if b.buf == nil {
+		b.buf = make([][]float64, b.NumChannels)
+		for i := range b.buf {
+			b.buf[i] = make([]float64, b.WindowSize)
+		}
+	}
+	for i, v := range in {
+		b.buf[i][b.pos] = v
+	}
+	b.pos = (b.pos + 1) % b.WindowSize
+	b.n = min(b.n+1, b.WindowSize)
+	return true
+}
+
+// Spectrum returns the one-sided spectrum of each channel, the bins
+// 0 to WindowSize/2 of the transform of the windowed samples.
+// Bin k has the frequency k/(WindowSize*dt), see Frequencies.
+// If less than WindowSize samples have been recorded,
+// they are padded with zeros.
+func (b *FFTSink) Spectrum() [][]complex128 {
+	N := b.WindowSize
+	w, err := window(b.Window, N)
+	if err != nil {
+		return nil
+	}
+	r := make([][]complex128, b.NumChannels)
+	for i := range r {
+		x := make([]complex128, N)
+		for k := 0; k < b.n; k++ {
+			// oldest sample first
+			v := b.buf[i][(b.pos-b.n+k+N)%N]
+			x[k] = complex(v*w[k], 0)
+		}
+		fft(x)
+		r[i] = x[:N/2+1]
+	}
+	return r
+}
+
+// MagnitudeDB returns the magnitude of the spectrum in dB: 20*log10|X|.
+func (b *FFTSink) MagnitudeDB() [][]float64 {
+	s := b.Spectrum()
+	r := make([][]float64, len(s))
+	for i, x := range s {
+		r[i] = make([]float64, len(x))
+		for k, v := range x {
+			r[i][k] = 20 * math.Log10(cmplx.Abs(v))
+		}
+	}
+	return r
+}
+
+// Frequencies returns the frequency of each bin of Spectrum in Hz.
+func (b *FFTSink) Frequencies() []float64 {
+	dt := b.dt
+	if dt == 0 {
+		dt = loops.DefaultDT
+	}
+	f := make([]float64, b.WindowSize/2+1)
+	for k := range f {
+		f[k] = float64(k) / (float64(b.WindowSize) * dt)
+	}
+	return f
+}
+
+// window returns the window function of length n.
+func window(name string, n int) ([]float64, error) {
+	w := make([]float64, n)
+	for k := range w {
+		c := math.Cos(2 * math.Pi * float64(k) / float64(n-1))
+		switch name {
+		case "", "rectangular":
+			w[k] = 1
+		case "hann":
+			w[k] = 0.5 - 0.5*c
+		case "hamming":
+			w[k] = 0.54 - 0.46*c
+		default:
+			return nil, fmt.Errorf("fft sink: unknown window %q", name)
+		}
+	}
+	return w, nil
+}
+
+// fft transforms x in place with the radix-2 Cooley-Tukey algorithm.
+// The length of x must be a power of 2.
+func fft(x []complex128) {
+	n := len(x)
+	// Bit reversal permutation.
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], wk*x[start+k+size/2]
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				wk *= w
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package signal
+
+import (
+	"context"
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"github.com/ktye/loops"
+)
+
+// TestFFTSink records a 10 Hz sine and finds the peak of it's spectrum.
+func TestFFTSink(t *testing.T) {
+	for _, w := range []string{"rectangular", "hann", "hamming"} {
+		sink := &FFTSink{NumChannels: 1, WindowSize: 256, Window: w}
+		var s loops.System
+		s.DT = 0.001
+		s.Add(&loops.SineSource{Amplitude: 1, Frequency: 10}) // 0
+		s.Add(&loops.Stop{Time: 1})                           // 1
+		s.Add(sink)                                           // 2
+		s.Connect(0, 1, 0, 0)                                 // sine -> stop
+		s.Connect(1, 2, 0, 0)                                 // stop -> fft
+		if err := s.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		db := sink.MagnitudeDB()[0]
+		f := sink.Frequencies()
+		peak := 0
+		for k := range db {
+			if db[k] > db[peak] {
+				peak = k
+			}
+		}
+		if df := f[1] - f[0]; math.Abs(f[peak]-10) > df {
+			t.Errorf("%s: peak at %v Hz, bin width %v", w, f[peak], df)
+		}
+	}
+}
+
+// TestFFT compares with the direct DFT.
+func TestFFT(t *testing.T) {
+	x := make([]complex128, 16)
+	for k := range x {
+		x[k] = complex(math.Sin(float64(k)), float64(k%3))
+	}
+	want := make([]complex128, len(x))
+	for k := range want {
+		for j, v := range x {
+			want[k] += v * cmplx.Exp(complex(0, -2*math.Pi*float64(j*k)/16))
+		}
+	}
+	fft(x)
+	for k := range x {
+		if cmplx.Abs(x[k]-want[k]) > 1e-12 {
+			t.Fatalf("bin %d: got %v, want %v", k, x[k], want[k])
+		}
+	}
+
+	for _, b := range []FFTSink{{WindowSize: 100}, {WindowSize: 64, Window: "kaiser"}} {
+		if b.Validate() == nil {
+			t.Errorf("expected an error for %+v", b)
+		}
+	}
+}
+
+// TestAutoCorrelation checks that the autocorrelation of a sine is a cosine
+// and finds the delay of a noise signal with the cross-correlation.
+func TestAutoCorrelation(t *testing.T) {
+	const f, dt = 5.0, 0.001
+	ac := &AutoCorrelation{CrossCorrelation: CrossCorrelation{MaxLag: 100, NumSamples: 4000}}
+	var s loops.System
+	s.DT = dt
+	s.Add(&loops.SineSource{Amplitude: 2, Frequency: f}) // 0
+	s.Add(&loops.Stop{Time: 4.5})                        // 1
+	s.Add(ac)                                            // 2
+	s.Add(&loops.Recorder{NumChannels: 201})             // 3
+	s.Connect(0, 1, 0, 0)                                // sine -> stop
+	s.Connect(1, 2, 0, 0)                                // stop -> ac
+	for o := 0; o < 201; o++ {
+		s.Connect(2, 3, o, o) // ac -> rec
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rec := s.Block(3).(*loops.Recorder)
+	n := len(rec.Data[0])
+	if n < 4001 {
+		t.Fatalf("recorded %d steps", n)
+	}
+	for o := range rec.Data {
+		// The outputs are held: 0 before the 4000th step, then the result.
+		if r := rec.Data[o]; r[3998] != 0 || r[3999] != r[n-1] {
+			t.Fatalf("lag %d: not held: %v %v %v", o-100, r[3998], r[3999], r[n-1])
+		}
+		want := 2 * math.Cos(2*math.Pi*f*float64(o-100)*dt)
+		if got := rec.Data[o][n-1]; math.Abs(got-want) > 0.03 {
+			t.Errorf("lag %d: got %v, want %v", o-100, got, want)
+		}
+	}
+
+	// y is x delayed by 7 samples, the maximum is at lag -7.
+	cc := &CrossCorrelation{MaxLag: 10, NumSamples: 500}
+	if err := cc.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	noise := &loops.WhiteNoise{StdDev: 1, Seed: 1}
+	delay := &loops.Delay{Samples: 7}
+	x, y, r := make([]float64, 1), make([]float64, 1), make([]float64, 21)
+	for k := 0; k < 500; k++ {
+		noise.Step(nil, x)
+		delay.Step(x, y)
+		cc.Step([]float64{x[0], y[0]}, r)
+	}
+	peak := 0
+	for i := range r {
+		if r[i] > r[peak] {
+			peak = i
+		}
+	}
+	if peak != 10-7 || r[peak] < 0.8 {
+		t.Fatalf("peak at %d: %v", peak-10, r)
+	}
+
+	if (&CrossCorrelation{MaxLag: 10, NumSamples: 10}).Validate() == nil {
+		t.Error("expected an error for too few samples")
+	}
+}
+
+// TestPhaseDetector feeds a sine at the nominal frequency. After the filter
+// transient, the phase increases linearly at 2*pi*f rad/s.
+func TestPhaseDetector(t *testing.T) {
+	const f, dt = 10.0, 0.001
+	sine := &loops.SineSource{Amplitude: 3, Frequency: f}
+	b := &PhaseDetector{NominalFreq: f}
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	sine.SetDT(dt)
+	b.SetDT(dt)
+	x, p := make([]float64, 1), make([]float64, 1)
+	for k := 0; k < 1000; k++ {
+		sine.Step(nil, x)
+		b.Step(x, p)
+		// The phase of the input is 2*pi*f*t, with the time t = k*dt.
+		want := 2 * math.Pi * f * float64(k) * dt
+		if k >= 100 && math.Abs(p[0]-want) > 1e-3 {
+			t.Fatalf("step %d: phase %v, want %v", k, p[0], want)
+		}
+	}
+	if (&PhaseDetector{}).Validate() == nil {
+		t.Error("expected an error for a zero nominal frequency")
+	}
+}
@@ -0,0 +1,170 @@
+// Package web provides a sink block for loops, which streams
+// signals to a browser
+package web
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ktye/loops"
+)
+
+//go:embed index.html
+var static embed.FS
+
+// event is the data of one server-sent event.
+type event struct {
+	Time   float64   `json:"time"`
+	Values []float64 `json:"values"`
+}
+
+// HTTPSink serves it's inputs as server-sent events (SSE).
+//
+// On the first step it starts an HTTP server on Addr, e.g. ":8080".
+// The endpoint /stream sends one event per step, with a JSON object
+// {"time":t,"values":[...]}. The page at / shows a live chart of the data.
+// Events are dropped for clients which cannot keep up with the simulation.
+// The server is stopped by Close, which the system calls at the end of
+// the simulation.
+type HTTPSink struct {
+	Addr        string
+	NumChannels int
+	ln          net.Listener
+	srv         *http.Server
+	mu          sync.Mutex
+	clients     map[chan []byte]bool
+	done        chan struct{}
+	t, dt       float64
+}
+
+func (b *HTTPSink) Validate() error {
+	if b.NumChannels < 1 {
+		return fmt.Errorf("http sink: NumChannels must be positive: %d", b.NumChannels)
+	}
+	return nil
+}
+func (b *HTTPSink) SetDT(dt float64) { b.dt = dt }
+func (b *HTTPSink) Reset()           { b.Close(); b.t = 0 }
+func (b *HTTPSink) Inputs() int      { return b.NumChannels }
+func (b *HTTPSink) Outputs() int     { return 0 }
+func (b *HTTPSink) InputNames() []string {
+	r := make([]string, b.NumChannels)
+	for i := range r {
+		r[i] = fmt.Sprintf("in%d", i)
+	}
+	return r
+}
+func (b *HTTPSink) OutputNames() []string { return nil }
+func (b *HTTPSink) Step(in, out []float64) bool {
+	if b.srv == nil {
+		if err := b.start(); err != nil {
+			log.Printf("http sink: %v", err)
+			return false
+		}
+	}
+	data, err := json.Marshal(event{Time: b.t, Values: in})
+	if err != nil {
+		log.Printf("http sink: %v", err)
+		return false
+	}
+	b.mu.Lock()
+	for c := range b.clients {
+		select {
+		case c <- data:
+		default:
+		}
+	}
+	b.mu.Unlock()
+	dt := b.dt
+	if dt == 0 {
+		dt = loops.DefaultDT
+	}
+	b.t += dt
+	return true
+}
+
+// ListenAddr returns the address the server listens on,
+// which is known after the first step.
+func (b *HTTPSink) ListenAddr() string {
+	if b.ln == nil {
+		return ""
+	}
+	return b.ln.Addr().String()
+}
+
+// start starts the HTTP server in the background.
+func (b *HTTPSink) start() error {
+	ln, err := net.Listen("tcp", b.Addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServerFS(static))
+	mux.HandleFunc("/stream", b.stream)
+	b.ln, b.srv = ln, &http.Server{Handler: mux}
+	b.clients, b.done = make(map[chan []byte]bool), make(chan struct{})
+	go b.srv.Serve(ln)
+	return nil
+}
+
+// stream sends events to a client until it disconnects
+// or the server is closed.
+func (b *HTTPSink) stream(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	c := make(chan []byte, 64)
+	b.mu.Lock()
+	b.clients[c] = true
+	done := b.done
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, c)
+		b.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+	for {
+		select {
+		case data := <-c:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			f.Flush()
+		case <-r.Context().Done():
+			return
+		case <-done:
+			return
+		}
+	}
+}
+
+// Close ends all streams and stops the server.
+// Clients which do not disconnect within a second are dropped.
+func (b *HTTPSink) Close() error {
+	if b.srv == nil {
+		return nil
+	}
+	close(b.done)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := b.srv.Shutdown(ctx)
+	if err != nil {
+		err = b.srv.Close()
+	}
+	b.srv, b.ln = nil, nil
+	return err
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestHTTPSink receives the events of three steps.
+func TestHTTPSink(t *testing.T) {
+	b := HTTPSink{Addr: "127.0.0.1:0", NumChannels: 2}
+	defer b.Close()
+	if !b.Step([]float64{0, 0}, nil) {
+		t.Fatal("server does not start")
+	}
+	url := "http://" + b.ListenAddr()
+
+	resp, err := http.Get(url + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q", ct)
+	}
+	for k := 1; k <= 3; k++ {
+		b.Step([]float64{float64(k), -float64(k)}, nil)
+	}
+	r := bufio.NewReader(resp.Body)
+	for k := 1; k <= 3; k++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.ReadString('\n') // empty line after each event
+		var e event
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		if e.Time != float64(k)*0.01 || len(e.Values) != 2 || e.Values[0] != float64(k) || e.Values[1] != -float64(k) {
+			t.Fatalf("event %d: %+v", k, e)
+		}
+	}
+
+	page, err := http.Get(url + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer page.Body.Close()
+	if html, _ := io.ReadAll(page.Body); !strings.Contains(string(html), "EventSource") {
+		t.Fatalf("index page:\n%s", html)
+	}
+
+	// The stream ends, when the block is closed.
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+}
@@ -0,0 +1,1517 @@
+package loops
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestImpulseResponseCapture captures the impulse response of an integrator,
+// which is a step of height DefaultDT, and of a gain.
+func TestImpulseResponseCapture(t *testing.T) {
+	capture := ImpulseResponseCapture{N: 5}
+	inte := Integrate{}
+	stop := Stop{Time: 0.1}
+
+	var s System
+	s.Add(&capture)       // 0
+	s.Add(&inte)          // 1
+	s.Add(&stop)          // 2
+	s.Connect(0, 1, 0, 0) // capture -> inte
+	s.Connect(1, 2, 0, 0) // inte -> stop
+	s.Connect(2, 0, 0, 0) // stop -> capture
+	s.AddIC(-1, 0, 0)     // before the impulse, not recorded
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	r := capture.Response()
+	if len(r) != 5 {
+		t.Fatalf("got %d samples, want 5", len(r))
+	}
+	for k, v := range r {
+		if v != DefaultDT {
+			t.Fatalf("sample %d is %v, want %v", k, v, DefaultDT)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := capture.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 6 || lines[1] != "0,0.01" || lines[5] != "0.04,0.01" {
+		t.Fatalf("csv has %d lines, want 6 from t=0:\n%s", len(lines), buf.String())
+	}
+
+	// The response of a gain is the impulse itself at t=0.
+	capture = ImpulseResponseCapture{N: 3}
+	s = System{}
+	s.Add(&capture)       // 0
+	s.Add(Scale(2))       // 1
+	s.Connect(0, 1, 0, 0) // capture -> gain
+	s.Connect(1, 0, 0, 0) // gain -> capture
+	s.AddIC(-1, 0, 0)
+	for k := 0; k < 5; k++ {
+		if err := s.StepOnce(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r := capture.Response(); !equal(r, []float64{2, 0, 0}) {
+		t.Fatalf("gain: got %v, want [2 0 0]", r)
+	}
+}
+
+// TestValidator checks that invalid block parameters are reported by Start.
+func TestValidator(t *testing.T) {
+	var s System
+	s.Add(Source(1))
+	s.Add(&Stop{})
+	s.Add(&Recorder{NumChannels: 1})
+	s.Connect(0, 1, 0, 0)
+	s.Connect(1, 2, 0, 0)
+	err := s.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "block 1") {
+		t.Fatalf("expected an error for block 1, got %v", err)
+	}
+}
+
+// TestAlgebraicLoop checks that a loop without a delay block is rejected
+// and that an integrator breaks it.
+func TestAlgebraicLoop(t *testing.T) {
+	build := func(b Block) *System {
+		var s System
+		s.Add(Scale(0.5))     // 0
+		s.Add(Tee{})          // 1
+		s.Add(b)              // 2
+		s.Add(&Stop{Time: 1}) // 3
+		s.Add(&Recorder{NumChannels: 1})
+		s.Connect(0, 1, 0, 0) // scale -> tee
+		s.Connect(1, 2, 0, 0) // tee -> b
+		s.Connect(2, 0, 0, 0) // b -> scale
+		s.Connect(1, 3, 1, 0) // tee -> stop
+		s.Connect(3, 4, 0, 0) // stop -> rec
+		s.AddIC(1, 0, 0)
+		return &s
+	}
+	err := build(Scale(2)).Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "algebraic loop through blocks [0 1 2 0]") {
+		t.Fatalf("expected an algebraic loop error, got %v", err)
+	}
+	if err := build(&Integrate{}).Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// run calls the Step function of b n times with the constant input in
+// and returns the outputs of each step.
+func run(b Block, n int, in ...float64) [][]float64 {
+	var r [][]float64
+	for k := 0; k < n; k++ {
+		out := make([]float64, b.Outputs())
+		if !b.Step(in, out) {
+			break
+		}
+		r = append(r, out)
+	}
+	return r
+}
+
+// TestSources checks the periodic and ramp sources at selected times.
+func TestSources(t *testing.T) {
+	// 100 steps are one period at 1 Hz.
+	sine := run(&SineSource{Amplitude: 2, Frequency: 1, Phase: math.Pi / 2}, 100)
+	square := run(&SquareSource{Amplitude: 2, Frequency: 1}, 100)
+	saw := run(&SawtoothSource{Amplitude: 2, Frequency: 1}, 100)
+	ramp := run(&RampSource{Slope: 3, Offset: 1}, 100)
+
+	for _, c := range []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"sine(0)", sine[0][0], 2},
+		{"sine(0.25)", sine[25][0], 0},
+		{"sine(0.5)", sine[50][0], -2},
+		{"square(0.1)", square[10][0], 2},
+		{"square(0.6)", square[60][0], -2},
+		{"saw(0)", saw[0][0], -2},
+		{"saw(0.5)", saw[50][0], 0},
+		{"saw(0.75)", saw[75][0], 1},
+		{"ramp(0)", ramp[0][0], 1},
+		{"ramp(0.5)", ramp[50][0], 2.5},
+	} {
+		if math.Abs(c.got-c.want) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+// TestChirpSource counts the zero crossings of a chirp from 1 to 5 Hz
+// in the first and the second half of the sweep, and after it.
+func TestChirpSource(t *testing.T) {
+	y := run(&ChirpSource{Amplitude: 1, StartFreq: 1, EndFreq: 5, Duration: 2}, 300)
+	crossings := func(from, to int) int {
+		n := 0
+		for k := from + 1; k < to; k++ {
+			if (y[k-1][0] < 0) != (y[k][0] < 0) {
+				n++
+			}
+		}
+		return n
+	}
+	// The mean frequencies are 2 Hz and 4 Hz with 2 crossings per period,
+	// then 5 Hz.
+	for _, c := range []struct{ from, to, want int }{
+		{0, 100, 4},
+		{100, 200, 8},
+		{200, 300, 10},
+	} {
+		if n := crossings(c.from, c.to); n < c.want-1 || n > c.want+1 {
+			t.Errorf("steps %d..%d: %d zero crossings, want %d", c.from, c.to, n, c.want)
+		}
+	}
+	if (&ChirpSource{}).Validate() == nil {
+		t.Error("expected an error for a zero duration")
+	}
+}
+
+// TestImpulseSource integrates impulses for several time steps,
+// which gives a step of the height Amplitude.
+func TestImpulseSource(t *testing.T) {
+	for _, dt := range []float64{0.01, 0.001, 0.05} {
+		rec := Recorder{NumChannels: 1}
+		s := System{DT: dt}
+		s.Add(&ImpulseSource{Amplitude: 3, AtTime: 0.25}) // 0
+		s.Add(&Integrate{})                               // 1
+		s.Add(&Stop{Time: 1})                             // 2
+		s.Add(&rec)                                       // 3
+		s.Connect(0, 1, 0, 0)                             // impulse -> inte
+		s.Connect(1, 2, 0, 0)                             // inte -> stop
+		s.Connect(2, 3, 0, 0)                             // stop -> rec
+		if err := s.StartSync(); err != nil {
+			t.Fatal(err)
+		}
+		x := rec.Data[0]
+		for k, v := range x {
+			tk := float64(k) * dt
+			if tk < 0.25-dt && v != 0 || tk > 0.25+dt && math.Abs(v-3) > 1e-12 {
+				t.Fatalf("dt %v: x(%v) = %v", dt, tk, v)
+			}
+		}
+	}
+
+	// The impulse at 0 is the first output, it is emitted only once.
+	b := &ImpulseSource{Amplitude: 1}
+	b.SetDT(0.5)
+	for i := 0; i < 2; i++ {
+		if y := run(b, 3); y[0][0] != 2 || y[1][0] != 0 || y[2][0] != 0 {
+			t.Fatalf("round %d: got %v", i, y)
+		}
+		b.Reset()
+	}
+}
+
+// TestAddN checks the sum and product of five inputs.
+func TestAddN(t *testing.T) {
+	in := []float64{1, 2, 3, 4, 5}
+	if got := run(AddN{N: 5}, 1, in...); got[0][0] != 15 {
+		t.Fatalf("AddN: got %v, want 15", got[0][0])
+	}
+	if got := run(MulN{N: 5}, 1, in...); got[0][0] != 120 {
+		t.Fatalf("MulN: got %v, want 120", got[0][0])
+	}
+	if got := run(AddN{N: 3}, 1, in...); len(got) != 0 {
+		t.Fatal("AddN: expected false for a wrong number of inputs")
+	}
+	if err := (AddN{}).Validate(); err == nil {
+		t.Fatal("AddN: expected a validation error for N = 0")
+	}
+}
+
+// TestSubtract checks the Subtract and Negate blocks.
+func TestSubtract(t *testing.T) {
+	if got := run(Subtract{}, 1, 5, 3)[0][0]; got != 2 {
+		t.Fatalf("Subtract: got %v, want 2", got)
+	}
+	if got := run(Subtract{}, 1, 3, 5)[0][0]; got != -2 {
+		t.Fatalf("Subtract: got %v, want -2", got)
+	}
+	if got := run(Negate{}, 1, 3)[0][0]; got != -3 {
+		t.Fatalf("Negate: got %v, want -3", got)
+	}
+	if got := run(Negate{}, 1, -1.5)[0][0]; got != 1.5 {
+		t.Fatalf("Negate: got %v, want 1.5", got)
+	}
+}
+
+// TestAbs checks Abs, Sign, Sqrt and Square for negative,
+// zero and positive inputs.
+func TestAbs(t *testing.T) {
+	inf := math.Inf(1)
+	for _, c := range []struct {
+		b       Block
+		in, out float64
+	}{
+		{Abs{}, -2.5, 2.5},
+		{Abs{}, 0, 0},
+		{Abs{}, 3, 3},
+		{Abs{}, -inf, inf},
+		{Sign{}, -0x1p-1074, -1},
+		{Sign{}, 0, 0},
+		{Sign{}, 0x1p-1074, 1},
+		{Sign{}, -inf, -1},
+		{Sign{}, inf, 1},
+		{Sqrt{}, 0, 0},
+		{Sqrt{}, 2.25, 1.5},
+		{Sqrt{}, inf, inf},
+		{Square{}, -3, 9},
+		{Square{}, 0, 0},
+		{Square{}, 1.5, 2.25},
+		{Square{}, 1e200, inf},
+	} {
+		if got := run(c.b, 1, c.in)[0][0]; got != c.out {
+			t.Errorf("%T(%v): got %v, want %v", c.b, c.in, got, c.out)
+		}
+	}
+	if got := run(Sign{}, 1, math.NaN())[0][0]; !math.IsNaN(got) {
+		t.Errorf("Sign(NaN): got %v", got)
+	}
+	if got := run(Sqrt{}, 1, -0x1p-1074); len(got) != 0 {
+		t.Error("Sqrt: expected false for a negative input")
+	}
+}
+
+// TestMathFunc checks the MathFunc constructors for known values.
+func TestMathFunc(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		b       MathFunc
+		in, out float64
+	}{
+		{"sin", SinBlock(), math.Pi / 2, 1},
+		{"cos", CosBlock(), 0, 1},
+		{"cos", CosBlock(), math.Pi, -1},
+		{"exp", ExpBlock(), 1, math.E},
+		{"log", LogBlock(), math.E, 1},
+		{"log", LogBlock(), 1, 0},
+		{"pow", PowBlock(3), 2, 8},
+		{"pow", PowBlock(0.5), 9, 3},
+		{"abs", MathFunc{Fn: math.Abs}, -2, 2},
+		{"tanh", TanhBlock(), 0, 0},
+		{"tanh", TanhBlock(), math.Inf(1), 1},
+		{"tanh", TanhBlock(), math.Inf(-1), -1},
+		{"tanh", TanhBlock(), 1, (math.E*math.E - 1) / (math.E*math.E + 1)},
+		{"sigmoid", SigmoidBlock(2), 0, 0.5},
+		{"sigmoid", SigmoidBlock(2), math.Inf(1), 1},
+		{"sigmoid", SigmoidBlock(2), math.Inf(-1), 0},
+		{"sigmoid", SigmoidBlock(2), math.Log(3) / 2, 0.75},
+		{"sigmoid", SigmoidBlock(-1), 1000, 0},
+		{"heaviside", HeavisideBlock(0), 0, 1},
+		{"heaviside", HeavisideBlock(0), -0x1p-1074, 0},
+		{"heaviside", HeavisideBlock(1.5), 1.5, 1},
+		{"heaviside", HeavisideBlock(1.5), 1.4, 0},
+		{"heaviside", HeavisideBlock(1.5), math.Inf(1), 1},
+		{"heaviside", HeavisideBlock(1.5), math.Inf(-1), 0},
+	} {
+		if got := run(c.b, 1, c.in)[0][0]; math.Abs(got-c.out) > 1e-15 {
+			t.Errorf("%s(%v): got %v, want %v", c.name, c.in, got, c.out)
+		}
+	}
+	if err := (MathFunc{}).Validate(); err == nil {
+		t.Error("expected an error for a nil Fn")
+	}
+}
+
+// TestPolynomial compares Polynomial with x*x and Polynomial2D
+// with a direct evaluation for a sweep of inputs.
+func TestPolynomial(t *testing.T) {
+	sq := Polynomial{Coeffs: []float64{0, 0, 1}}
+	p2 := Polynomial2D{Coeffs: [][]float64{{1, 2}, {0, 0, 3}, {-1}}}
+	for x := -3.0; x <= 3; x += 0.25 {
+		if got := run(sq, 1, x)[0][0]; got != x*x {
+			t.Errorf("x² at %v: got %v", x, got)
+		}
+		y := 0.5 - x
+		want := 1 + 2*y + 3*x*y*y - x*x
+		if got := run(p2, 1, x, y)[0][0]; math.Abs(got-want) > 1e-12 {
+			t.Errorf("p(%v, %v): got %v, want %v", x, y, got, want)
+		}
+	}
+	if got := run(Polynomial{}, 1, 2)[0][0]; got != 0 {
+		t.Errorf("empty polynomial: got %v", got)
+	}
+}
+
+// naivePolynomial evaluates c with explicit powers for the benchmark.
+func naivePolynomial(c []float64, x float64) float64 {
+	r := 0.0
+	for i, v := range c {
+		r += v * math.Pow(x, float64(i))
+	}
+	return r
+}
+
+func BenchmarkPolynomial(b *testing.B) {
+	c := make([]float64, 50)
+	for i := range c {
+		c[i] = 1 / float64(i+1)
+	}
+	var r float64
+	b.Run("horner", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r += horner(c, 0.9)
+		}
+	})
+	b.Run("naive", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r += naivePolynomial(c, 0.9)
+		}
+	})
+	if math.Abs(horner(c, 0.9)-naivePolynomial(c, 0.9)) > 1e-12 {
+		b.Fatal("evaluations differ")
+	}
+}
+
+// TestDivide checks Multiply and Divide with identities,
+// zero and tiny denominators.
+func TestDivide(t *testing.T) {
+	for _, c := range []struct {
+		b         Block
+		x, y, out float64
+	}{
+		{Multiply{}, 3, 4, 12},
+		{Multiply{}, 3, 1, 3},
+		{Multiply{}, 3, 0, 0},
+		{Multiply{}, -2, 4, -8},
+		{Divide{}, 12, 4, 3},
+		{Divide{}, 3, 1, 3},
+		{Divide{}, 0, 5, 0},
+		{Divide{}, 1, 0x1p-1000, 0x1p1000},
+		{Divide{}, 1, -0x1p-1000, -0x1p1000},
+	} {
+		if got := run(c.b, 1, c.x, c.y)[0][0]; got != c.out {
+			t.Errorf("%T(%v, %v): got %v, want %v", c.b, c.x, c.y, got, c.out)
+		}
+	}
+	if got := run(Divide{}, 1, 1, 1e-320)[0][0]; !math.IsInf(got, 1) {
+		t.Errorf("Divide(1, 1e-320): got %v, want +Inf", got)
+	}
+	if got := run(Divide{}, 1, 1, 0); len(got) != 0 {
+		t.Error("Divide: expected false for a division by zero")
+	}
+}
+
+// TestSaturation checks the saturation and dead band blocks
+// at and beyond their limits.
+func TestSaturation(t *testing.T) {
+	sat := Saturation{Min: -1, Max: 2}
+	db := Deadband{Width: 1}
+	for _, c := range []struct {
+		b       Block
+		in, out float64
+	}{
+		{sat, 0, 0},
+		{sat, 1.5, 1.5},
+		{sat, 2, 2},
+		{sat, 2.1, 2},
+		{sat, 100, 2},
+		{sat, -1, -1},
+		{sat, -1.1, -1},
+		{db, 0, 0},
+		{db, 0.3, 0},
+		{db, -0.5, 0},
+		{db, 0.5, 0},
+		{db, 0.75, 0.25},
+		{db, -2, -1.5},
+	} {
+		if got := run(c.b, 1, c.in)[0][0]; got != c.out {
+			t.Errorf("%T(%v): got %v, want %v", c.b, c.in, got, c.out)
+		}
+	}
+	if (Saturation{Min: 1, Max: 1}).Validate() == nil {
+		t.Error("expected an error for min == max")
+	}
+	if (Deadband{Width: -1}).Validate() == nil {
+		t.Error("expected an error for a negative width")
+	}
+}
+
+// TestBiquadIIR compares the impulse response of a biquad
+// with the difference equation y[n] = x[n] + 0.5*x[n-1] - 0.25*y[n-2].
+func TestBiquadIIR(t *testing.T) {
+	// Scaling all coefficients by A0 gives the same filter.
+	for _, b := range []BiquadIIR{
+		{B0: 1, B1: 0.5, A0: 1, A2: 0.25},
+		{B0: 2, B1: 1, A0: 2, A2: 0.5},
+	} {
+		if err := b.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		want := []float64{1, 0.5, -0.25, -0.125, 0.0625, 0.03125}
+		out := make([]float64, 1)
+		for k, w := range want {
+			x := 0.0
+			if k == 0 {
+				x = 1
+			}
+			b.Step([]float64{x}, out)
+			if out[0] != w {
+				t.Fatalf("A0 %v, step %d: got %v, want %v", b.A0, k, out[0], w)
+			}
+		}
+	}
+	for _, a0 := range []float64{0, math.NaN(), math.Inf(-1)} {
+		if (&BiquadIIR{B0: 1, A0: a0}).Validate() == nil {
+			t.Errorf("expected an error for A0 = %v", a0)
+		}
+	}
+}
+
+// TestDelay delays a ramp by 10 samples, which is a lag of 0.1s.
+func TestDelay(t *testing.T) {
+	scope := Scope{NumChannels: 2}
+	var s System
+	s.Add(&RampSource{Slope: 1}) // 0
+	s.Add(Tee{})                 // 1
+	s.Add(&Delay{Samples: 10})   // 2
+	s.Add(&Stop{Time: 0.5})      // 3
+	s.Add(&scope)                // 4
+	s.Connect(0, 3, 0, 0)        // ramp -> stop
+	s.Connect(3, 1, 0, 0)        // stop -> tee
+	s.Connect(1, 4, 0, 0)        // tee -> scope
+	s.Connect(1, 2, 1, 0)        // tee -> delay
+	s.Connect(2, 4, 0, 1)        // delay -> scope
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	x, y := scope.Data[0], scope.Data[1]
+	if len(y) < 40 {
+		t.Fatalf("recorded %d samples", len(y))
+	}
+	for k := range y {
+		want := 0.0
+		if k >= 10 {
+			want = x[k] - 0.1
+		}
+		if math.Abs(y[k]-want) > 1e-9 {
+			t.Fatalf("step %d: got %v, want %v", k, y[k], want)
+		}
+	}
+}
+
+// TestDeadtime checks that the delay in seconds is independent
+// of the time step, and that a change of the time step keeps
+// the most recent inputs.
+func TestDeadtime(t *testing.T) {
+	for _, dt := range []float64{0.001, 0.003, 0.01, 0.02, 0.07} {
+		b := Deadtime{DelaySeconds: 0.25}
+		b.SetDT(dt)
+		out := make([]float64, 1)
+		k := 0
+		for ; out[0] == 0 && k < 1000; k++ {
+			b.Step([]float64{1}, out)
+		}
+		if delay := float64(k-1) * dt; math.Abs(delay-0.25) > dt {
+			t.Errorf("dt %v: delay %v", dt, delay)
+		}
+	}
+	if n := (&Deadtime{}).Samples(); n != 1 {
+		t.Errorf("zero delay: %d samples", n)
+	}
+
+	// Delay 0.05s = 5 steps of a 0.01s ramp 1, 2, ...
+	b := Deadtime{DelaySeconds: 0.05}
+	b.SetDT(0.01)
+	out := make([]float64, 1)
+	for k := 1; k <= 8; k++ {
+		b.Step([]float64{float64(k)}, out)
+	}
+	if out[0] != 3 {
+		t.Fatalf("got %v, want 3", out[0])
+	}
+	b.SetDT(0.02) // 3 samples, keeps 6, 7, 8
+	if n := b.Samples(); n != 3 {
+		t.Fatalf("got %d samples, want 3", n)
+	}
+	for k := 9; k <= 11; k++ {
+		b.Step([]float64{float64(k)}, out)
+		if want := float64(k - 3); out[0] != want {
+			t.Fatalf("after resize to 3: got %v, want %v", out[0], want)
+		}
+	}
+	b.SetDT(0.005) // 10 samples, 7 leading zeros before 9, 10, 11
+	for k := 0; k < 10; k++ {
+		b.Step([]float64{0}, out)
+		want := 0.0
+		if k >= 7 {
+			want = float64(k + 2)
+		}
+		if out[0] != want {
+			t.Fatalf("after resize to 10, step %d: got %v, want %v", k, out[0], want)
+		}
+	}
+}
+
+// TestTransferFunction compares the step response of a second order system
+// w²/(s²+2ζws+w²) with the closed form solution.
+func TestTransferFunction(t *testing.T) {
+	const w, z = 2.0, 0.5
+	tf := TransferFunction{Num: []float64{w * w}, Den: []float64{1, 2 * z * w, w * w}}
+	rec := Recorder{NumChannels: 1}
+	var s System
+	s.Add(Source(1))      // 0
+	s.Add(&Stop{Time: 5}) // 1
+	s.Add(&tf)            // 2
+	s.Add(&rec)           // 3
+	s.Connect(0, 1, 0, 0) // source -> stop
+	s.Connect(1, 2, 0, 0) // stop -> tf
+	s.Connect(2, 3, 0, 0) // tf -> rec
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	y := rec.Data[0]
+	if len(y) < 490 {
+		t.Fatalf("recorded %d samples", len(y))
+	}
+	wd := w * math.Sqrt(1-z*z)
+	for k, v := range y {
+		tk := float64(k+1) * DefaultDT
+		want := 1 - math.Exp(-z*w*tk)/math.Sqrt(1-z*z)*math.Sin(wd*tk+math.Acos(z))
+		if math.Abs(v-want) > 0.01 {
+			t.Fatalf("y(%v) = %v, want %v", tk, v, want)
+		}
+	}
+
+	for _, tf := range []*TransferFunction{
+		{Num: []float64{1, 0}, Den: []float64{1, 1}},
+		{Num: []float64{1}, Den: []float64{0, 1}},
+		{Num: []float64{1}},
+	} {
+		if tf.Validate() == nil {
+			t.Errorf("expected an error for %v/%v", tf.Num, tf.Den)
+		}
+	}
+}
+
+// TestLookup1D interpolates inside the table, at the breakpoints and outside.
+func TestLookup1D(t *testing.T) {
+	clamp := Lookup1D{X: []float64{0, 1, 3}, Y: []float64{0, 2, 0}}
+	extra := Lookup1D{X: clamp.X, Y: clamp.Y, Extrapolate: true}
+	for _, c := range []struct {
+		b       Lookup1D
+		in, out float64
+	}{
+		{clamp, 0.5, 1},
+		{clamp, 2, 1},
+		{clamp, 2.5, 0.5},
+		{clamp, 0, 0},
+		{clamp, 1, 2},
+		{clamp, 3, 0},
+		{clamp, -1, 0},
+		{clamp, 5, 0},
+		{extra, 0.5, 1},
+		{extra, 1, 2},
+		{extra, -1, -2},
+		{extra, 5, -2},
+	} {
+		if got := run(c.b, 1, c.in)[0][0]; got != c.out {
+			t.Errorf("%+v(%v): got %v, want %v", c.b, c.in, got, c.out)
+		}
+	}
+	for _, b := range []Lookup1D{
+		{X: []float64{0, 1}, Y: []float64{0}},
+		{X: []float64{0}, Y: []float64{0}},
+		{X: []float64{0, 1, 1}, Y: []float64{0, 1, 2}},
+	} {
+		if b.Validate() == nil {
+			t.Errorf("expected an error for %+v", b)
+		}
+	}
+}
+
+// TestRateLimiter slews a step up and down with different rates.
+func TestRateLimiter(t *testing.T) {
+	b := RateLimiter{Rising: 10, Falling: 5}
+	in := []float64{0, 1, 1, 1, 1, 0, 0, 0, 0, 0}
+	want := []float64{0, 0.1, 0.2, 0.3, 0.4, 0.35, 0.3, 0.25, 0.2, 0.15}
+	for k, x := range in {
+		out := make([]float64, 1)
+		b.Step([]float64{x}, out)
+		if math.Abs(out[0]-want[k]) > 1e-12 {
+			t.Fatalf("step %d: got %v, want %v", k, out[0], want[k])
+		}
+	}
+
+	// After a reset, the first value passes again.
+	b.Reset()
+	if got := run(&b, 2, 7); got[0][0] != 7 || got[1][0] != 7 {
+		t.Fatalf("got %v", got)
+	}
+	if (&RateLimiter{Rising: 1}).Validate() == nil {
+		t.Error("expected an error for a zero falling rate")
+	}
+}
+
+// TestMovingAverage checks the startup and steady state of
+// MovingAverage and MedianFilter.
+func TestMovingAverage(t *testing.T) {
+	in := []float64{3, 1, 2, 10, 4, 4, 4, 4}
+	for _, c := range []struct {
+		b    Block
+		want []float64
+	}{
+		{&MovingAverage{Window: 3}, []float64{3, 2, 2, 13.0 / 3, 16.0 / 3, 6, 4, 4}},
+		{&MedianFilter{Window: 3}, []float64{3, 2, 2, 2, 4, 4, 4, 4}},
+		{&MedianFilter{Window: 4}, []float64{3, 2, 2, 2.5, 3, 4, 4, 4}},
+	} {
+		out := make([]float64, 1)
+		for k, x := range in {
+			c.b.Step([]float64{x}, out)
+			if math.Abs(out[0]-c.want[k]) > 1e-12 {
+				t.Fatalf("%T step %d: got %v, want %v", c.b, k, out[0], c.want[k])
+			}
+		}
+	}
+	if (&MovingAverage{}).Validate() == nil || (&MedianFilter{}).Validate() == nil {
+		t.Error("expected an error for a zero window")
+	}
+}
+
+// TestConvolution checks that a unit impulse kernel passes the input through,
+// and that a box-car kernel is a moving average after the startup.
+func TestConvolution(t *testing.T) {
+	x := run(&WhiteNoise{StdDev: 1, Seed: 3}, 200)
+
+	pass := &Convolution{Kernel: []float64{1}}
+	delay := &Convolution{Kernel: []float64{0, 0, 1}}
+	box := &Convolution{Kernel: []float64{0.2, 0.2, 0.2, 0.2, 0.2}}
+	avg := &MovingAverage{Window: 5}
+	for k, v := range x {
+		if got := run(pass, 1, v...)[0][0]; got != v[0] {
+			t.Fatalf("step %d: pass-through gives %v, want %v", k, got, v[0])
+		}
+		want := 0.0
+		if k >= 2 {
+			want = x[k-2][0]
+		}
+		if got := run(delay, 1, v...)[0][0]; got != want {
+			t.Fatalf("step %d: delay gives %v, want %v", k, got, want)
+		}
+		y, m := run(box, 1, v...)[0][0], run(avg, 1, v...)[0][0]
+		if k >= 4 && math.Abs(y-m) > 1e-12 {
+			t.Fatalf("step %d: box-car gives %v, moving average %v", k, y, m)
+		}
+	}
+
+	box.Reset()
+	if got := run(box, 1, 5)[0][0]; got != 1 {
+		t.Fatalf("after reset: %v", got)
+	}
+	if (&Convolution{}).Validate() == nil {
+		t.Error("expected an error for an empty kernel")
+	}
+}
+
+// TestNoise checks that noise sources repeat their sequence for the same seed.
+func TestNoise(t *testing.T) {
+	w1 := run(&WhiteNoise{StdDev: 2, Seed: 42}, 1000)
+	w2 := run(&WhiteNoise{StdDev: 2, Seed: 42}, 1000)
+	w3 := run(&WhiteNoise{StdDev: 2, Seed: 43}, 1000)
+	var sum, sq float64
+	for k := range w1 {
+		if w1[k][0] != w2[k][0] {
+			t.Fatalf("step %d: %v != %v", k, w1[k][0], w2[k][0])
+		}
+		sum += w1[k][0]
+		sq += w1[k][0] * w1[k][0]
+	}
+	if w1[0][0] == w3[0][0] {
+		t.Fatal("different seeds give the same value")
+	}
+	if mean, std := sum/1000, math.Sqrt(sq/1000); math.Abs(mean) > 0.2 || math.Abs(std-2) > 0.2 {
+		t.Fatalf("mean %v, std %v", mean, std)
+	}
+
+	// A random seed can be reproduced.
+	u := UniformNoise{Min: -1, Max: 3}
+	u1 := run(&u, 100)
+	if u.UsedSeed() == 0 {
+		t.Fatal("no seed is used")
+	}
+	u2 := run(&UniformNoise{Min: -1, Max: 3, Seed: u.UsedSeed()}, 100)
+	for k := range u1 {
+		if v := u1[k][0]; v != u2[k][0] || v < -1 || v >= 3 {
+			t.Fatalf("step %d: %v %v", k, v, u2[k][0])
+		}
+	}
+
+	// The same sequence is repeated in a second simulation run.
+	rec := Recorder{NumChannels: 1}
+	var s System
+	s.Add(&WhiteNoise{StdDev: 1}) // 0
+	s.Add(&Stop{Time: 0.5})       // 1
+	s.Add(&rec)                   // 2
+	s.Connect(0, 1, 0, 0)         // noise -> stop
+	s.Connect(1, 2, 0, 0)         // stop -> rec
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	first := rec.Data[0]
+	s.Reset()
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(first, rec.Data[0]) {
+		t.Fatalf("second run differs:\n%v\n%v", first, rec.Data[0])
+	}
+}
+
+// chiSquared returns the chi-squared statistic of the samples x
+// for the bins, which are separated at edges and have the probabilities p.
+func chiSquared(x []float64, edges, p []float64) float64 {
+	count := make([]float64, len(p))
+	for _, v := range x {
+		count[sort.SearchFloat64s(edges, v)]++
+	}
+	var chi2 float64
+	for i := range count {
+		e := p[i] * float64(len(x))
+		chi2 += (count[i] - e) * (count[i] - e) / e
+	}
+	return chi2
+}
+
+// TestRandom checks the distributions of UniformRandom and GaussianRandom
+// with a chi-squared test for 10 bins.
+func TestRandom(t *testing.T) {
+	const n = 10000
+	const chi2max = 27.88 // 9 degrees of freedom, p = 0.001
+
+	samples := func(b Block) []float64 {
+		x := make([]float64, n)
+		for k, v := range run(b, n) {
+			x[k] = v[0]
+		}
+		return x
+	}
+
+	u := UniformRandom{Min: 2, Max: 7, Seed: 1}
+	edges, p := make([]float64, 9), make([]float64, 10)
+	for i := range p {
+		p[i] = 0.1
+		if i < 9 {
+			edges[i] = 2 + 0.5*float64(i+1)
+		}
+	}
+	if chi2 := chiSquared(samples(&u), edges, p); chi2 > chi2max {
+		t.Errorf("uniform: chi2 = %v", chi2)
+	}
+	u.Reset()
+	if !equal(samples(&u), samples(&UniformNoise{Min: 2, Max: 7, Seed: 1})) {
+		t.Error("UniformRandom differs from UniformNoise")
+	}
+
+	g := GaussianRandom{Mean: 1, StdDev: 3, Seed: 1}
+	x := samples(&g)
+	cdf := func(z float64) float64 { return 0.5 * (1 + math.Erf(z/math.Sqrt2)) }
+	last := 0.0
+	for i := range p {
+		z := math.Inf(1)
+		if i < 9 {
+			z = -2 + 0.5*float64(i)
+			edges[i] = 1 + 3*z
+		}
+		p[i], last = cdf(z)-last, cdf(z)
+	}
+	if chi2 := chiSquared(x, edges, p); chi2 > chi2max {
+		t.Errorf("gaussian: chi2 = %v", chi2)
+	}
+
+	// Reset repeats the sequence.
+	g.Reset()
+	if y := samples(&g); !equal(x, y) {
+		t.Error("gaussian: reset does not repeat the sequence")
+	}
+
+	// Box-Muller maps u1 = 1 to the origin and keeps the angle of u2.
+	if z0, z1 := boxMuller(1, 0.25); z0 != 0 || z1 != 0 {
+		t.Errorf("boxMuller(1, 0.25) = %v, %v", z0, z1)
+	}
+	if z0, z1 := boxMuller(math.Exp(-0.5), 0.25); math.Abs(z0) > 1e-15 || math.Abs(z1-1) > 1e-15 {
+		t.Errorf("boxMuller(exp(-1/2), 0.25) = %v, %v", z0, z1)
+	}
+}
+
+// TestPRBS checks the period of the shift registers and that the
+// circular autocorrelation over one period is an impulse.
+func TestPRBS(t *testing.T) {
+	maxLength := 20
+	if testing.Short() {
+		maxLength = 12
+	}
+	for n := 1; n <= maxLength; n++ {
+		b := PRBSSource{Length: n, Amplitude: 1}
+		out := make([]float64, 1)
+		b.Step(nil, out)
+		first, period := b.state, 1
+		for ; ; period++ {
+			b.Step(nil, out)
+			if b.state == first {
+				break
+			}
+		}
+		if period != 1<<n-1 {
+			t.Errorf("length %d: period %d, want %d", n, period, 1<<n-1)
+		}
+	}
+
+	const n = 127
+	x := make([]float64, n)
+	for k, v := range run(&PRBSSource{Length: 7, Seed: 0x55, Amplitude: 2}, n) {
+		x[k] = v[0]
+	}
+	for lag := 0; lag < n; lag++ {
+		r := 0.0
+		for k := range x {
+			r += x[k] * x[(k+lag)%n]
+		}
+		want := -4.0
+		if lag == 0 {
+			want = 4 * n
+		}
+		if r != want {
+			t.Fatalf("autocorrelation at lag %d: %v, want %v", lag, r, want)
+		}
+	}
+
+	for _, l := range []int{0, 32} {
+		if (&PRBSSource{Length: l}).Validate() == nil {
+			t.Errorf("length %d: expected an error", l)
+		}
+	}
+}
+
+// TestTeeN fans out a ramp to five recorders.
+func TestTeeN(t *testing.T) {
+	var s System
+	s.Add(&RampSource{Slope: 1}) // 0
+	s.Add(&Stop{Time: 0.5})      // 1
+	s.Add(TeeN{N: 5})            // 2
+	s.Connect(0, 1, 0, 0)        // ramp -> stop
+	s.Connect(1, 2, 0, 0)        // stop -> tee
+	recs := make([]Recorder, 5)
+	for k := range recs {
+		recs[k].NumChannels = 1
+		s.Add(&recs[k])
+		s.Connect(2, 3+k, k, 0)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The recorders may differ by the last sample.
+	x := recs[0].Data[0]
+	for k, r := range recs {
+		y := r.Data[0]
+		if n := min(len(x), len(y)); n < 45 || !equal(x[:n], y[:n]) {
+			t.Fatalf("recorder %d: %v, recorder 0: %v", k, y, x)
+		}
+	}
+	if got, want := run(TeeN{N: 2}, 1, 3), run(Tee{}, 1, 3); !equal(got[0], want[0]) {
+		t.Fatalf("TeeN{2}: %v, Tee: %v", got, want)
+	}
+	if (TeeN{}).Validate() == nil {
+		t.Fatal("expected an error for N = 0")
+	}
+}
+
+// TestZeroCrossing detects the crossings of a 1 Hz sine within 2.2s.
+func TestZeroCrossing(t *testing.T) {
+	// Sample the sine at 0.005 + k*dt, such that no sample is zero.
+	sine := run(&SineSource{Amplitude: 1, Frequency: 1, Phase: 2 * math.Pi * 0.005}, 220)
+	for _, c := range []struct {
+		rising, falling bool
+		want            []float64
+	}{
+		{true, false, []float64{1, 2}},
+		{false, true, []float64{0.5, 1.5}},
+		{true, true, []float64{0.5, 1, 1.5, 2}},
+	} {
+		calls := 0
+		b := ZeroCrossing{Rising: c.rising, Falling: c.falling, Callback: func(float64) { calls++ }}
+		for _, y := range sine {
+			out := make([]float64, 1)
+			b.Step(y, out)
+			if out[0] != y[0] {
+				t.Fatalf("output %v, input %v", out[0], y[0])
+			}
+		}
+		if len(b.Events) != len(c.want) || calls != len(c.want) {
+			t.Fatalf("%+v: events %v, %d calls, want %v", c, b.Events, calls, c.want)
+		}
+		for k, e := range b.Events {
+			// The sample times are shifted by -0.005.
+			if want := c.want[k] - 0.005; math.Abs(e-want) > 1e-4 {
+				t.Fatalf("event %d at %v, want %v", k, e, want)
+			}
+		}
+	}
+}
+
+// TestSampleAndHold samples a 1 Hz sine at 10 Hz with a time step of 1ms.
+func TestSampleAndHold(t *testing.T) {
+	rec := Recorder{NumChannels: 2}
+	var s System
+	s.DT = 0.001
+	s.Add(&SineSource{Amplitude: 1, Frequency: 1}) // 0
+	s.Add(&Stop{Time: 1})                          // 1
+	s.Add(Tee{})                                   // 2
+	s.Add(&SampleAndHold{SampleRate: 10})          // 3
+	s.Add(&rec)                                    // 4
+	s.Connect(0, 1, 0, 0)                          // sine -> stop
+	s.Connect(1, 2, 0, 0)                          // stop -> tee
+	s.Connect(2, 3, 0, 0)                          // tee -> sah
+	s.Connect(2, 4, 1, 0)                          // tee -> rec
+	s.Connect(3, 4, 0, 1)                          // sah -> rec
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	x, y := rec.Data[0], rec.Data[1]
+	if len(y) < 990 {
+		t.Fatalf("recorded %d samples", len(y))
+	}
+	// Each stair is 100 steps long and starts at a sample of the input.
+	stairs := 0
+	for k := range y {
+		if k%100 == 0 {
+			stairs++
+			if y[k] != x[k] {
+				t.Fatalf("step %d: sampled %v, input is %v", k, y[k], x[k])
+			}
+		} else if y[k] != y[k-1] {
+			t.Fatalf("step %d: output changes from %v to %v", k, y[k-1], y[k])
+		}
+	}
+	if stairs != 10 {
+		t.Fatalf("got %d stairs, want 10", stairs)
+	}
+}
+
+// TestSwitch checks Switch at the threshold and MultiSwitch for every index.
+func TestSwitch(t *testing.T) {
+	sw := Switch{Threshold: 0.5}
+	for _, c := range []struct{ control, out float64 }{
+		{0, 2},
+		{0.49, 2},
+		{0.5, 1},
+		{3, 1},
+	} {
+		if got := run(sw, 1, 1, 2, c.control)[0][0]; got != c.out {
+			t.Errorf("Switch(%v): got %v, want %v", c.control, got, c.out)
+		}
+	}
+
+	ms := MultiSwitch{N: 4}
+	for _, c := range []struct{ sel, out float64 }{
+		{0, 10},
+		{1, 11},
+		{2, 12},
+		{3, 13},
+		{2.2, 12},
+		{-1, 10},
+		{7, 13},
+	} {
+		if got := run(ms, 1, 10, 11, 12, 13, c.sel)[0][0]; got != c.out {
+			t.Errorf("MultiSwitch(%v): got %v, want %v", c.sel, got, c.out)
+		}
+	}
+	if (MultiSwitch{}).Validate() == nil {
+		t.Error("expected an error for N = 0")
+	}
+}
+
+// TestRelay drives a relay with a triangle wave between -1 and 1.
+// The output is a square wave, which switches 0.25 periods after
+// the zero crossings of the input.
+func TestRelay(t *testing.T) {
+	b := Relay{OnThreshold: 0.5, OffThreshold: -0.5, OnValue: 1, OffValue: -1}
+	out := make([]float64, 1)
+	for k := 0; k < 80; k++ {
+		// The triangle has a period of 40 steps and starts at 0, rising.
+		p := k % 40
+		var x float64
+		switch {
+		case p <= 10:
+			x = float64(p) / 10
+		case p < 30:
+			x = 2 - float64(p)/10
+		default:
+			x = float64(p)/10 - 4
+		}
+		b.Step([]float64{x}, out)
+		// On from a quarter period after the rising to a quarter after the falling crossing.
+		want := -1.0
+		if p >= 5 && p < 25 {
+			want = 1
+		}
+		if out[0] != want {
+			t.Fatalf("step %d: input %v, output %v, want %v", k, x, out[0], want)
+		}
+	}
+	if (&Relay{OnThreshold: 0, OffThreshold: 1}).Validate() == nil {
+		t.Error("expected an error for on < off threshold")
+	}
+}
+
+// TestFrequencyMeter measures a 10 Hz sine over windows of 0.5 s.
+// The first window misses the crossing at t=0, which has no previous sample,
+// so the measurements are checked from the second window on.
+func TestFrequencyMeter(t *testing.T) {
+	sine := run(&SineSource{Amplitude: 1, Frequency: 10}, 300)
+	b := FrequencyMeter{Window: 0.5}
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	out := make([]float64, 1)
+	for k, y := range sine {
+		b.Step(y, out)
+		if k < 49 && out[0] != 0 {
+			t.Fatalf("step %d: output %v during the first window", k, out[0])
+		} else if k >= 99 && math.Abs(out[0]-10) > 0.5 {
+			t.Fatalf("step %d: frequency %v, want 10±0.5", k, out[0])
+		}
+	}
+	if (&FrequencyMeter{}).Validate() == nil {
+		t.Error("expected an error for a zero window")
+	}
+}
+
+// TestHysteresis drives the backlash with a sine wave and reconstructs
+// the parallelogram from the input/output pairs: rising edges lie on
+// y = x-Width/2, falling edges on y = x+Width/2, and the horizontal edges,
+// where the output is held, are at ±(Amplitude-Width/2).
+func TestHysteresis(t *testing.T) {
+	const a, w = 2.0, 1.0
+	b := Hysteresis{Width: w}
+	out := make([]float64, 1)
+	var x, y, ymin, ymax float64
+	var rising, falling, held int
+	for k := 1; k <= 400; k++ {
+		xk := a * math.Sin(2*math.Pi*float64(k)/100)
+		b.Step([]float64{xk}, out)
+		switch {
+		case out[0] == y:
+			held++
+			if math.Abs(xk-y) > w/2+1e-12 {
+				t.Fatalf("step %d: held output %v is outside the dead zone around %v", k, y, xk)
+			}
+		case xk > x:
+			rising++
+			if math.Abs(out[0]-(xk-w/2)) > 1e-12 {
+				t.Fatalf("step %d: rising input %v, output %v, want %v", k, xk, out[0], xk-w/2)
+			}
+		default:
+			falling++
+			if math.Abs(out[0]-(xk+w/2)) > 1e-12 {
+				t.Fatalf("step %d: falling input %v, output %v, want %v", k, xk, out[0], xk+w/2)
+			}
+		}
+		x, y = xk, out[0]
+		ymin, ymax = math.Min(ymin, y), math.Max(ymax, y)
+	}
+	if rising == 0 || falling == 0 || held == 0 {
+		t.Errorf("rising %d, falling %d, held %d: expected all edges of the parallelogram", rising, falling, held)
+	}
+	if want := a - w/2; math.Abs(ymax-want) > 1e-12 || math.Abs(ymin+want) > 1e-12 {
+		t.Errorf("output range [%v, %v], want ±%v", ymin, ymax, want)
+	}
+	if (&Hysteresis{Width: -1}).Validate() == nil {
+		t.Error("expected an error for a negative width")
+	}
+}
+
+// TestQuantizer rounds positive and negative values on and between
+// the quantization boundaries.
+func TestQuantizer(t *testing.T) {
+	q, tr, ce := Quantizer{Resolution: 0.1}, Truncate{Resolution: 0.1}, Ceiling{Resolution: 0.1}
+	q2 := Quantizer{Resolution: 2.5}
+	for _, c := range []struct {
+		b       Block
+		in, out float64
+	}{
+		{q, 0.3, 0.3},
+		{q, 0.34, 0.3},
+		{q, 0.35, 0.4},
+		{q, -0.34, -0.3},
+		{q, -0.35, -0.4},
+		{tr, 0.3, 0.3},
+		{tr, 0.1 + 0.2, 0.3},
+		{tr, 0.39, 0.3},
+		{tr, -0.3, -0.3},
+		{tr, -0.31, -0.4},
+		{ce, 0.3, 0.3},
+		{ce, 0.31, 0.4},
+		{ce, -0.39, -0.3},
+		{q2, 3.75, 5},
+		{q2, -3.7, -2.5},
+		{q2, 7.5, 7.5},
+	} {
+		if got := run(c.b, 1, c.in)[0][0]; got != c.out {
+			t.Errorf("%T(%v): got %v, want %v", c.b, c.in, got, c.out)
+		}
+	}
+	if (Quantizer{}).Validate() == nil {
+		t.Error("expected an error for a zero resolution")
+	}
+}
+
+// TestGainScheduler sweeps the scheduling variable across the breakpoints.
+// The gain is continuous: it matches the breakpoints from both sides.
+func TestGainScheduler(t *testing.T) {
+	b := GainScheduler{Schedules: []float64{0, 1, 3}, Gains: []float64{1, 3, -1}}
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	out := make([]float64, 1)
+	gain := func(s float64) float64 {
+		b.Step([]float64{2, s}, out)
+		return out[0] / 2
+	}
+	for _, tc := range []struct{ s, gain float64 }{
+		{-1, 1}, {0, 1}, {0.5, 2}, {1, 3}, {2, 1}, {3, -1}, {4, -1},
+	} {
+		if g := gain(tc.s); math.Abs(g-tc.gain) > 1e-12 {
+			t.Errorf("schedule %v: gain %v, want %v", tc.s, g, tc.gain)
+		}
+	}
+	const eps = 1e-9
+	for _, x := range b.Schedules {
+		if lo, hi := gain(x-eps), gain(x+eps); math.Abs(hi-lo) > 1e-8 {
+			t.Errorf("gain jumps at %v: %v %v", x, lo, hi)
+		}
+	}
+
+	for _, b := range []GainScheduler{
+		{Schedules: []float64{0, 1}, Gains: []float64{1}},
+		{Schedules: []float64{0}, Gains: []float64{1}},
+		{Schedules: []float64{0, 0}, Gains: []float64{1, 2}},
+	} {
+		if b.Validate() == nil {
+			t.Errorf("expected an error for %+v", b)
+		}
+	}
+}
+
+// TestGainSchedulerPID interpolates the parameter sets and checks
+// the integral and derivative terms.
+func TestGainSchedulerPID(t *testing.T) {
+	b := GainSchedulerPID{
+		Schedules: []float64{0, 2},
+		Gains:     []PIDGains{{P: 1, I: 0, D: 0}, {P: 3, I: 2, D: 4}},
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	b.SetDT(0.5)
+	out := make([]float64, 1)
+	// P=2, I=1, D=2 at schedule 1.
+	for k, tc := range []struct{ e, u float64 }{
+		{1, 2*1 + 1*0.5 + 0},
+		{3, 2*3 + 1*2 + 2*4},
+		{3, 2*3 + 1*3.5 + 0},
+	} {
+		b.Step([]float64{tc.e, 1}, out)
+		if math.Abs(out[0]-tc.u) > 1e-12 {
+			t.Errorf("step %d: got %v, want %v", k, out[0], tc.u)
+		}
+	}
+	b.Reset()
+	b.Step([]float64{1, 0}, out)
+	if out[0] != 1 {
+		t.Errorf("after reset: got %v, want 1", out[0])
+	}
+}
+
+// TestLookup2D interpolates at the grid points, the cell centers and outside.
+func TestLookup2D(t *testing.T) {
+	b := Lookup2D{
+		X: []float64{0, 1, 3},
+		Y: []float64{0, 2},
+		Z: [][]float64{{0, 4}, {2, 10}, {6, 0}},
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ x, y, z float64 }{
+		{0, 0, 0},
+		{1, 2, 10},
+		{3, 0, 6},
+		{0.5, 1, (0 + 4 + 2 + 10) / 4.0},
+		{2, 1, (2 + 10 + 6 + 0) / 4.0},
+		{0.5, 0, 1},
+		{-1, -1, 0},
+		{5, 5, 0},
+		{5, 1, 3},
+	} {
+		if got := run(b, 1, c.x, c.y)[0][0]; got != c.z {
+			t.Errorf("(%v, %v): got %v, want %v", c.x, c.y, got, c.z)
+		}
+	}
+	for _, b := range []Lookup2D{
+		{X: []float64{0, 1}, Y: []float64{0, 1}, Z: [][]float64{{0, 1}}},
+		{X: []float64{0, 1}, Y: []float64{0, 1}, Z: [][]float64{{0, 1}, {0}}},
+		{X: []float64{0, 0}, Y: []float64{0, 1}, Z: [][]float64{{0, 1}, {0, 1}}},
+		{X: []float64{0, 1}, Y: []float64{0}, Z: [][]float64{{0}, {0}}},
+	} {
+		if b.Validate() == nil {
+			t.Errorf("expected an error for %+v", b)
+		}
+	}
+}
+
+// TestDerivative differentiates a ramp and a sine.
+func TestDerivative(t *testing.T) {
+	ramp := run(&RampSource{Slope: 3}, 10)
+	d := Derivative{}
+	for k, x := range ramp {
+		out := run(&d, 1, x...)[0][0]
+		if want := 3.0; k > 0 && math.Abs(out-want) > 1e-9 {
+			t.Fatalf("ramp step %d: got %v, want %v", k, out, want)
+		}
+	}
+
+	// The backward difference is the derivative half a step earlier.
+	sine := run(&SineSource{Amplitude: 1, Frequency: 1}, 200)
+	d.Reset()
+	for k, x := range sine {
+		out := run(&d, 1, x...)[0][0]
+		tk := (float64(k) - 0.5) * DefaultDT
+		if want := 2 * math.Pi * math.Cos(2*math.Pi*tk); k > 0 && math.Abs(out-want) > 0.01 {
+			t.Fatalf("sine step %d: got %v, want %v", k, out, want)
+		}
+	}
+
+	// A filtered derivative approaches the slope of the ramp.
+	f := Derivative{FilterCoeff: 0.1}
+	var out float64
+	for _, x := range ramp {
+		out = run(&f, 1, x...)[0][0]
+	}
+	if out <= 0 || out >= 3 {
+		t.Fatalf("filtered derivative is %v", out)
+	}
+	if (&Derivative{FilterCoeff: 2}).Validate() == nil {
+		t.Fatal("expected an error for a filter coefficient > 1")
+	}
+}
+
+// TestMinMax tracks the extremes of a sine with amplitude 2 for one period.
+func TestMinMax(t *testing.T) {
+	var lo RunningMin
+	var hi RunningMax
+	var mm MinMax
+	for k, x := range run(&SineSource{Amplitude: 2, Frequency: 1}, 100) {
+		a, b, c := run(&lo, 1, x...)[0], run(&hi, 1, x...)[0], run(&mm, 1, x...)[0]
+		if a[0] != c[0] || b[0] != c[1] {
+			t.Fatalf("step %d: MinMax %v, RunningMin %v, RunningMax %v", k, c, a, b)
+		}
+		if k == 0 && (a[0] != 0 || b[0] != 0) {
+			t.Fatalf("extremes start at %v %v", a, b)
+		}
+	}
+	if math.Abs(lo.Value+2) > 1e-9 || math.Abs(hi.Value-2) > 1e-9 {
+		t.Fatalf("min %v, max %v, want -2, 2", lo.Value, hi.Value)
+	}
+	if mm.Min.Value != lo.Value || mm.Max.Value != hi.Value {
+		t.Fatalf("MinMax: %v %v", mm.Min.Value, mm.Max.Value)
+	}
+}
+
+// TestStatistics runs normal and uniform random values through Statistics.
+func TestStatistics(t *testing.T) {
+	for _, tc := range []struct {
+		src            Block
+		mean, variance float64
+	}{
+		{&GaussianRandom{Mean: 3, StdDev: 2, Seed: 1}, 3, 4},
+		{&UniformRandom{Min: -1, Max: 3, Seed: 2}, 1, 16.0 / 12},
+	} {
+		var b Statistics
+		x, out := make([]float64, 1), make([]float64, 4)
+		for k := 0; k < 100000; k++ {
+			tc.src.Step(nil, x)
+			b.Step(x, out)
+		}
+		if out[0] != x[0] || out[1] != b.Mean || out[2] != b.Variance || out[3] != b.StdDev {
+			t.Fatalf("outputs %v, fields %v %v %v", out, b.Mean, b.Variance, b.StdDev)
+		}
+		if math.Abs(b.Mean-tc.mean) > 0.01*math.Abs(tc.mean) || math.Abs(b.Variance-tc.variance) > 0.01*tc.variance ||
+			math.Abs(b.StdDev-math.Sqrt(tc.variance)) > 0.01*math.Sqrt(tc.variance) {
+			t.Errorf("%T: mean %v, variance %v, stddev %v, want %v %v", tc.src, b.Mean, b.Variance, b.StdDev, tc.mean, tc.variance)
+		}
+		b.Reset()
+		b.Step([]float64{5}, out)
+		if out[1] != 5 || out[2] != 0 {
+			t.Errorf("after reset: %v", out)
+		}
+	}
+}
+
+// TestCSVSource replays two columns of testdata/signals.csv.
+func TestCSVSource(t *testing.T) {
+	src := CSVSource{Filename: "testdata/signals.csv", Columns: []int{2, 1}, Header: true}
+	rec := Recorder{NumChannels: 2}
+	var s System
+	s.Add(&src)           // 0
+	s.Add(&rec)           // 1
+	s.Connect(0, 1, 0, 0) // csv -> rec
+	s.Connect(0, 1, 1, 1) // csv -> rec
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(rec.Data[0], []float64{10, 20, 30.5, 40}) || !equal(rec.Data[1], []float64{1, 2, 3, -4}) {
+		t.Fatalf("got %v", rec.Data)
+	}
+
+	for _, b := range []*CSVSource{
+		{Filename: "testdata/signals.csv", Columns: []int{1}},
+		{Filename: "testdata/signals.csv", Columns: []int{3}, Header: true},
+		{Filename: "testdata/nosuchfile.csv", Columns: []int{0}},
+	} {
+		if len(run(b, 1)) != 0 {
+			t.Errorf("expected an error for %+v", b)
+		}
+	}
+}
+
+// TestScopeReplay records ode1 and plays it back into another scope.
+func TestScopeReplay(t *testing.T) {
+	recorded := Scope{NumChannels: 1}
+	if err := ode1System(&recorded, &Stop{Time: 1}).Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	replay := ScopeReplay{Recorded: &recorded}
+	replayed := Scope{NumChannels: 1}
+	var s System
+	s.Add(&replay)        // 0
+	s.Add(&replayed)      // 1
+	s.Connect(0, 1, 0, 0) // replay -> scope
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded.Data[0]) < 90 || !equal(replayed.Data[0], recorded.Data[0]) {
+		t.Fatalf("got %d samples, recorded %d", len(replayed.Data[0]), len(recorded.Data[0]))
+	}
+
+	if (&ScopeReplay{}).Validate() == nil {
+		t.Fatal("expected an error for a nil scope")
+	}
+}
+
+// TestCSVSink writes a ramp to a CSV file and reads it back with CSVSource.
+func TestCSVSink(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ramp.csv")
+	sink := CSVSink{Filename: file, NumChannels: 1, FlushEvery: 7}
+	rec := Recorder{NumChannels: 1}
+	var s System
+	s.Add(Source(1))        // 0
+	s.Add(&Stop{Time: 0.5}) // 1
+	s.Add(&Integrate{})     // 2
+	s.Add(Tee{})            // 3
+	s.Add(&sink)            // 4
+	s.Add(&rec)             // 5
+	s.Connect(0, 1, 0, 0)   // ones -> stop
+	s.Connect(1, 2, 0, 0)   // stop -> inte
+	s.Connect(2, 3, 0, 0)   // inte -> tee
+	s.Connect(3, 4, 0, 0)   // tee -> sink
+	s.Connect(3, 5, 1, 0)   // tee -> rec
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if sink.f != nil {
+		t.Fatal("file is not closed")
+	}
+
+	src := CSVSource{Filename: file, Columns: []int{0, 1}, Header: true}
+	replay := Recorder{NumChannels: 2}
+	var r System
+	r.Add(&src)           // 0
+	r.Add(&replay)        // 1
+	r.Connect(0, 1, 0, 0) // csv -> replay
+	r.Connect(0, 1, 1, 1) // csv -> replay
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The last value may only reach one of sink and rec.
+	x, y := rec.Data[0], replay.Data[1]
+	n := min(len(x), len(y))
+	if n < 40 || !equal(x[:n], y[:n]) || max(len(x), len(y))-n > 1 {
+		t.Fatalf("replayed %v, recorded %v", y, x)
+	}
+	for k, tk := range replay.Data[0] {
+		if math.Abs(tk-float64(k)*DefaultDT) > 1e-9 {
+			t.Fatalf("t[%d] = %v", k, tk)
+		}
+	}
+
+	// Rows are flushed before the file is closed.
+	b := CSVSink{Filename: file, NumChannels: 2, FlushEvery: 2}
+	run(&b, 3, 1, 2)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "t,y0,y1\n0,1,2\n0.01,1,2\n" {
+		t.Fatalf("got %q", got)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCallbackSink detects the time, when a ramp crosses a threshold.
+func TestCallbackSink(t *testing.T) {
+	crossed := -1.0
+	sink := CallbackSink{NumChannels: 1, Fn: func(t float64, values []float64) {
+		if crossed < 0 && values[0] >= 0.75 {
+			crossed = t
+		}
+	}}
+	var s System
+	s.Add(&RampSource{Slope: 2}) // 0
+	s.Add(&Stop{Time: 1})        // 1
+	s.Add(&sink)                 // 2
+	s.Connect(0, 1, 0, 0)        // ramp -> stop
+	s.Connect(1, 2, 0, 0)        // stop -> sink
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(crossed-0.375) > DefaultDT {
+		t.Fatalf("crossed at %v, want 0.375", crossed)
+	}
+
+	// A panic ends the simulation.
+	calls := 0
+	p := CallbackSink{NumChannels: 2, Fn: func(t float64, values []float64) {
+		if calls++; calls == 3 {
+			panic("boom")
+		}
+	}}
+	var r System
+	r.Add(Source(1))      // 0
+	r.Add(Source(2))      // 1
+	r.Add(&p)             // 2
+	r.Connect(0, 2, 0, 0) // one -> sink
+	r.Connect(1, 2, 0, 1) // two -> sink
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("%d calls", calls)
+	}
+}
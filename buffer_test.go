@@ -0,0 +1,61 @@
+package loops
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// discard is a terminal block which ignores it's input.
+type discard struct{}
+
+func (b discard) Inputs() int                 { return 1 }
+func (b discard) Outputs() int                { return 0 }
+func (b discard) Step(in, out []float64) bool { return true }
+
+// BenchmarkChain runs a chain of ten blocks for b.N steps
+// with different channel buffer sizes.
+func BenchmarkChain(b *testing.B) {
+	for _, size := range []int{0, 1, 8, 64} {
+		b.Run(fmt.Sprintf("buf%d", size), func(b *testing.B) {
+			var s System
+			s.Add(Source(1)) // 0
+			for k := 1; k <= 7; k++ {
+				s.Add(Scale(1))
+			}
+			s.Add(&Stop{Time: (float64(b.N) + 0.5) * DefaultDT}) // 8
+			s.Add(discard{})                                     // 9
+			for k := 0; k < 9; k++ {
+				s.ConnectBuffered(k, k+1, 0, 0, size)
+			}
+			b.ResetTimer()
+			if err := s.Start(context.Background()); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestConnectBuffered mixes buffered and unbuffered connections.
+func TestConnectBuffered(t *testing.T) {
+	var rec = Recorder{NumChannels: 1}
+	var s System
+	s.Add(Source(2))      // 0
+	s.Add(Scale(3))       // 1
+	s.Add(&Stop{Time: 1}) // 2
+	s.Add(&rec)           // 3
+	s.ConnectBuffered(0, 1, 0, 0, 4)
+	s.Connect(1, 2, 0, 0)
+	s.ConnectBuffered(2, 3, 0, 0, 16)
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Data[0]) == 0 {
+		t.Fatal("nothing recorded")
+	}
+	for _, v := range rec.Data[0] {
+		if v != 6 {
+			t.Fatalf("got %v, want 6", v)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Package builder constructs a loops.System by block and port names.
+package builder
+
+import (
+	"fmt"
+
+	"github.com/ktye/loops"
+)
+
+// A SystemBuilder collects named blocks, connections and initial conditions
+// and creates the System with Build.
+// The methods return the builder, so that calls can be chained:
+//
+//	b := new(builder.SystemBuilder).
+//		Block("inte", &loops.Integrate{State: 1}).
+//		Block("neg", loops.Scale(-1)).
+//		Connect("inte", "out", "neg", "in").
+//		IC("inte", "in", -1)
+//	s, err := b.Build()
+//
+// Errors are not reported by the chained calls, but by Build.
+type SystemBuilder struct {
+	names       []string
+	blocks      []loops.Block
+	connections []connection
+	initials    []initial
+}
+
+type connection struct {
+	src, srcPort, dst, dstPort string
+}
+
+type initial struct {
+	dst, port string
+	value     float64
+}
+
+// Block adds the block b with the given name.
+func (b *SystemBuilder) Block(name string, block loops.Block) *SystemBuilder {
+	b.names = append(b.names, name)
+	b.blocks = append(b.blocks, block)
+	return b
+}
+
+// Connect connects the output port srcPort of the block src
+// to the input port dstPort of the block dst.
+func (b *SystemBuilder) Connect(src, srcPort, dst, dstPort string) *SystemBuilder {
+	b.connections = append(b.connections, connection{src, srcPort, dst, dstPort})
+	return b
+}
+
+// IC adds the initial condition value to the input port of the block dst.
+func (b *SystemBuilder) IC(dst, port string, value float64) *SystemBuilder {
+	b.initials = append(b.initials, initial{dst, port, value})
+	return b
+}
+
+// Build returns the system with all blocks, connections and initial conditions.
+// It fails, if a block name is used twice or a block or port does not exist.
+// The block indexes in the system follow the order of the calls to Block.
+func (b *SystemBuilder) Build() (*loops.System, error) {
+	var s loops.System
+	for k, name := range b.names {
+		if err := s.AddNamed(name, b.blocks[k]); err != nil {
+			return nil, fmt.Errorf("builder: %v", err)
+		}
+	}
+	for _, c := range b.connections {
+		if err := s.ConnectNamed(c.src, c.srcPort, c.dst, c.dstPort); err != nil {
+			return nil, fmt.Errorf("builder: %v", err)
+		}
+	}
+	for _, ic := range b.initials {
+		block, k, ok := s.BlockByName(ic.dst)
+		if !ok {
+			return nil, fmt.Errorf("builder: unknown block %q", ic.dst)
+		}
+		i := index(inputNames(block), ic.port)
+		if i < 0 {
+			return nil, fmt.Errorf("builder: block %q has no input %q, it has %q", ic.dst, ic.port, inputNames(block))
+		}
+		s.AddIC(ic.value, k, i)
+	}
+	return &s, nil
+}
+
+// inputNames returns the input port names of b, which are in0, in1, ...
+// if b is not a NamedBlock, like in ConnectNamed.
+func inputNames(b loops.Block) []string {
+	if n, ok := b.(loops.NamedBlock); ok {
+		return n.InputNames()
+	}
+	names := make([]string, b.Inputs())
+	for i := range names {
+		names[i] = fmt.Sprintf("in%d", i)
+	}
+	return names
+}
+
+func index(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
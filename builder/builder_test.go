@@ -0,0 +1,66 @@
+package builder
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ktye/loops"
+)
+
+// TestOde1 builds the 1st order system of the README by names:
+// x' = -x with x0 = 1.
+func TestOde1(t *testing.T) {
+	scope := &loops.Scope{NumChannels: 1}
+	s, err := new(SystemBuilder).
+		Block("inte", &loops.Integrate{State: 1}).
+		Block("scope", scope).
+		Block("neg", loops.Scale(-1)).
+		Block("add", loops.Add{}).
+		Block("tee", loops.Tee{}).
+		Block("zeros", loops.Source(0)).
+		Block("stop", &loops.Stop{Time: 1}).
+		Connect("inte", "out", "tee", "in").
+		Connect("tee", "out0", "scope", "in0").
+		Connect("tee", "out1", "neg", "in").
+		Connect("neg", "out", "add", "in1").
+		Connect("zeros", "out", "stop", "in").
+		Connect("stop", "out", "add", "in0").
+		Connect("add", "out", "inte", "in").
+		IC("add", "in1", -1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	x := scope.Data[0]
+	if len(x) < 90 {
+		t.Fatalf("got %d samples", len(x))
+	}
+	for k, v := range x {
+		if want := math.Pow(1-loops.DefaultDT, float64(k+1)); math.Abs(v-want) > 1e-12 {
+			t.Fatalf("x[%d] = %v, want %v", k, v, want)
+		}
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	testCases := []struct {
+		b   *SystemBuilder
+		err string
+	}{
+		{new(SystemBuilder).Block("a", loops.Tee{}).Block("a", loops.Tee{}), `"a" is already used`},
+		{new(SystemBuilder).Block("a", loops.Tee{}).Connect("a", "out0", "b", "in"), `unknown block "b"`},
+		{new(SystemBuilder).Block("a", loops.Tee{}).Connect("a", "out", "a", "in"), `no output "out"`},
+		{new(SystemBuilder).Block("a", loops.Tee{}).IC("b", "in", 1), `unknown block "b"`},
+		{new(SystemBuilder).Block("a", loops.Tee{}).IC("a", "in0", 1), `no input "in0"`},
+	}
+	for _, tc := range testCases {
+		if _, err := tc.b.Build(); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("got %v, want %s", err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package loops
+
+import (
+	"context"
+	"testing"
+)
+
+// clockReader records the time of the system clock on every step.
+type clockReader struct {
+	clock *Clock
+	t     []float64
+}
+
+func (b *clockReader) SetClock(c *Clock) { b.clock = c }
+func (b *clockReader) Inputs() int       { return 1 }
+func (b *clockReader) Outputs() int      { return 0 }
+func (b *clockReader) Step(in, out []float64) bool {
+	b.t = append(b.t, b.clock.T())
+	return true
+}
+
+// TestClock compares the time of all blocks at every step.
+// The time step is changed between the runs.
+func TestClock(t *testing.T) {
+	var callback []float64
+	sink := &CallbackSink{NumChannels: 1, Fn: func(t float64, _ []float64) { callback = append(callback, t) }}
+	scope := &Scope{NumChannels: 1}
+	reader := &clockReader{}
+	var s System
+	s.Add(Source(0))      // 0
+	s.Add(&Stop{Time: 1}) // 1
+	s.Add(Tee{})          // 2
+	s.Add(sink)           // 3
+	s.Add(Tee{})          // 4
+	s.Add(scope)          // 5
+	s.Add(reader)         // 6
+	s.Connect(0, 1, 0, 0) // zeros -> stop
+	s.Connect(1, 2, 0, 0) // stop -> tee
+	s.Connect(2, 3, 0, 0) // tee -> sink
+	s.Connect(2, 4, 1, 0) // tee -> tee
+	s.Connect(4, 5, 0, 0) // tee -> scope
+	s.Connect(4, 6, 1, 0) // tee -> reader
+	if reader.clock != s.Clock() {
+		t.Fatal("the clock has not been set on Add")
+	}
+
+	for _, dt := range []float64{0.01, 0.025, 0.01} {
+		s.DT = dt
+		s.Reset()
+		callback, reader.t = nil, nil
+		if err := s.StartSync(); err != nil {
+			t.Fatal(err)
+		}
+		n := int(1/dt + 0.5)
+		if len(scope.Time) != n-1 || len(callback) != n-1 || len(reader.t) != n-1 {
+			t.Fatalf("dt %v: got %d, %d, %d steps, want %d", dt, len(scope.Time), len(callback), len(reader.t), n-1)
+		}
+		for k := range scope.Time {
+			want := float64(k) * dt
+			if scope.Time[k] != want || callback[k] != want || reader.t[k] != want {
+				t.Fatalf("dt %v step %d: scope %v, callback %v, clock %v, want %v", dt, k, scope.Time[k], callback[k], reader.t[k], want)
+			}
+		}
+		if got := s.Clock().Steps(); got != int64(n) {
+			t.Fatalf("dt %v: clock has %d steps, want %d", dt, got, n)
+		}
+	}
+
+	// With Start, the blocks are stepped concurrently, but each of them
+	// still reports the time of it's own steps.
+	s.Reset()
+	callback = nil
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for k := range min(len(callback), len(scope.Time)) {
+		if want := float64(k) * s.DT; scope.Time[k] != want || callback[k] != want {
+			t.Fatalf("start step %d: scope %v, callback %v, want %v", k, scope.Time[k], callback[k], want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package loops
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+)
+
+// A Cloner is a block which can copy itself.
+// It is an optional interface, which is used by System.Clone.
+// Blocks with func fields, which are not copied by encoding/gob,
+// implement it.
+type Cloner interface {
+	Clone() Block
+}
+
+// Clone returns a copy of the system with the same blocks, connections
+// and initial conditions, which can be started independently.
+// It is meant for parameter sweeps: the blocks of the copy can be changed
+// without affecting the original.
+//
+// Blocks are copied by their Clone method, if they implement Cloner,
+// sub-systems by System.Clone and other blocks with an encoding/gob round-trip
+// of their exported fields. Blocks without exported fields are copied by value,
+// or newly allocated if they are pointers.
+// Registered Stop blocks are registered with the copy.
+// Spies are not copied.
+func (s *System) Clone() (*System, error) {
+	c := &System{
+		In:               make([]chan float64, len(s.In)),
+		Out:              make([]chan float64, len(s.Out)),
+		DT:               s.DT,
+		Registry:         s.Registry,
+		profiling:        s.profiling,
+		ProgressFunc:     s.ProgressFunc,
+		ProgressInterval: s.ProgressInterval,
+		Watchdog:         s.Watchdog,
+	}
+	for k, b := range s.blocks {
+		nb, err := cloneBlock(b.Block)
+		if err != nil {
+			return nil, fmt.Errorf("clone: block %d (%s): %v", k, typeName(b.Block), err)
+		}
+		c.Add(nb)
+		for _, stop := range s.stops {
+			if stop == b.Block {
+				c.RegisterStop(nb.(*Stop))
+			}
+		}
+	}
+	if s.names != nil {
+		c.names = make(map[string]int)
+		for name, k := range s.names {
+			c.names[name] = k
+		}
+	}
+	for _, con := range s.connections {
+		c.connections = append(c.connections, con)
+		c.connect(con)
+	}
+	c.initials = append([]IC(nil), s.initials...)
+	return c, nil
+}
+
+// cloneBlock returns a copy of b.
+func cloneBlock(b Block) (Block, error) {
+	switch v := b.(type) {
+	case *cascade:
+		return v.clone()
+	case Cloner:
+		return v.Clone(), nil
+	case *System:
+		return v.Clone()
+	}
+	t := reflect.TypeOf(b)
+	if t.Kind() == reflect.Ptr && !hasExportedFields(t.Elem()) {
+		return reflect.New(t.Elem()).Interface().(Block), nil
+	} else if !hasExportedFields(t) {
+		return b, nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(b); err != nil {
+		return nil, err
+	}
+	p := reflect.New(t)
+	if err := gob.NewDecoder(&buf).DecodeValue(p); err != nil {
+		return nil, err
+	}
+	return p.Elem().Interface().(Block), nil
+}
+
+// hasExportedFields returns true, if t is not a struct,
+// or a struct with at least one exported field.
+func hasExportedFields(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package loops
+
+import (
+	"context"
+	"testing"
+)
+
+// TestClone runs the 1st order system and a clone with a different
+// stop time.
+func TestClone(t *testing.T) {
+	stopped := 0
+	s := ode1System(&Recorder{NumChannels: 1}, &Stop{Time: 1, Callbacks: []func(){func() { stopped++ }}})
+	c, err := s.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Block(6).(*Stop).Time = 0.5
+	if tEnd := s.Block(6).(*Stop).Time; tEnd != 1 {
+		t.Fatalf("original stop time changed to %v", tEnd)
+	}
+	if c.Block(1) == s.Block(1) {
+		t.Fatal("the recorder is not copied")
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	x, y := s.Block(1).(*Recorder).Data[0], c.Block(1).(*Recorder).Data[0]
+	if len(x) < 90 || len(y) < 40 || len(y) > 50 {
+		t.Fatalf("recorded %d and %d samples", len(x), len(y))
+	}
+	for k := range y {
+		if y[k] != x[k] {
+			t.Fatalf("step %d: clone %v, original %v", k, y[k], x[k])
+		}
+	}
+	if stopped != 2 {
+		t.Fatalf("stop callback called %d times", stopped)
+	}
+
+	// Sub-systems are cloned.
+	var sub System
+	sub.AddInputPort(0)     // 0
+	sub.Add(&Integrate{})   // 1
+	sub.AddOutputPort(0)    // 2
+	sub.Connect(0, 1, 0, 0) // in -> inte
+	sub.Connect(1, 2, 0, 0) // inte -> out
+	var p System
+	p.Add(&sub)
+	cp, err := p.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs := cp.Block(0).(*System); cs == &sub || cs.Inputs() != 1 || cs.Outputs() != 1 || cs.In[0] == sub.In[0] {
+		t.Fatal("sub-system is not cloned")
+	}
+
+	// Func fields can only be cloned by a Cloner.
+	var f System
+	f.Add(funcBlock{F: func() {}})
+	if _, err := f.Clone(); err == nil {
+		t.Fatal("expected an error for a func field")
+	}
+
+	// Cascades are cloned with their blocks, or fail like them.
+	gain, _ := NewCascade(&Integrate{}, Scale(2))
+	var g System
+	g.Add(gain)
+	cg, err := g.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc := cg.Block(0).(*cascade); cc == gain || cc.first == gain.(*cascade).first {
+		t.Fatal("cascade is not cloned")
+	}
+	fc, _ := NewCascade(Scale(1), funcBlock{F: func() {}})
+	var h System
+	h.Add(fc)
+	if _, err := h.Clone(); err == nil {
+		t.Fatal("expected an error for a cascade with a func field")
+	}
+}
+
+// funcBlock only has a func field, which cannot be copied by gob.
+type funcBlock struct {
+	discard
+	F func()
+}
@@ -0,0 +1,435 @@
+package loops
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// WriteDOT writes the system as a Graphviz DOT digraph.
+// Each block is a node labeled with it's index and type,
+// each connection an edge labeled with the output and input port.
+// Sub-systems are drawn as clusters.
+//
+// The nodes and edges carry the attributes which are read by
+// NewSystemFromDOT, so that a system without sub-systems can be rebuilt
+// from the output. Block parameters are stored as JSON, if the block can
+// be encoded.
+func (s *System) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	s.writeDOT(&b, "n", "\t")
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeDOT writes the nodes and edges of the system.
+// Node ids are prefixed with prefix.
+// Connections to the system's own inputs and outputs
+// are attached to the node named prefix.
+func (s *System) writeDOT(b *strings.Builder, prefix, indent string) {
+	for k, blk := range s.blocks {
+		id := fmt.Sprintf("%s%d", prefix, k)
+		name := typeName(blk.Block)
+		label := dotQuote(fmt.Sprintf("%d %s", k, name))
+		if sub, ok := any(blk.Block).(*System); ok {
+			fmt.Fprintf(b, "%ssubgraph cluster_%s {\n", indent, id)
+			fmt.Fprintf(b, "%s\tlabel=%s;\n", indent, label)
+			fmt.Fprintf(b, "%s\t%s [label=%s, shape=ellipse];\n", indent, id, dotQuote("ports"))
+			sub.writeDOT(b, id+"_", indent+"\t")
+			fmt.Fprintf(b, "%s}\n", indent)
+			continue
+		}
+		fmt.Fprintf(b, "%s%s [label=%s, type=%s", indent, id, label, name)
+		if p, err := json.Marshal(blk.Block); err == nil && string(p) != "{}" {
+			fmt.Fprintf(b, ", params=%s", dotQuote(string(p)))
+		}
+		b.WriteString("];\n")
+	}
+	for _, c := range s.connections {
+		src, dst := fmt.Sprintf("%s%d", prefix, c.src), fmt.Sprintf("%s%d", prefix, c.dst)
+		label := fmt.Sprintf("%d:%d", c.o, c.i)
+		if c.o < 0 {
+			src, label = strings.TrimSuffix(prefix, "_"), fmt.Sprintf("in %d:%d", -c.o-1, c.i)
+		}
+		if c.i < 0 {
+			dst, label = strings.TrimSuffix(prefix, "_"), fmt.Sprintf("%d:out %d", c.o, -c.i-1)
+		}
+		fmt.Fprintf(b, "%s%s -> %s [label=%s", indent, src, dst, dotQuote(label))
+		if c.vector {
+			b.WriteString(", style=bold, vector=true")
+		}
+		if c.o >= 0 && c.i >= 0 {
+			fmt.Fprintf(b, ", o=%d, i=%d", c.o, c.i)
+			for _, ic := range s.initials {
+				if ic.block == c.dst && ic.input == c.i {
+					fmt.Fprintf(b, ", ic=%s", strconv.FormatFloat(ic.value, 'g', -1, 64))
+					break
+				}
+			}
+		}
+		b.WriteString("];\n")
+	}
+}
+
+// NewSystemFromDOT builds a system from a Graphviz DOT description.
+//
+// Only a small subset of the DOT language is understood.
+// Every node statement adds a block, in the order of appearance.
+// The block is created by registry.NewBlock(type), where type is the node's
+// type attribute. If the node has a params attribute, it is decoded
+// as JSON into the new block.
+// Every edge statement connects two blocks. The attributes o and i
+// select the output port of the source and the input port of the
+// destination (both default to 0). An optional ic attribute adds an
+// initial condition to the destination port. Vector ports are
+// connected, if the edge has the attribute vector=true.
+//
+// Example:
+//
+//	digraph {
+//		inte [type=Integrate, params="{\"State\":1}"];
+//		neg  [type=Scale, params="-1"];
+//		inte -> neg [o=0, i=0];
+//	}
+//
+// Subgraphs are flattened; graph, node and edge defaults are ignored.
+func NewSystemFromDOT(dotSource string, registry Registry) (*System, error) {
+	toks, err := dotTokens(dotSource)
+	if err != nil {
+		return nil, err
+	}
+	p := dotParser{toks: toks, ids: make(map[string]int), registry: registry, sys: &System{}}
+	if err := p.graph(); err != nil {
+		return nil, err
+	}
+	return p.sys, nil
+}
+
+// dotParser is a recursive descent parser for the DOT subset
+// accepted by NewSystemFromDOT.
+type dotParser struct {
+	toks     []string
+	pos      int
+	ids      map[string]int // node id to block index
+	registry Registry
+	sys      *System
+}
+
+func (p *dotParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *dotParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *dotParser) expect(t string) error {
+	if got := p.next(); got != t {
+		return fmt.Errorf("dot: expected %q, got %q", t, got)
+	}
+	return nil
+}
+
+// ident returns the next token as an identifier.
+// Quoted strings are unquoted.
+func (p *dotParser) ident() (string, error) {
+	t := p.next()
+	switch t {
+	case "", "{", "}", "[", "]", "=", ";", ",", "->", "--":
+		return "", fmt.Errorf("dot: expected identifier, got %q", t)
+	}
+	if strings.HasPrefix(t, `"`) {
+		return dotUnquote(t), nil
+	}
+	return t, nil
+}
+
+func (p *dotParser) graph() error {
+	if strings.EqualFold(p.peek(), "strict") {
+		p.next()
+	}
+	if t := p.next(); !strings.EqualFold(t, "digraph") {
+		return fmt.Errorf("dot: expected digraph, got %q", t)
+	}
+	if p.peek() != "{" {
+		if _, err := p.ident(); err != nil {
+			return err
+		}
+	}
+	if err := p.stmts(); err != nil {
+		return err
+	}
+	if p.pos < len(p.toks) {
+		return fmt.Errorf("dot: unexpected %q after graph", p.peek())
+	}
+	return nil
+}
+
+// stmts parses a brace enclosed statement list.
+func (p *dotParser) stmts() error {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		switch p.peek() {
+		case "":
+			return fmt.Errorf("dot: unexpected end of input")
+		case "}":
+			p.next()
+			return nil
+		case ";":
+			p.next()
+			continue
+		}
+		if err := p.stmt(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *dotParser) stmt() error {
+	switch t := strings.ToLower(p.peek()); t {
+	case "graph", "node", "edge":
+		p.next()
+		_, err := p.attrs()
+		return err
+	case "subgraph", "{":
+		if t == "subgraph" {
+			p.next()
+			if p.peek() != "{" {
+				if _, err := p.ident(); err != nil {
+					return err
+				}
+			}
+		}
+		return p.stmts()
+	}
+	id, err := p.ident()
+	if err != nil {
+		return err
+	}
+	switch p.peek() {
+	case "=": // graph attribute, such as rankdir=LR
+		p.next()
+		_, err := p.ident()
+		return err
+	case "->":
+		p.next()
+		dst, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if p.peek() == "->" {
+			return fmt.Errorf("dot: edge chains are not supported: %s -> %s ->", id, dst)
+		}
+		a, err := p.attrs()
+		if err != nil {
+			return err
+		}
+		return p.edge(id, dst, a)
+	case "--":
+		return fmt.Errorf("dot: undirected edge from %s", id)
+	}
+	a, err := p.attrs()
+	if err != nil {
+		return err
+	}
+	return p.node(id, a)
+}
+
+// attrs parses an optional attribute list, such as [a=1, b="x"].
+func (p *dotParser) attrs() (map[string]string, error) {
+	a := make(map[string]string)
+	for p.peek() == "[" {
+		p.next()
+		for p.peek() != "]" {
+			k, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			v, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			a[k] = v
+			if t := p.peek(); t == "," || t == ";" {
+				p.next()
+			}
+		}
+		p.next()
+	}
+	return a, nil
+}
+
+func (p *dotParser) node(id string, a map[string]string) error {
+	if _, ok := p.ids[id]; ok {
+		return fmt.Errorf("dot: node %s is defined twice", id)
+	}
+	typ, ok := a["type"]
+	if !ok {
+		return fmt.Errorf("dot: node %s has no type attribute", id)
+	}
+	b, err := p.registry.NewBlock(typ)
+	if err != nil {
+		return fmt.Errorf("dot: node %s: %v", id, err)
+	}
+	if params, ok := a["params"]; ok {
+		if b, err = decodeParams(b, params); err != nil {
+			return fmt.Errorf("dot: node %s: %v", id, err)
+		}
+	}
+	p.ids[id] = len(p.sys.blocks)
+	p.sys.Add(b)
+	return nil
+}
+
+func (p *dotParser) edge(src, dst string, a map[string]string) error {
+	s, ok := p.ids[src]
+	if !ok {
+		return fmt.Errorf("dot: edge from undefined node %s", src)
+	}
+	d, ok := p.ids[dst]
+	if !ok {
+		return fmt.Errorf("dot: edge to undefined node %s", dst)
+	}
+	port := func(name string) (int, error) {
+		v, ok := a[name]
+		if !ok {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("dot: edge %s -> %s: %s: %v", src, dst, name, err)
+		}
+		return n, nil
+	}
+	o, err := port("o")
+	if err != nil {
+		return err
+	}
+	i, err := port("i")
+	if err != nil {
+		return err
+	}
+	if a["vector"] == "true" {
+		if err := p.sys.ConnectVector(s, d, o, i); err != nil {
+			return fmt.Errorf("dot: edge %s -> %s: %v", src, dst, err)
+		}
+		return nil
+	}
+	if o < 0 || o >= len(p.sys.blocks[s].Out) {
+		return fmt.Errorf("dot: edge %s -> %s: block %s has no output %d", src, dst, src, o)
+	}
+	if i < 0 || i >= len(p.sys.blocks[d].In) {
+		return fmt.Errorf("dot: edge %s -> %s: block %s has no input %d", src, dst, dst, i)
+	}
+	p.sys.Connect(s, d, o, i)
+	if v, ok := a["ic"]; ok {
+		x, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("dot: edge %s -> %s: ic: %v", src, dst, err)
+		}
+		p.sys.AddIC(x, d, i)
+	}
+	return nil
+}
+
+// decodeParams decodes the JSON string params into the block b.
+// Blocks which are not pointers are decoded into a copy,
+// which is returned.
+func decodeParams(b Block, params string) (Block, error) {
+	v := reflect.ValueOf(b)
+	if v.Kind() == reflect.Ptr {
+		return b, json.Unmarshal([]byte(params), b)
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	if err := json.Unmarshal([]byte(params), ptr.Interface()); err != nil {
+		return nil, err
+	}
+	return ptr.Elem().Interface().(Block), nil
+}
+
+// dotQuote returns s as a quoted DOT string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// dotUnquote reverses dotQuote.
+func dotUnquote(s string) string {
+	var b strings.Builder
+	s = s[1 : len(s)-1]
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// dotTokens splits DOT source into tokens.
+// Quoted strings keep their quotes, comments are removed.
+func dotTokens(src string) ([]string, error) {
+	var toks []string
+	r := []rune(src)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' || (c == '/' && i+1 < len(r) && r[i+1] == '/'):
+			for i < len(r) && r[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(r) && r[i+1] == '*':
+			end := strings.Index(string(r[i+2:]), "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("dot: unterminated comment")
+			}
+			i += 2 + len([]rune(string(r[i+2:])[:end])) + 2
+		case strings.ContainsRune("{}[]=;,", c):
+			toks = append(toks, string(c))
+			i++
+		case c == '-' && i+1 < len(r) && (r[i+1] == '>' || r[i+1] == '-'):
+			toks = append(toks, string(r[i:i+2]))
+			i += 2
+		case c == '"':
+			j := i + 1
+			for ; j < len(r) && r[j] != '"'; j++ {
+				if r[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(r) {
+				return nil, fmt.Errorf("dot: unterminated string")
+			}
+			toks = append(toks, string(r[i:j+1]))
+			i = j + 1
+		case c == '_' || c == '.' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			j := i
+			for j < len(r) && (r[j] == '_' || r[j] == '.' || unicode.IsLetter(r[j]) || unicode.IsDigit(r[j]) || (j == i && r[j] == '-')) {
+				j++
+			}
+			toks = append(toks, string(r[i:j]))
+			i = j
+		default:
+			return nil, fmt.Errorf("dot: unexpected character %q", c)
+		}
+	}
+	return toks, nil
+}
@@ -0,0 +1,135 @@
+package loops
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// ode1DOT is the 1st order system of TestOde1 with a Recorder
+// instead of the plot.
+const ode1DOT = `
+digraph ode1 {
+	rankdir=LR;
+	inte  [type=Integrate, params="{\"State\":1}"];
+	rec   [type=Recorder, params="{\"NumChannels\":1}"];
+	neg   [type=Scale, params="-1"];
+	add   [type=Add];
+	tee   [type=Tee];
+	zeros [type=Source];
+	stop  [type=Stop, params="{\"Time\":1}"]; // stops after 1s
+
+	inte  -> tee;
+	tee   -> rec;
+	tee   -> neg  [o=1];
+	neg   -> add  [i=1, ic=1];
+	zeros -> stop;
+	stop  -> add;
+	add   -> inte;
+}`
+
+// TestNewSystemFromDOT builds the ODE1 example from the DOT source and runs it.
+func TestNewSystemFromDOT(t *testing.T) {
+	var rec *Recorder
+	registry := map[string]func() Block{
+		"Integrate": func() Block { return &Integrate{} },
+		"Recorder":  func() Block { rec = &Recorder{}; return rec },
+		"Scale":     func() Block { return Scale(0) },
+		"Add":       func() Block { return Add{} },
+		"Tee":       func() Block { return Tee{} },
+		"Source":    func() Block { return Source(0) },
+		"Stop":      func() Block { return &Stop{} },
+	}
+	s, err := NewSystemFromDOT(ode1DOT, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	x := rec.Data[0]
+	if len(x) < 90 {
+		t.Fatalf("recorded %d samples", len(x))
+	}
+	tEnd := float64(len(x)) * DefaultDT
+	if last := x[len(x)-1]; math.Abs(last-math.Exp(-tEnd)) > 0.01 {
+		t.Fatalf("x(%v) = %v, want %v", tEnd, last, math.Exp(-tEnd))
+	}
+
+	for _, src := range []string{
+		`graph { a [type=Add] }`,
+		`digraph { a [type=Unknown] }`,
+		`digraph { a [type=Tee]; a -> b }`,
+		`digraph { a [type=Tee]; b [type=Tee]; a -> b [o=2] }`,
+		`digraph { a [type=Scale, params="x"] }`,
+	} {
+		if _, err := NewSystemFromDOT(src, registry); err == nil {
+			t.Fatalf("expected an error for %s", src)
+		}
+	}
+}
+
+// TestWriteDOT writes the DOT graph of the 1st order system,
+// checks the edges and rebuilds the system from it.
+func TestWriteDOT(t *testing.T) {
+	var s System
+	s.Add(&Integrate{State: 1})      // 0
+	s.Add(&Recorder{NumChannels: 1}) // 1
+	s.Add(Scale(-1))                 // 2
+	s.Add(Add{})                     // 3
+	s.Add(Tee{})                     // 4
+	s.Add(Source(0))                 // 5
+	s.Add(&Stop{Time: 1})            // 6
+	s.Connect(0, 4, 0, 0)            // inte -> tee
+	s.Connect(4, 1, 0, 0)            // tee -> rec
+	s.Connect(4, 2, 1, 0)            // tee -> neg
+	s.Connect(2, 3, 0, 1)            // neg -> add
+	s.Connect(5, 6, 0, 0)            // zeros -> stop
+	s.Connect(6, 3, 0, 0)            // stop -> add
+	s.Connect(3, 0, 0, 0)            // add -> inte
+	s.AddIC(1.0, 3, 1)
+
+	var buf bytes.Buffer
+	if err := s.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+
+	re := regexp.MustCompile(`n(\d+) -> n(\d+) \[label="(\d+):(\d+)"`)
+	var edges []string
+	for _, m := range re.FindAllStringSubmatch(dot, -1) {
+		edges = append(edges, strings.Join(m[1:], " "))
+	}
+	want := []string{"0 4 0 0", "4 1 0 0", "4 2 1 0", "2 3 0 1", "5 6 0 0", "6 3 0 0", "3 0 0 0"}
+	if strings.Join(edges, ",") != strings.Join(want, ",") {
+		t.Fatalf("got edges %q, want %q\n%s", edges, want, dot)
+	}
+	if !strings.Contains(dot, `n0 [label="0 Integrate", type=Integrate, params="{\"State\":1}"]`) {
+		t.Fatalf("node 0 is missing:\n%s", dot)
+	}
+
+	// The output can be read back.
+	var rec *Recorder
+	registry := map[string]func() Block{
+		"Integrate": func() Block { return &Integrate{} },
+		"Recorder":  func() Block { rec = &Recorder{}; return rec },
+		"Scale":     func() Block { return Scale(0) },
+		"Add":       func() Block { return Add{} },
+		"Tee":       func() Block { return Tee{} },
+		"Source":    func() Block { return Source(0) },
+		"Stop":      func() Block { return &Stop{} },
+	}
+	r, err := NewSystemFromDOT(dot, registry)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, dot)
+	}
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rec.Data[0]); n < 90 {
+		t.Fatalf("rebuilt system recorded %d samples", n)
+	}
+}
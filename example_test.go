@@ -0,0 +1,139 @@
+package loops_test
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"testing"
+
+	"github.com/ktye/loops"
+	"github.com/ktye/loops/plot"
+)
+
+// The examples of the README are external tests,
+// because the plot package imports loops.
+// Add3 is defined in the internal test ode2_test.go.
+
+// TestOde1 is the example for the 1st order system described in the README.
+func TestOde1(t *testing.T) {
+
+	// Set up blocks.
+	var inte = loops.Integrate{State: 1} // Initial condition x0 = 1
+	var plt = plot.Plot{NumChannels: 1, Size: image.Point{256, 256}}
+	var sub loops.Subtract
+	var tee loops.Tee
+	var zeros loops.Source
+
+	// The stop block terminates the simulation.
+	var stop = loops.Stop{Time: 3}
+
+	// Define the system.
+	// I'm sure there exists a better way to do this.
+	var system loops.System
+
+	// Add all blocks.
+	system.Add(&inte) // 0
+	system.Add(&plt)  // 1
+	system.Add(sub)   // 2
+	system.Add(tee)   // 3
+	system.Add(zeros) // 4
+	system.Add(&stop) // 5
+
+	// Connect blocks. This is the mechanical work,
+	// which would better be done by a front-end.
+	system.Connect(0, 3, 0, 0) // inte -> tee
+	system.Connect(3, 1, 0, 0) // tee -> plot
+	system.Connect(3, 2, 1, 1) // tee -> sub
+	system.Connect(4, 5, 0, 0) // zeros -> stop
+	system.Connect(5, 2, 0, 0) // stop -> sub
+	system.Connect(2, 0, 0, 0) // sub -> inte
+
+	// Add initial condition for x.
+	system.AddIC(1.0, 2, 1) // send 1.0 to block "sub" on input 1
+
+	if err := system.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Write the plot to ode1.png, after all blocks have stopped.
+	if err := plt.Write("ode1.png"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestOde2 is the example for the 2nd order system described in the README.
+func TestOde2(t *testing.T) {
+
+	fmt.Println("test ode 2")
+
+	// Set up blocks.
+	var inte1 = loops.Integrate{State: 0} // Initial condition v0 = 0
+	var inte2 = loops.Integrate{State: 1} // Initial condition x0 = 1
+	var plt = plot.Plot{NumChannels: 1, Size: image.Point{512, 256}}
+	var omega2 loops.Scale = -30
+	var delta loops.Scale = -0.5
+	var add loops.Add3
+	var tee1, tee2 loops.Tee
+	var zeros loops.Source
+	var stop = loops.Stop{Time: 5}
+
+	// Define the system.
+	var system loops.System
+
+	// Add all blocks.
+	system.Add(&inte1) // 0
+	system.Add(&inte2) // 1
+	system.Add(&plt)   // 2
+	system.Add(omega2) // 3
+	system.Add(delta)  // 4
+	system.Add(add)    // 5
+	system.Add(tee1)   // 6
+	system.Add(tee2)   // 7
+	system.Add(zeros)  // 8
+	system.Add(&stop)  // 9
+
+	// Connect blocks.
+	system.Connect(8, 5, 0, 0) // zeros -> add
+	system.Connect(5, 0, 0, 0) // add -> inte1
+	system.Connect(0, 6, 0, 0) // inte1 -> tee1
+	system.Connect(6, 1, 0, 0) // tee1 -> inte2
+	system.Connect(1, 7, 0, 0) // inte2 -> tee2
+	system.Connect(7, 9, 0, 0) // tee2 -> stop
+	system.Connect(9, 2, 0, 0) // stop -> plot
+	system.Connect(6, 4, 1, 0) // tee1 -> delta
+	system.Connect(4, 5, 0, 1) // delta -> add
+	system.Connect(7, 3, 1, 0) // tee2 -> omega2
+	system.Connect(3, 5, 0, 2) // omega2 -> add
+
+	// Add initial condition for x and v.
+	system.AddIC(0, 3, 0) // send 0 to block "omega2" on input 0
+	system.AddIC(1, 4, 0) // send 1 to block "delta" on input 0
+
+	if err := system.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	fmt.Println("writing ode2.png")
+	if err := plt.Write("ode2.png"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ExampleSystem_Simulate solves x' = -x, x(0) = 1 and prints x(0.5).
+func ExampleSystem_Simulate() {
+	var s loops.System
+	s.Add(&loops.Integrate{State: 1}) // 0
+	s.Add(loops.Scale(-1))            // 1
+	s.Connect(0, 1, 0, 0)             // inte -> neg
+	s.Connect(1, 0, 0, 0)             // neg -> inte
+	s.AddIC(-1, 0, 0)
+
+	t, signals, err := s.Simulate(1, []int{0})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("x(%.2f) = %.2f\n", t[50], signals[0][0][50])
+	// Output:
+	// x(0.50) = 0.60
+}
@@ -0,0 +1,124 @@
+package loops
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// jsonSystem is the JSON representation of a System.
+type jsonSystem struct {
+	Inputs      int              `json:"inputs,omitempty"`
+	Outputs     int              `json:"outputs,omitempty"`
+	Blocks      []jsonBlock      `json:"blocks"`
+	Connections []jsonConnection `json:"connections"`
+	Initials    []jsonIC         `json:"initials,omitempty"`
+}
+
+type jsonBlock struct {
+	Index  int             `json:"index"`
+	Type   string          `json:"type"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type jsonConnection struct {
+	Src    int  `json:"src"`
+	Dst    int  `json:"dst"`
+	O      int  `json:"o"`
+	I      int  `json:"i"`
+	Buf    int  `json:"buf,omitempty"`
+	Vector bool `json:"vector,omitempty"`
+}
+
+type jsonIC struct {
+	Value float64 `json:"value"`
+	Block int     `json:"block"`
+	Input int     `json:"input"`
+}
+
+// MarshalJSON encodes the system topology.
+// Each block is stored with it's index, type name and exported fields,
+// followed by all connections and initial conditions.
+func (s *System) MarshalJSON() ([]byte, error) {
+	j := jsonSystem{
+		Inputs:      len(s.In),
+		Outputs:     len(s.Out),
+		Blocks:      make([]jsonBlock, len(s.blocks)),
+		Connections: make([]jsonConnection, len(s.connections)),
+	}
+	for k, b := range s.blocks {
+		p, err := json.Marshal(b.Block)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %v", k, err)
+		}
+		j.Blocks[k] = jsonBlock{Index: k, Type: typeName(b.Block), Params: p}
+	}
+	for k, c := range s.connections {
+		j.Connections[k] = jsonConnection{c.src, c.dst, c.o, c.i, c.buf, c.vector}
+	}
+	for _, ic := range s.initials {
+		j.Initials = append(j.Initials, jsonIC{ic.value, ic.block, ic.input})
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON rebuilds a system encoded by MarshalJSON.
+// Blocks are created by the factory functions in s.Registry,
+// which are looked up by type name, or by NewRegistry if it is nil. Fields in the encoded data which
+// are unknown to a block are ignored.
+// The system must be empty.
+func (s *System) UnmarshalJSON(data []byte) error {
+	if len(s.blocks) > 0 {
+		return fmt.Errorf("unmarshal: system is not empty")
+	}
+	var j jsonSystem
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	s.In = make([]chan float64, j.Inputs)
+	s.Out = make([]chan float64, j.Outputs)
+	registry := s.Registry
+	if registry == nil {
+		registry = NewRegistry()
+	}
+	for k, jb := range j.Blocks {
+		if jb.Index != k {
+			return fmt.Errorf("unmarshal: block %d has index %d", k, jb.Index)
+		}
+		b, err := registry.NewBlock(jb.Type)
+		if err != nil {
+			return fmt.Errorf("unmarshal: block %d: %v", k, err)
+		}
+		if sub, ok := any(b).(*System); ok && sub.Registry == nil {
+			sub.Registry = registry
+		}
+		if len(jb.Params) > 0 {
+			if b, err = decodeParams(b, string(jb.Params)); err != nil {
+				return fmt.Errorf("unmarshal: block %d: %v", k, err)
+			}
+		}
+		s.Add(b)
+	}
+	in := func(k, n int) bool { return k >= 0 && k < n }
+	for _, c := range j.Connections {
+		if c.Vector {
+			if err := s.ConnectVector(c.Src, c.Dst, c.O, c.I); err != nil {
+				return fmt.Errorf("unmarshal: %v", err)
+			}
+			continue
+		}
+		if c.O >= 0 && (!in(c.Src, len(s.blocks)) || !in(c.O, len(s.blocks[c.Src].Out))) ||
+			c.O < 0 && !in(-c.O-1, len(s.In)) ||
+			c.I >= 0 && (!in(c.Dst, len(s.blocks)) || !in(c.I, len(s.blocks[c.Dst].In))) ||
+			c.I < 0 && !in(-c.I-1, len(s.Out)) {
+			return fmt.Errorf("unmarshal: invalid connection %+v", c)
+		}
+		s.ConnectBuffered(c.Src, c.Dst, c.O, c.I, c.Buf)
+	}
+	for _, ic := range j.Initials {
+		if !in(ic.Block, len(s.blocks)) || !in(ic.Input, len(s.blocks[ic.Block].In)) {
+			return fmt.Errorf("unmarshal: invalid initial condition %+v", ic)
+		}
+		s.AddIC(ic.Value, ic.Block, ic.Input)
+	}
+	return nil
+}
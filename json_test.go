@@ -0,0 +1,129 @@
+package loops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// testRegistry returns constructors for all blocks used by the ODE examples.
+func testRegistry() map[string]func() Block {
+	return map[string]func() Block{
+		"Integrate": func() Block { return &Integrate{} },
+		"Scope":     func() Block { return &Scope{} },
+		"Scale":     func() Block { return Scale(0) },
+		"Add":       func() Block { return Add{} },
+		"Add3":      func() Block { return Add3{} },
+		"Tee":       func() Block { return Tee{} },
+		"Source":    func() Block { return Source(0) },
+		"Stop":      func() Block { return &Stop{} },
+	}
+}
+
+// TestJSON encodes the ODE examples, decodes and re-encodes them,
+// and checks that the decoded systems produce the same results.
+func TestJSON(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		build func(Block, *Stop) *System
+		sink  int // block index of the scope
+	}{
+		{"ode1", ode1System, 1},
+		{"ode2", ode2System, 2},
+	} {
+		name := c.name
+		scope := Scope{NumChannels: 1}
+		s := c.build(&scope, &Stop{Time: 1})
+		data, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := System{Registry: testRegistry()}
+		if err := json.Unmarshal(data, &r); err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, data)
+		}
+		again, err := json.Marshal(&r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Fatalf("%s: round trip differs:\n%s\n%s", name, data, again)
+		}
+
+		if err := s.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := truncate(t, r.Block(c.sink).(*Scope).Data[0], scope.Data[0]); !equal(got, want) {
+			t.Fatalf("%s: decoded system gives different results", name)
+		}
+	}
+
+	// Unknown types are reported.
+	r := System{Registry: map[string]func() Block{}}
+	if err := json.Unmarshal([]byte(`{"blocks":[{"index":0,"type":"Tee"}]}`), &r); err == nil {
+		t.Fatal("expected an error for an unknown type")
+	}
+}
+
+// offset is a custom block for TestRegistry.
+type offset struct{ Value float64 }
+
+func (b *offset) Inputs() int  { return 1 }
+func (b *offset) Outputs() int { return 1 }
+func (b *offset) Step(in, out []float64) bool {
+	out[0] = in[0] + b.Value
+	return true
+}
+
+// TestRegistry marshals a system with a custom block and
+// rebuilds it with the built-in blocks and the registered type.
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	for name, f := range r {
+		if b := f(); typeName(b) != name {
+			t.Errorf("%s creates a %s", name, typeName(b))
+		}
+	}
+	if _, err := r.NewBlock("offset"); err == nil {
+		t.Fatal("expected an error for an unregistered type")
+	}
+	r.Register("offset", func() Block { return &offset{} })
+
+	var s System
+	s.Add(&RampSource{Slope: 1})     // 0
+	s.Add(&offset{Value: 2.5})       // 1
+	s.Add(&Stop{Time: 0.1})          // 2
+	s.Add(&Recorder{NumChannels: 1}) // 3
+	s.Connect(0, 1, 0, 0)            // ramp -> offset
+	s.Connect(1, 2, 0, 0)            // offset -> stop
+	s.Connect(2, 3, 0, 0)            // stop -> rec
+	data, err := json.Marshal(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the custom type, the default registry fails.
+	if err := json.Unmarshal(data, &System{}); err == nil {
+		t.Fatal("expected an error for the custom type")
+	}
+	d := System{Registry: r}
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatal(err)
+	}
+	b, ok := d.Block(1).(*offset)
+	if !ok || b.Value != 2.5 {
+		t.Fatalf("got %#v", d.Block(1))
+	}
+	if err := d.StartSync(); err != nil {
+		t.Fatal(err)
+	}
+	rec := d.Block(3).(*Recorder)
+	if len(rec.Data) != 1 || len(rec.Data[0]) < 5 || rec.Data[0][0] != 2.5 {
+		t.Fatalf("recorded %v", rec.Data)
+	}
+}
@@ -0,0 +1,165 @@
+package loops
+
+import (
+	"fmt"
+	"math"
+)
+
+// LinearizeAt computes the linear model x' = Ax + Bu, y = Cx + Du of the
+// system around the operating point x0, u0 by central finite differences.
+//
+// The states x are the Integrate blocks and the inputs u the Source blocks,
+// both in the order of their block indexes. The outputs y are the inputs of
+// all sinks, the blocks without outputs, again in this order.
+//
+// The system itself is not changed. LinearizeAt works on a copy, in which
+// the integrators output their fixed state and the sources the input values.
+// For each perturbation, the copy is reset and advanced with StepOnce, until
+// the derivatives at the integrator inputs and the outputs settle.
+// This requires that all other blocks are static functions of their inputs,
+// after the initial conditions have been passed on. Systems with other
+// dynamic blocks, such as Delay, TransferFunction or RK4, do not settle
+// and return an error. Sub-systems are not supported by StepOnce.
+func (s *System) LinearizeAt(x0, u0 []float64) (A, B, C, D [][]float64, err error) {
+	c, err := s.Clone()
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("linearize: %v", err)
+	}
+	var states, inputs []*linearProbe
+	var outputs []*linearProbe
+	for k, b := range c.blocks {
+		switch b.Block.(type) {
+		case *Integrate:
+			p := &linearProbe{in: make([]float64, 1), out: 1}
+			states = append(states, p)
+			c.blocks[k].Block = p
+		case Source:
+			p := &linearProbe{out: 1}
+			inputs = append(inputs, p)
+			c.blocks[k].Block = p
+		default:
+			if b.Block.Outputs() == 0 && b.Block.Inputs() > 0 {
+				p := &linearProbe{in: make([]float64, b.Block.Inputs())}
+				outputs = append(outputs, p)
+				c.blocks[k].Block = p
+			}
+		}
+	}
+	if len(x0) != len(states) || len(u0) != len(inputs) {
+		return nil, nil, nil, nil, fmt.Errorf("linearize: the system has %d states and %d inputs, got %d and %d", len(states), len(inputs), len(x0), len(u0))
+	}
+	ny := 0
+	for _, p := range outputs {
+		ny += len(p.in)
+	}
+
+	// eval returns the derivatives and the outputs for x and u.
+	rounds := len(c.initials) + 2
+	eval := func(x, u []float64) (f, y []float64, err error) {
+		c.Reset()
+		for i, p := range states {
+			p.value = x[i]
+		}
+		for i, p := range inputs {
+			p.value = u[i]
+		}
+		var last []float64
+		for r := 0; r <= rounds; r++ {
+			if err := c.StepOnce(); err != nil {
+				return nil, nil, err
+			}
+			f, y = f[:0], y[:0]
+			for _, p := range states {
+				f = append(f, p.in[0])
+			}
+			for _, p := range outputs {
+				y = append(y, p.in...)
+			}
+			if r == rounds && !equalValues(last, append(f[:len(f):len(f)], y...)) {
+				return nil, nil, fmt.Errorf("the system does not settle, it has dynamic blocks other than Integrate")
+			}
+			last = append(append(last[:0], f...), y...)
+		}
+		return f, y, nil
+	}
+	if _, _, err := eval(x0, u0); err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("linearize: %v", err)
+	}
+
+	// jacobian returns the derivatives of f and y with respect to v,
+	// which is x0 or u0, as columns.
+	jacobian := func(v []float64) (df, dy [][]float64, err error) {
+		df, dy = zeroMatrix(len(states), len(v)), zeroMatrix(ny, len(v))
+		for j := range v {
+			vj := v[j]
+			h := 1e-6 * math.Max(1, math.Abs(vj))
+			v[j] = vj + h
+			f1, y1, err := eval(x0, u0)
+			if err != nil {
+				return nil, nil, err
+			}
+			f1, y1 = append([]float64(nil), f1...), append([]float64(nil), y1...)
+			v[j] = vj - h
+			f2, y2, err := eval(x0, u0)
+			v[j] = vj
+			if err != nil {
+				return nil, nil, err
+			}
+			for i := range f1 {
+				df[i][j] = (f1[i] - f2[i]) / (2 * h)
+			}
+			for i := range y1 {
+				dy[i][j] = (y1[i] - y2[i]) / (2 * h)
+			}
+		}
+		return df, dy, nil
+	}
+	x0, u0 = append([]float64(nil), x0...), append([]float64(nil), u0...)
+	if A, C, err = jacobian(x0); err == nil {
+		B, D, err = jacobian(u0)
+	}
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("linearize: %v", err)
+	}
+	return A, B, C, D, nil
+}
+
+// linearProbe replaces blocks of the system copy in LinearizeAt.
+// It outputs value and stores it's inputs.
+type linearProbe struct {
+	value float64
+	in    []float64
+	out   int
+}
+
+func (b *linearProbe) IsDelay() bool { return true }
+func (b *linearProbe) Inputs() int   { return len(b.in) }
+func (b *linearProbe) Outputs() int  { return b.out }
+func (b *linearProbe) Step(in, out []float64) bool {
+	copy(b.in, in)
+	for i := range out {
+		out[i] = b.value
+	}
+	return true
+}
+
+func zeroMatrix(rows, cols int) [][]float64 {
+	m := make([][]float64, rows)
+	for i := range m {
+		m[i] = make([]float64, cols)
+	}
+	return m
+}
+
+// equalValues compares two slices exactly.
+func equalValues(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
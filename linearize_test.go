@@ -0,0 +1,87 @@
+package loops
+
+import (
+	"math"
+	"testing"
+)
+
+// pendulum returns the damped pendulum x1' = x2, x2' = -sin(x1) - 0.5x2 + u
+// with the output y = x1.
+func pendulum() *System {
+	var s System
+	s.Add(&Integrate{})   // 0 x1
+	s.Add(&Integrate{})   // 1 x2
+	s.Add(Tee{})          // 2
+	s.Add(Tee{})          // 3
+	s.Add(SinBlock())     // 4
+	s.Add(Scale(-1))      // 5
+	s.Add(Scale(-0.5))    // 6
+	s.Add(AddN{N: 3})     // 7
+	s.Add(Source(0))      // 8 u
+	s.Add(discard{})      // 9 y
+	s.Connect(0, 2, 0, 0) // x1 -> tee1
+	s.Connect(2, 4, 0, 0) // tee1 -> sin
+	s.Connect(2, 9, 1, 0) // tee1 -> y
+	s.Connect(4, 5, 0, 0) // sin -> neg
+	s.Connect(5, 7, 0, 0) // neg -> add
+	s.Connect(1, 3, 0, 0) // x2 -> tee2
+	s.Connect(3, 0, 0, 0) // tee2 -> x1
+	s.Connect(3, 6, 1, 0) // tee2 -> damping
+	s.Connect(6, 7, 0, 1) // damping -> add
+	s.Connect(8, 7, 0, 2) // u -> add
+	s.Connect(7, 1, 0, 0) // add -> x2
+	s.AddIC(0, 7, 0)
+	s.AddIC(0, 7, 1)
+	return &s
+}
+
+// TestLinearizeAt compares the linearized pendulum with the analytical Jacobian.
+func TestLinearizeAt(t *testing.T) {
+	s := pendulum()
+	for _, x1 := range []float64{0, 0.5, math.Pi} {
+		A, B, C, D, err := s.LinearizeAt([]float64{x1, 0.3}, []float64{0.2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range []struct {
+			name      string
+			got, want [][]float64
+		}{
+			{"A", A, [][]float64{{0, 1}, {-math.Cos(x1), -0.5}}},
+			{"B", B, [][]float64{{0}, {1}}},
+			{"C", C, [][]float64{{1, 0}}},
+			{"D", D, [][]float64{{0}}},
+		} {
+			if len(c.got) != len(c.want) {
+				t.Fatalf("x1=%v: %s is %v, want %v", x1, c.name, c.got, c.want)
+			}
+			for i := range c.want {
+				for j := range c.want[i] {
+					if math.Abs(c.got[i][j]-c.want[i][j]) > 1e-6 {
+						t.Fatalf("x1=%v: %s is %v, want %v", x1, c.name, c.got, c.want)
+					}
+				}
+			}
+		}
+	}
+	if x := s.Block(0).(*Integrate).State; x != 0 {
+		t.Fatalf("the system has been changed: x1 = %v", x)
+	}
+
+	if _, _, _, _, err := s.LinearizeAt([]float64{0}, []float64{0}); err == nil {
+		t.Fatal("expected an error for a missing state")
+	}
+
+	// A transfer function has a state of it's own and does not settle.
+	var d System
+	d.Add(Source(1))                                                  // 0
+	d.Add(&TransferFunction{Num: []float64{1}, Den: []float64{1, 1}}) // 1
+	d.Add(&Integrate{})                                               // 2
+	d.Add(discard{})                                                  // 3
+	d.Connect(0, 1, 0, 0)                                             // u -> tf
+	d.Connect(1, 2, 0, 0)                                             // tf -> inte
+	d.Connect(2, 3, 0, 0)                                             // inte -> discard
+	if _, _, _, _, err := d.LinearizeAt([]float64{0}, []float64{1}); err == nil {
+		t.Fatal("expected an error for a transfer function")
+	}
+}
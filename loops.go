@@ -3,10 +3,35 @@
 // See github.com/ktye/loops/blob/master/README.md for a description
 package loops
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
-// Simulation time step increment.
-var DT = 0.01
+// ErrWatchdogTimeout is returned by Start, if the System.Watchdog
+// has ended the simulation.
+var ErrWatchdogTimeout = errors.New("watchdog timeout: no block has been stepped")
+
+// DefaultDT is the simulation time step increment
+// of a System which has no DT set.
+var DefaultDT = 0.01
+
+// timeStep returns dt, or DefaultDT if it is 0.
+// Blocks use it, as they may be stepped outside of a system.
+func timeStep(dt float64) float64 {
+	if dt == 0 {
+		return DefaultDT
+	}
+	return dt
+}
 
 // A block is any type which has a Step function as well as
 // Inputs and Outputs.
@@ -20,10 +45,91 @@ type Block interface {
 	Outputs() int
 }
 
+// A Validator is a block which can check its own parameters.
+// It is an optional interface. If a block implements it,
+// the system calls Validate before the simulation starts.
+type Validator interface {
+	Validate() error
+}
+
+// A DTSetter is a block which needs to know the simulation time step.
+// It is an optional interface. The system calls SetDT on every block
+// which implements it, before the simulation starts.
+type DTSetter interface {
+	SetDT(float64)
+}
+
+// A Resetter is a block with internal state, which can be restored
+// to the state before the simulation.
+// It is an optional interface, which is used by System.Reset.
+type Resetter interface {
+	Reset()
+}
+
+// A Clock counts the steps of a simulation.
+// Each System owns one, which is advanced once per step of it's clock block,
+// the first Stop block or block 0, see ProgressFunc.
+// With StartSync, the clock is advanced after all blocks have been stepped,
+// so during a step all blocks see the same time.
+// With Start, blocks run concurrently and may be a few steps ahead or behind.
+type Clock struct {
+	steps atomic.Int64
+	dt    atomic.Uint64 // float64 bits
+}
+
+// T returns the simulation time, the number of steps times DT.
+func (c *Clock) T() float64 { return float64(c.steps.Load()) * c.DT() }
+
+// Steps returns the number of steps since the simulation started.
+func (c *Clock) Steps() int64 { return c.steps.Load() }
+
+// DT returns the time step of the system.
+func (c *Clock) DT() float64 { return timeStep(math.Float64frombits(c.dt.Load())) }
+
+func (c *Clock) tick() { c.steps.Add(1) }
+
+// A ClockAware block gets the clock of the system, when it is added.
+// It is an optional interface. Built-in time dependent blocks implement it
+// and compute their time from their step count and the time step of the clock,
+// instead of accumulating the time step, which drifts.
+type ClockAware interface {
+	SetClock(*Clock)
+}
+
+// clocked counts the steps of a ClockAware block.
+type clocked struct {
+	clock *Clock
+	k     int64 // number of steps
+}
+
+func (c *clocked) SetClock(clock *Clock) { c.clock = clock }
+
+// next counts a step and returns the time of the next one.
+// Without a clock, dt is the time step.
+func (c *clocked) next(dt float64) float64 {
+	if c.clock != nil {
+		dt = c.clock.DT()
+	}
+	c.k++
+	return float64(c.k) * timeStep(dt)
+}
+
+// A DelayBlock is a block with internal state, such as an integrator,
+// whose output is not an instantaneous function of it's input.
+// It is an optional interface, which marks the block as a breaker
+// of algebraic loops.
+// Every feedback loop must contain at least one block for which
+// IsDelay returns true.
+type DelayBlock interface {
+	IsDelay() bool
+}
+
 // ioBlock stores a Block together with it's in and output channels.
+// VIn and VOut are the vector channels of a VectorBlock.
 type ioBlock struct {
 	Block
-	In, Out []chan float64
+	In, Out   []chan float64
+	VIn, VOut []chan []float64
 }
 
 // A System connects multiple blocks and runs the simulation.
@@ -32,22 +138,96 @@ type ioBlock struct {
 // Any level of nesting is possible.
 type System struct {
 	In, Out     []chan float64
+	DT          float64  // Simulation time step, DefaultDT if 0.
+	Registry    Registry // Block constructors by type name, used by UnmarshalJSON.
 	blocks      []ioBlock
 	initials    []IC
-	initialized bool
+	connections []connection
+	spies       []*ChannelSpy
+	names       map[string]int // block indexes by name, see AddNamed
+	clock       Clock
+	profiling   bool
+	profile     []BlockProfile // of the last run, see EnableProfiling
+	stops       []*Stop        // registered for ForceStop
+	force       chan struct{}  // closed by ForceStop
+	forceOnce   sync.Once
+	stepper     *syncRunner // of StepOnce
+
+	// A sub-system runs in the background, see Step.
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+
+	// ProgressFunc is called periodically while the simulation is running
+	// and once at the end, with the current simulation time t and the stop time.
+	// The time is counted at the first Stop block, which also gives tEnd.
+	// Without a Stop block, the steps of the first block are counted and tEnd is 0.
+	ProgressFunc     func(t, tEnd float64)
+	ProgressInterval time.Duration // Interval of ProgressFunc calls, 1s if 0.
+
+	// Watchdog ends a simulation, in which no block has been stepped
+	// for the given duration, e.g. because an initial condition is missing.
+	// Start then returns ErrWatchdogTimeout. It is disabled if 0.
+	Watchdog time.Duration
 }
 
 func (s *System) Inputs() int  { return len(s.In) }
 func (s *System) Outputs() int { return len(s.Out) }
-func (s *System) Step(in, out []chan float64) bool {
-	// This is needed to start sub-systems only.
-	// The outer system is started manually.
-	if !s.initialized {
-		s.Start()
+
+// Step passes the inputs to the system's input ports and returns
+// the values of it's output ports.
+// This is needed for sub-systems only, which are started in the
+// background on the first step.
+// The outer system is started manually.
+func (s *System) Step(in, out []float64) bool {
+	if s.done == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.cancel, s.done = cancel, make(chan struct{})
+		go func() {
+			s.err = s.Start(ctx)
+			close(s.done)
+		}()
+	}
+	for i, v := range in {
+		select {
+		case s.In[i] <- v:
+		case <-s.done:
+			return false
+		}
+	}
+	for i, c := range s.Out {
+		select {
+		case v, ok := <-c:
+			if !ok {
+				return false
+			}
+			out[i] = v
+		case <-s.done:
+			return false
+		}
 	}
 	return true
 }
 
+// Close stops a sub-system, which has been started by Step,
+// and waits until it has finished.
+func (s *System) Close() error {
+	if s.done == nil {
+		return nil
+	}
+	s.cancel()
+	<-s.done
+	err := s.err
+	s.cancel, s.done, s.err = nil, nil, nil
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
+
+// SetDT sets the time step of a sub-system to the one of the parent.
+func (s *System) SetDT(dt float64) { s.DT = dt }
+
 // Additionally to the methods to satisfy the Block interface,
 // the system has methods to add and connect blocks.
 
@@ -58,7 +238,41 @@ func (s *System) Add(b Block) {
 		In:    make([]chan float64, b.Inputs()),
 		Out:   make([]chan float64, b.Outputs()),
 	}
+	if v, ok := b.(VectorBlock); ok {
+		io.VIn = make([]chan []float64, v.VectorInputs())
+		io.VOut = make([]chan []float64, v.VectorOutputs())
+	}
 	s.blocks = append(s.blocks, io)
+	s.addPort(b)
+	s.setClock(b)
+}
+
+// Clock returns the clock of the system.
+func (s *System) Clock() *Clock { return &s.clock }
+
+// setClock passes the system clock to a ClockAware block.
+func (s *System) setClock(b Block) {
+	if c, ok := b.(ClockAware); ok {
+		c.SetClock(&s.clock)
+	}
+}
+
+// Block returns the i'th block of the system.
+func (s *System) Block(i int) Block {
+	return s.blocks[i].Block
+}
+
+// NumBlocks returns the number of blocks of the system.
+func (s *System) NumBlocks() int { return len(s.blocks) }
+
+// typeName returns the name of the block's type without a package
+// qualifier, dereferencing pointers.
+func typeName(b Block) string {
+	t := reflect.TypeOf(b)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
 }
 
 // IC is an initial condition which is sent to a channel
@@ -78,84 +292,522 @@ func (s *System) AddIC(x float64, dst, i int) {
 	})
 }
 
+// connection records the arguments of a call to Connect or ConnectBuffered.
+type connection struct {
+	src, dst, o, i int
+	buf            int  // channel buffer size
+	vector         bool // connection between vector ports
+}
+
 // Connect creates a channel between src at output number o
 // and dst at input number i.
+// Within a sub-system, a negative o connects the system's input -o-1
+// and a negative i the system's output -i-1. These negative port numbers
+// are deprecated, use AddInputPort and AddOutputPort instead.
 func (s *System) Connect(src, dst, o, i int) {
-	c := make(chan float64)
-	if i < 0 {
-		s.Out[-i-1] = c
+	s.ConnectBuffered(src, dst, o, i, 0)
+}
+
+// ConnectBuffered is like Connect, but the channel has a buffer
+// of size bufSize. This allows the source block to run ahead of
+// the destination by up to bufSize steps.
+func (s *System) ConnectBuffered(src, dst, o, i, bufSize int) {
+	c := connection{src: src, dst: dst, o: o, i: i, buf: bufSize}
+	s.connections = append(s.connections, c)
+	s.connect(c)
+}
+
+// connect creates the channel for the connection c.
+func (s *System) connect(c connection) {
+	if c.vector {
+		ch := make(chan []float64, c.buf)
+		s.blocks[c.dst].VIn[c.i] = ch
+		s.blocks[c.src].VOut[c.o] = ch
+		return
+	}
+	ch := make(chan float64, c.buf)
+	if c.i < 0 {
+		s.Out[-c.i-1] = ch
 	} else {
-		s.blocks[dst].In[i] = c
+		s.blocks[c.dst].In[c.i] = ch
 	}
-	if o < 0 {
-		s.In[-o-1] = c
+	if c.o < 0 {
+		s.In[-c.o-1] = ch
 	} else {
-		s.blocks[src].Out[o] = c
+		s.blocks[c.src].Out[c.o] = ch
 	}
 }
 
 // check checks if the system is set up correctly, that is
-// if all blocks are connected properly.
+// if all blocks are connected properly and have valid parameters.
+// It returns the first error found by validate.
 func (s *System) check() error {
-	for i, b := range s.blocks {
-		for k, c := range b.In {
-			if c == nil {
-				return fmt.Errorf("block %d input %d is not connected", i, k)
+	if errs := s.validate(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// algebraicLoop returns the block indices of a cycle which
+// does not pass through a DelayBlock, or nil.
+// It does a depth first search over all connections,
+// ignoring those which leave a DelayBlock.
+func (s *System) algebraicLoop() []int {
+	next := make([][]int, len(s.blocks))
+	for _, c := range s.connections {
+		if c.src < 0 || c.dst < 0 || c.o < 0 || c.i < 0 {
+			continue
+		}
+		if d, ok := s.blocks[c.src].Block.(DelayBlock); ok && d.IsDelay() {
+			continue
+		}
+		next[c.src] = append(next[c.src], c.dst)
+	}
+
+	const (
+		unvisited = iota
+		active    // on the current path
+		done
+	)
+	state := make([]int, len(s.blocks))
+	var path []int
+	var visit func(b int) []int
+	visit = func(b int) []int {
+		state[b] = active
+		path = append(path, b)
+		for _, d := range next[b] {
+			switch state[d] {
+			case active:
+				for k := len(path) - 1; k >= 0; k-- {
+					if path[k] == d {
+						return append(append([]int(nil), path[k:]...), d)
+					}
+				}
+			case unvisited:
+				if loop := visit(d); loop != nil {
+					return loop
+				}
 			}
 		}
-		for k, c := range b.Out {
-			if c == nil {
-				return fmt.Errorf("block %d output %d is not connected", i, k)
+		path = path[:len(path)-1]
+		state[b] = done
+		return nil
+	}
+	for b := range s.blocks {
+		if state[b] == unvisited {
+			if loop := visit(b); loop != nil {
+				return loop
 			}
 		}
 	}
 	return nil
 }
 
-// Start starts goroutines for every block of the system.
-func (s *System) Start() error {
+// Start starts goroutines for every block of the system
+// and waits until the simulation is finished.
+// The simulation ends when a block's Step function returns false,
+// or when ctx is cancelled. In the latter case ctx.Err() is returned.
+// If the Watchdog ends the simulation, it returns ErrWatchdogTimeout.
+// All goroutines have exited when Start returns.
+// Blocks which implement io.Closer, such as CSVSink, are closed
+// when their goroutine exits.
+func (s *System) Start(ctx context.Context) error {
 	// Check if all system blocks are properly connected.
 	if err := s.check(); err != nil {
 		return err
 	}
 
-	done := make(chan bool)
+	s.setDT()
+	s.startProfile()
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.startPorts(ctx)
+	var wg sync.WaitGroup
+
+	// Initial conditions are the first values a block reads from
+	// it's inputs, before it reads from the channels.
+	initials := make([][][]float64, len(s.blocks))
+	for _, ic := range s.initials {
+		if initials[ic.block] == nil {
+			initials[ic.block] = make([][]float64, len(s.blocks[ic.block].In))
+		}
+		initials[ic.block][ic.input] = append(initials[ic.block][ic.input], ic.value)
+	}
+
+	// The steps of the clock block advance the system clock.
+	clock, tEnd := s.clockBlock()
+
+	// The steps of all blocks are counted for the watchdog.
+	var progress atomic.Int64
+	var timeout atomic.Bool
 
 	// Create a goroutine for every block.
 	// The goroutine runs in the background.
-	// It's a function that loops for ever
+	// It's a function that loops until the context is cancelled
 	// and calls the block's Step function each time.
-	for _, b := range s.blocks {
-		// Arrange input and output channels
-		// for the block's step function.
-		go func(in, out []chan float64, b Block) {
-			x := make([]float64, len(in))
-			y := make([]float64, len(out))
+	// A block which returns false closes it's outputs and ends
+	// the simulation, which may happen for several blocks at once.
+	for k, b := range s.blocks {
+		wg.Add(1)
+		go func(k int, b ioBlock, ic [][]float64, prof *BlockProfile) {
+			defer wg.Done()
+			defer closeBlock(k, b.Block)
+			// Arrange input and output values
+			// for the block's step function.
+			x := make([]float64, len(b.In))
+			y := make([]float64, len(b.Out))
+			vx := make([][]float64, len(b.VIn))
+			vy := make([][]float64, len(b.VOut))
+			vb, isVector := b.Block.(VectorBlock)
 			for {
+				for i, c := range b.In {
+					if i < len(ic) && len(ic[i]) > 0 {
+						x[i], ic[i] = ic[i][0], ic[i][1:]
+						continue
+					}
+					select {
+					case v, ok := <-c:
+						if !ok {
+							return
+						}
+						x[i] = v
+					case <-ctx.Done():
+						return
+					}
+				}
+				for i, c := range b.VIn {
+					select {
+					case v, ok := <-c:
+						if !ok {
+							return
+						}
+						vx[i] = v
+					case <-ctx.Done():
+						return
+					}
+				}
 				var ok bool
-				for i, c := range in {
-					x[i], ok = <-c
-					if !ok {
+				var t0 time.Time
+				if prof != nil {
+					t0 = time.Now()
+				}
+				if isVector {
+					ok = vb.StepVector(x, y, vx, vy)
+				} else {
+					ok = b.Step(x, y)
+				}
+				if prof != nil {
+					prof.add(time.Since(t0))
+				}
+				if k == clock {
+					s.clock.tick()
+				}
+				if s.Watchdog > 0 {
+					progress.Add(1)
+				}
+				if !ok {
+					for _, c := range b.Out {
+						close(c)
+					}
+					for _, c := range b.VOut {
+						close(c)
+					}
+					cancel()
+					return
+				}
+				for i, c := range b.Out {
+					select {
+					case c <- y[i]:
+					case <-ctx.Done():
+						return
+					}
+				}
+				for i, c := range b.VOut {
+					select {
+					case c <- vy[i]:
+					case <-ctx.Done():
 						return
 					}
 				}
-				if b.Step(x, y) == false {
-					done <- true
+			}
+		}(k, b, initials[k], s.blockProfile(k))
+	}
+
+	// Start forwarding spied channels.
+	for _, spy := range s.spies {
+		wg.Add(1)
+		go func(spy *ChannelSpy) {
+			defer wg.Done()
+			spy.run(ctx)
+		}(spy)
+	}
+
+	// The watchdog cancels the simulation, if the steps stop.
+	if s.Watchdog > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(max(s.Watchdog/4, 1))
+			defer ticker.Stop()
+			last, since := progress.Load(), time.Now()
+			for {
+				select {
+				case <-ticker.C:
+					if p := progress.Load(); p != last {
+						last, since = p, time.Now()
+					} else if time.Since(since) >= s.Watchdog {
+						timeout.Store(true)
+						cancel()
+						return
+					}
+				case <-ctx.Done():
 					return
 				}
-				for i, c := range out {
-					c <- y[i]
+			}
+		}()
+	}
+
+	// Report the progress.
+	if s.ProgressFunc != nil {
+		interval := s.ProgressInterval
+		if interval == 0 {
+			interval = time.Second
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					s.ProgressFunc(s.clock.T(), tEnd)
+				case <-ctx.Done():
+					s.ProgressFunc(s.clock.T(), tEnd)
+					return
 				}
 			}
-		}(b.In, b.Out, b.Block)
+		}()
 	}
 
-	// Send initial conditions.
-	for _, ic := range s.initials {
-		s.blocks[ic.block].In[ic.input] <- ic.value
+	// Wait until all goroutines have exited.
+	wg.Wait()
+	if timeout.Load() {
+		return ErrWatchdogTimeout
 	}
+	return parent.Err()
+}
+
+// setDT tells all blocks the time step and returns it.
+// It also resets the clock.
+func (s *System) setDT() float64 {
+	dt := timeStep(s.DT)
+	s.clock.dt.Store(math.Float64bits(dt))
+	s.clock.steps.Store(0)
+	for _, b := range s.blocks {
+		if d, ok := b.Block.(DTSetter); ok {
+			d.SetDT(dt)
+		}
+	}
+	return dt
+}
+
+// clockBlock returns the index of the block, whose steps advance
+// the clock, and the stop time.
+// This is the first Stop block, or block 0 without a stop time.
+func (s *System) clockBlock() (int, float64) {
+	for k, b := range s.blocks {
+		if stop, ok := b.Block.(*Stop); ok {
+			return k, stop.Time
+		}
+	}
+	return 0, 0
+}
+
+// closeBlock closes block k, if it implements io.Closer.
+func closeBlock(k int, b Block) {
+	if c, ok := b.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Printf("block %d: %v", k, err)
+		}
+	}
+}
+
+// closeBlocks closes all blocks which implement io.Closer, in reverse order.
+func (s *System) closeBlocks() {
+	for k := len(s.blocks) - 1; k >= 0; k-- {
+		closeBlock(k, s.blocks[k].Block)
+	}
+}
 
-	// Wait for the simulation to finish.
-	<-done
+// Reset prepares the system to be started again, without rebuilding it.
+// It reallocates all channels and calls Reset on every block
+// which implements the Resetter interface.
+// Other block parameters, such as Integrate.State, are kept as they are
+// and may be changed before the next start.
+// Initial conditions are sent again, when the system is started.
+// A simulation which is advanced by StepOnce is ended.
+// Reset must not be called while the simulation is running.
+func (s *System) Reset() {
+	if s.stepper != nil && !s.stepper.ended {
+		s.closeBlocks()
+	}
+	s.stepper = nil
+	s.resetStop()
+	for _, c := range s.connections {
+		s.connect(c)
+	}
+	for _, spy := range s.spies {
+		spy.reset(s)
+	}
+	for _, b := range s.blocks {
+		if r, ok := b.Block.(Resetter); ok {
+			r.Reset()
+		}
+	}
+}
+
+// NewCascade returns a block, which steps first and then second
+// with the outputs of first, in the same goroutine.
+// The outputs of first must match the inputs of second.
+// The optional interfaces Validator, DTSetter, Resetter, ClockAware and
+// io.Closer are passed on to both blocks. The cascade is a DelayBlock,
+// if one of them is. Vector blocks are not supported.
+func NewCascade(first, second Block) (Block, error) {
+	if first.Outputs() != second.Inputs() {
+		return nil, fmt.Errorf("cascade: %s has %d outputs, %s has %d inputs", typeName(first), first.Outputs(), typeName(second), second.Inputs())
+	}
+	for _, b := range []Block{first, second} {
+		if _, ok := b.(VectorBlock); ok {
+			return nil, fmt.Errorf("cascade: %s is a vector block", typeName(b))
+		}
+	}
+	return &cascade{first: first, second: second, buf: make([]float64, first.Outputs())}, nil
+}
+
+// cascade is the synchronous composition of two blocks, see NewCascade.
+type cascade struct {
+	first, second Block
+	buf           []float64 // outputs of first
+}
+
+func (b *cascade) Validate() error {
+	for _, c := range []Block{b.first, b.second} {
+		if v, ok := c.(Validator); ok {
+			if err := v.Validate(); err != nil {
+				return fmt.Errorf("cascade: %v", err)
+			}
+		}
+	}
 	return nil
 }
+func (b *cascade) SetDT(dt float64) {
+	for _, c := range []Block{b.first, b.second} {
+		if d, ok := c.(DTSetter); ok {
+			d.SetDT(dt)
+		}
+	}
+}
+func (b *cascade) Reset() {
+	for _, c := range []Block{b.first, b.second} {
+		if r, ok := c.(Resetter); ok {
+			r.Reset()
+		}
+	}
+}
+func (b *cascade) SetClock(clock *Clock) {
+	for _, c := range []Block{b.first, b.second} {
+		if a, ok := c.(ClockAware); ok {
+			a.SetClock(clock)
+		}
+	}
+}
+func (b *cascade) Close() error {
+	var err error
+	for _, c := range []Block{b.first, b.second} {
+		if cl, ok := c.(io.Closer); ok {
+			if e := cl.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+func (b *cascade) IsDelay() bool {
+	for _, c := range []Block{b.first, b.second} {
+		if d, ok := c.(DelayBlock); ok && d.IsDelay() {
+			return true
+		}
+	}
+	return false
+}
+
+// Clone copies both blocks like System.Clone.
+// If one of them cannot be copied, the copy shares the cascade.
+// System.Clone uses clone instead, which returns the error.
+func (b *cascade) Clone() Block {
+	c, err := b.clone()
+	if err != nil {
+		return b
+	}
+	return c
+}
+func (b *cascade) clone() (Block, error) {
+	first, err := cloneBlock(b.first)
+	if err != nil {
+		return nil, fmt.Errorf("cascade: %v", err)
+	}
+	second, err := cloneBlock(b.second)
+	if err != nil {
+		return nil, fmt.Errorf("cascade: %v", err)
+	}
+	return &cascade{first: first, second: second, buf: make([]float64, len(b.buf))}, nil
+}
+func (b *cascade) InputNames() []string {
+	in, _ := portNames(b.first)
+	return in
+}
+func (b *cascade) OutputNames() []string {
+	_, out := portNames(b.second)
+	return out
+}
+func (b *cascade) Inputs() int  { return b.first.Inputs() }
+func (b *cascade) Outputs() int { return b.second.Outputs() }
+func (b *cascade) Step(in, out []float64) bool {
+	return b.first.Step(in, b.buf) && b.second.Step(b.buf, out)
+}
+
+// NewFeedbackLoop returns a system, which controls the plant with
+// negative feedback. It's input is the reference r and it's output the
+// plant output y, which is fed back with a delay of one step:
+//
+//	r -> Subtract -> controller -> plant -> Tee -> y
+//	        ^                               |
+//	        +-------------------------------+
+//
+// The feedback starts with y = 0. Both blocks must have one input and one output.
+// The returned system is meant to be used as a block of another system.
+func NewFeedbackLoop(plant, controller Block) (*System, error) {
+	for _, b := range []Block{plant, controller} {
+		if b.Inputs() != 1 || b.Outputs() != 1 {
+			return nil, fmt.Errorf("feedback loop: %s has %d inputs and %d outputs, want 1 and 1", typeName(b), b.Inputs(), b.Outputs())
+		}
+	}
+	var s System
+	s.AddInputPort(0)     // 0
+	s.Add(Subtract{})     // 1
+	s.Add(controller)     // 2
+	s.Add(plant)          // 3
+	s.Add(Tee{})          // 4
+	s.AddOutputPort(0)    // 5
+	s.Connect(0, 1, 0, 0) // r -> sub
+	s.Connect(1, 2, 0, 0) // sub -> controller
+	s.Connect(2, 3, 0, 0) // controller -> plant
+	s.Connect(3, 4, 0, 0) // plant -> tee
+	s.Connect(4, 5, 0, 0) // tee -> y
+	s.Connect(4, 1, 1, 1) // tee -> sub
+	s.AddIC(0, 1, 1)      // y0 to sub
+	return &s, nil
+}
@@ -0,0 +1,239 @@
+package loops
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestStartCancel cancels a simulation without a Stop block
+// and checks that no goroutines are left running.
+func TestStartCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	var s System
+	s.Add(Source(1))                 // 0
+	s.Add(&Integrate{})              // 1
+	s.Add(&Recorder{NumChannels: 1}) // 2
+	s.Connect(0, 1, 0, 0)            // source -> inte
+	s.Connect(1, 2, 0, 0)            // inte -> rec
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Start(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("%d goroutines are still running, %d before the simulation", after, before)
+	}
+}
+
+// TestSystemDT runs two systems with different time steps concurrently.
+func TestSystemDT(t *testing.T) {
+	dts := []float64{0.01, 0.001}
+	scopes := []Scope{{NumChannels: 1}, {NumChannels: 1}}
+	errs := make(chan error, len(dts))
+	for k, dt := range dts {
+		s := ode1System(&scopes[k], &Stop{Time: 1})
+		s.DT = dt
+		go func() { errs <- s.Start(context.Background()) }()
+	}
+	for range dts {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for k, dt := range dts {
+		sc := scopes[k]
+		if n := len(sc.Time); math.Abs(float64(n)*dt-1) > 0.05 {
+			t.Fatalf("dt %v: got %d samples, want %v", dt, n, 1/dt)
+		}
+		if step := sc.Time[1] - sc.Time[0]; math.Abs(step-dt) > 1e-12 {
+			t.Fatalf("dt %v: scope time step is %v", dt, step)
+		}
+		x := sc.Data[0][len(sc.Data[0])-1]
+		if want := math.Exp(-1); math.Abs(x-want) > 0.01 {
+			t.Fatalf("dt %v: x(1) = %v, want %v", dt, x, want)
+		}
+	}
+}
+
+// TestReset runs the 1st order system twice with different initial states.
+func TestReset(t *testing.T) {
+	scope := Scope{NumChannels: 1}
+	s := ode1System(&scope, &Stop{Time: 1})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	first := append([]float64(nil), scope.Data[0]...)
+
+	s.Reset()
+	s.Block(0).(*Integrate).State = 2
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	first, second := truncate(t, first, scope.Data[0])
+	if equal(first, second) {
+		t.Fatal("second trajectory equals the first")
+	}
+	if second[0] <= first[0] {
+		t.Fatalf("second run starts at %v, first at %v", second[0], first[0])
+	}
+}
+
+// TestMultipleStops runs two independent branches with different stop times.
+// The first stop ends the simulation and all goroutines exit.
+func TestMultipleStops(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	r1, r2 := Recorder{NumChannels: 1}, Recorder{NumChannels: 1}
+	var s System
+	s.Add(Source(1))        // 0
+	s.Add(&Stop{Time: 0.5}) // 1
+	s.Add(&r1)              // 2
+	s.Add(Source(2))        // 3
+	s.Add(&Stop{Time: 1})   // 4
+	s.Add(&r2)              // 5
+	s.Connect(0, 1, 0, 0)   // source -> stop
+	s.Connect(1, 2, 0, 0)   // stop -> r1
+	s.Connect(3, 4, 0, 0)   // source -> stop
+	s.Connect(4, 5, 0, 0)   // stop -> r2
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(r1.Data[0]); n < 45 || n > 50 {
+		t.Fatalf("first branch recorded %d samples", n)
+	}
+	if n := len(r2.Data[0]); n >= 100 {
+		t.Fatalf("second branch recorded %d samples after the first stop", n)
+	}
+
+	// Both stops end at the same time.
+	s.Reset()
+	s.Block(4).(*Stop).Time = 0.5
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for k := 0; runtime.NumGoroutine() > before; k++ {
+		if k == 100 {
+			t.Fatalf("%d goroutines are still running, %d before the simulation", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestProgressFunc runs the 1st order system for 5s with a small time step
+// and records the progress.
+func TestProgressFunc(t *testing.T) {
+	s := ode1System(&Recorder{NumChannels: 1}, &Stop{Time: 5})
+	s.DT = 0.0001
+	s.ProgressInterval = time.Millisecond
+	var times []float64
+	s.ProgressFunc = func(now, tEnd float64) {
+		if tEnd != 5 {
+			t.Errorf("tEnd is %v, want 5", tEnd)
+		}
+		times = append(times, now)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(times) == 0 {
+		t.Fatal("ProgressFunc is not called")
+	}
+	for k := 1; k < len(times); k++ {
+		if times[k] < times[k-1] {
+			t.Fatalf("time decreases: %v", times)
+		}
+	}
+	if last := times[len(times)-1]; math.Abs(last-5) > 0.01 {
+		t.Fatalf("progress ends at %v, want 5", last)
+	}
+}
+
+// TestCascade clips the doubled output of a ramp with a limited gain.
+func TestCascade(t *testing.T) {
+	gain, err := NewCascade(Scale(2), Saturation{Min: -1, Max: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := -2.0; x <= 2; x += 0.125 {
+		want := math.Max(-1, math.Min(1, 2*x))
+		if got := run(gain, 1, x)[0][0]; got != want {
+			t.Fatalf("x=%v: got %v, want %v", x, got, want)
+		}
+	}
+
+	// The cascade passes on the time step and the delay property in a system.
+	sat, _ := NewCascade(&Integrate{}, Saturation{Min: 0, Max: 0.5})
+	rec := Recorder{NumChannels: 1}
+	s := System{DT: 0.02}
+	s.Add(Source(1))      // 0
+	s.Add(sat)            // 1
+	s.Add(&Stop{Time: 1}) // 2
+	s.Add(&rec)           // 3
+	s.Connect(0, 1, 0, 0) // one -> sat
+	s.Connect(1, 2, 0, 0) // sat -> stop
+	s.Connect(2, 3, 0, 0) // stop -> rec
+	if err := s.StartSync(); err != nil {
+		t.Fatal(err)
+	}
+	if x := rec.Data[0]; math.Abs(x[9]-0.2) > 1e-12 || x[len(x)-1] != 0.5 {
+		t.Fatalf("got %v", x)
+	}
+	if d, ok := sat.(DelayBlock); !ok || !d.IsDelay() {
+		t.Fatal("the cascade with an integrator is not a delay")
+	}
+
+	if _, err := NewCascade(Add{}, Add{}); err == nil {
+		t.Fatal("expected an error for mismatched ports")
+	}
+	bad, _ := NewCascade(Scale(1), Saturation{Min: 1, Max: 0})
+	if err := bad.(Validator).Validate(); err == nil {
+		t.Fatal("expected an error for an invalid saturation")
+	}
+}
+
+// TestFeedbackLoop controls an integrator with a proportional controller,
+// which gives the closed loop step response 1 - exp(-Kt).
+func TestFeedbackLoop(t *testing.T) {
+	const K = 5
+	loop, err := NewFeedbackLoop(&Integrate{}, Scale(K))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := Recorder{NumChannels: 1}
+	var s System
+	s.Add(Source(1))      // 0
+	s.Add(loop)           // 1
+	s.Add(&Stop{Time: 1}) // 2
+	s.Add(&rec)           // 3
+	s.Connect(0, 1, 0, 0) // r -> loop
+	s.Connect(1, 2, 0, 0) // loop -> stop
+	s.Connect(2, 3, 0, 0) // stop -> rec
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	y := rec.Data[0]
+	if len(y) < 99 {
+		t.Fatalf("recorded %d steps", len(y))
+	}
+	prev := 0.0
+	for n, v := range y {
+		// The forward Euler solution of y' = K(1 - y).
+		if want := prev + DefaultDT*K*(1-prev); math.Abs(v-want) > 1e-12 {
+			t.Fatalf("step %d: got %v, want %v", n, v, want)
+		}
+		if want := 1 - math.Exp(-K*float64(n+1)*DefaultDT); math.Abs(v-want) > 0.01 {
+			t.Fatalf("step %d: got %v, want %v", n, v, want)
+		}
+		prev = v
+	}
+
+	if _, err := NewFeedbackLoop(Add{}, Scale(1)); err == nil {
+		t.Fatal("expected an error for a plant with two inputs")
+	}
+}
@@ -0,0 +1,159 @@
+package loops
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ExportMatlab writes a MATLAB script, which rebuilds the system with
+// the Control System Toolbox.
+//
+// Linear blocks are written as tf or ss objects, with InputName and
+// OutputName set to the signals they are connected to, and combined by
+// connect. The signal of output o of block k is named bk_outo.
+// Blocks without a MATLAB equivalent, such as sources, sinks and nonlinear
+// blocks, are written as comments. The signals they send to linear blocks
+// become the inputs of the connected model, and the signals they receive
+// its outputs, together with the ports of the system itself.
+// Initial conditions and states have no equivalent and are noted as comments.
+func (s *System) ExportMatlab(w io.Writer) error {
+	var b strings.Builder
+	num := func(x float64) string { return strconv.FormatFloat(x, 'g', -1, 64) }
+	vec := func(x []float64, sep string) string {
+		v := make([]string, len(x))
+		for i := range x {
+			v[i] = num(x[i])
+		}
+		return "[" + strings.Join(v, sep) + "]"
+	}
+	ones := func(n int, sep string) string {
+		v := make([]float64, n)
+		for i := range v {
+			v[i] = 1
+		}
+		return vec(v, sep)
+	}
+	signal := func(k, o int) string { return fmt.Sprintf("b%d_out%d", k, o) }
+
+	// The signal names at the inputs of each block.
+	inputs := make([][]string, len(s.blocks))
+	for k, blk := range s.blocks {
+		inputs[k] = make([]string, len(blk.In))
+		for i := range inputs[k] {
+			inputs[k][i] = fmt.Sprintf("b%d_in%d", k, i)
+		}
+	}
+	var sysIn, sysOut []string
+	for _, c := range s.connections {
+		if c.vector {
+			continue
+		}
+		name := ""
+		if c.o < 0 {
+			name = fmt.Sprintf("in%d", -c.o-1)
+			sysIn = append(sysIn, name)
+		} else {
+			name = signal(c.src, c.o)
+		}
+		if c.i < 0 {
+			sysOut = append(sysOut, name)
+		} else {
+			inputs[c.dst][c.i] = name
+		}
+	}
+
+	// model returns the MATLAB expression of a linear block, or "".
+	model := func(blk Block) string {
+		switch v := blk.(type) {
+		case Scale:
+			return fmt.Sprintf("tf(%s, 1)", num(float64(v)))
+		case Negate:
+			return "tf(-1, 1)"
+		case Add:
+			return "ss([1 1])"
+		case Subtract:
+			return "ss([1 -1])"
+		case AddN:
+			return fmt.Sprintf("ss(%s)", ones(v.N, " "))
+		case Tee:
+			return "ss([1; 1])"
+		case TeeN:
+			return fmt.Sprintf("ss(%s)", ones(v.N, "; "))
+		case *Integrate:
+			return "tf(1, [1 0])"
+		case *TransferFunction:
+			return fmt.Sprintf("tf(%s, %s)", vec(v.Num, " "), vec(v.Den, " "))
+		}
+		return ""
+	}
+	cell := func(names []string) string {
+		q := make([]string, len(names))
+		for i, n := range names {
+			q[i] = "'" + n + "'"
+		}
+		return "{" + strings.Join(q, ", ") + "}"
+	}
+
+	fmt.Fprintf(&b, "%% MATLAB script generated by loops, it needs the Control System Toolbox.\n")
+	fmt.Fprintf(&b, "%% The simulation time step is %s s.\n", num(timeStep(s.DT)))
+	linear := make([]bool, len(s.blocks))
+	var blocks []string
+	for k, blk := range s.blocks {
+		name := typeName(blk.Block)
+		m := model(blk.Block)
+		if m == "" {
+			fmt.Fprintf(&b, "%% %d %s: no MATLAB equivalent\n", k, name)
+			continue
+		}
+		linear[k] = true
+		id := fmt.Sprintf("b%d", k)
+		blocks = append(blocks, id)
+		outputs := make([]string, len(blk.Out))
+		for o := range outputs {
+			outputs[o] = signal(k, o)
+		}
+		fmt.Fprintf(&b, "%s = %s; %% %d %s\n", id, m, k, name)
+		fmt.Fprintf(&b, "%s.InputName = %s;\n", id, cell(inputs[k]))
+		fmt.Fprintf(&b, "%s.OutputName = %s;\n", id, cell(outputs))
+		if v, ok := blk.Block.(*Integrate); ok && v.State != 0 {
+			fmt.Fprintf(&b, "%% The initial state of %s is %s.\n", id, num(v.State))
+		}
+	}
+	for _, ic := range s.initials {
+		fmt.Fprintf(&b, "%% The initial condition %s of input %d of block %d is ignored.\n", num(ic.value), ic.input, ic.block)
+	}
+	if len(blocks) == 0 {
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	// Signals between linear and other blocks are the model's ports.
+	for _, c := range s.connections {
+		if c.vector || c.o < 0 || c.i < 0 || linear[c.src] == linear[c.dst] {
+			continue
+		}
+		if linear[c.dst] {
+			sysIn = append(sysIn, signal(c.src, c.o))
+		} else {
+			sysOut = append(sysOut, signal(c.src, c.o))
+		}
+	}
+	fmt.Fprintf(&b, "sys = connect(%s, %s, %s);\n", strings.Join(blocks, ", "), cell(unique(sysIn)), cell(unique(sysOut)))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// unique removes repeated strings and keeps the order.
+func unique(a []string) []string {
+	var r []string
+	seen := make(map[string]bool)
+	for _, s := range a {
+		if !seen[s] {
+			seen[s] = true
+			r = append(r, s)
+		}
+	}
+	return r
+}
@@ -0,0 +1,57 @@
+package loops
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestExportMatlab exports the 1st order system and checks the script.
+func TestExportMatlab(t *testing.T) {
+	s := ode1System(&Recorder{NumChannels: 1}, &Stop{Time: 1})
+	var buf bytes.Buffer
+	if err := s.ExportMatlab(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	t.Log("\n" + out)
+	for _, want := range []string{
+		"b0 = tf(1, [1 0]); % 0 Integrate\n",
+		"b0.InputName = {'b3_out0'};\n",
+		"b0.OutputName = {'b0_out0'};\n",
+		"% The initial state of b0 is 1.\n",
+		"% 1 Recorder: no MATLAB equivalent\n",
+		"b2 = tf(-1, 1); % 2 Scale\n",
+		"b2.InputName = {'b4_out1'};\n",
+		"b3 = ss([1 1]); % 3 Add\n",
+		"b3.InputName = {'b6_out0', 'b2_out0'};\n",
+		"b4 = ss([1; 1]); % 4 Tee\n",
+		"b4.OutputName = {'b4_out0', 'b4_out1'};\n",
+		"% 5 Source: no MATLAB equivalent\n",
+		"% 6 Stop: no MATLAB equivalent\n",
+		"% The initial condition -1 of input 1 of block 3 is ignored.\n",
+		"sys = connect(b0, b2, b3, b4, {'b6_out0'}, {'b4_out0'});\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+
+	// A sub-system has its ports as inputs and outputs.
+	var sub System
+	sub.AddInputPort(0)                                                   // 0
+	sub.Add(&TransferFunction{Num: []float64{2}, Den: []float64{1, 0.5}}) // 1
+	sub.AddOutputPort(0)                                                  // 2
+	sub.Connect(0, 1, 0, 0)
+	sub.Connect(1, 2, 0, 0)
+	buf.Reset()
+	if err := sub.ExportMatlab(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "b1 = tf([2], [1 0.5]); % 1 TransferFunction\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("missing %q in\n%s", want, buf.String())
+	}
+	if want := "sys = connect(b1, {'b0_out0'}, {'b1_out0'});\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("missing %q in\n%s", want, buf.String())
+	}
+}
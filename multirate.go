@@ -0,0 +1,173 @@
+package loops
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+)
+
+// MultiRateSystem runs blocks at different step rates, e.g. a
+// sensor model at 10 Hz and a controller at 100 Hz.
+//
+// Other than System, the blocks are stepped sequentially in a single
+// goroutine. An event queue holds the next step time of every block,
+// and the simulation time advances to the earliest one.
+// The n'th step of a block with rate r happens at t = n/r, starting at 0.
+// Blocks which are scheduled at the same time are stepped in the order
+// they have been added.
+//
+// The connections hold the last value of their source, so a block which
+// runs slower than it's source gets the most recent output, and a block
+// which runs faster receives the same value until the source updates.
+// Before the first update of a source, the value is 0 or the initial
+// condition of the input. Feedback loops therefore need no initial
+// condition. Vector ports are not supported.
+type MultiRateSystem struct {
+	blocks []rateBlock
+	inputs [][]port // source of each input
+	values [][]float64
+	t      float64
+}
+
+// rateBlock is a block with it's step rate.
+type rateBlock struct {
+	Block
+	rate    float64
+	in, ic  []float64
+	stepped bool // the outputs are valid
+}
+
+// port is an output port of a block, block is -1 for unconnected inputs.
+type port struct {
+	block, index int
+}
+
+// AddWithRate adds a block, which is stepped rate times per second.
+// Blocks which implement DTSetter get 1/rate as their time step.
+func (m *MultiRateSystem) AddWithRate(b Block, rate float64) {
+	m.blocks = append(m.blocks, rateBlock{Block: b, rate: rate, in: make([]float64, b.Inputs()), ic: make([]float64, b.Inputs())})
+	in := make([]port, b.Inputs())
+	for i := range in {
+		in[i] = port{-1, 0}
+	}
+	m.inputs = append(m.inputs, in)
+	m.values = append(m.values, make([]float64, b.Outputs()))
+}
+
+// Block returns the i'th block of the system.
+func (m *MultiRateSystem) Block(i int) Block { return m.blocks[i].Block }
+
+// Connect connects the output o of block src to the input i of block dst.
+func (m *MultiRateSystem) Connect(src, dst, o, i int) {
+	m.inputs[dst][i] = port{src, o}
+}
+
+// AddIC sets the value of input i of block dst, which it receives
+// until it's source has been stepped.
+func (m *MultiRateSystem) AddIC(x float64, dst, i int) {
+	m.blocks[dst].ic[i] = x
+}
+
+// T returns the simulation time of the last step.
+func (m *MultiRateSystem) T() float64 { return m.t }
+
+func (m *MultiRateSystem) check() error {
+	for k, b := range m.blocks {
+		if !(b.rate > 0) || math.IsInf(b.rate, 1) {
+			return fmt.Errorf("multirate: block %d (%s) has an invalid rate %v", k, typeName(b.Block), b.rate)
+		}
+		if v, ok := b.Block.(VectorBlock); ok && v.VectorInputs()+v.VectorOutputs() > 0 {
+			return fmt.Errorf("multirate: block %d (%s) has vector ports", k, typeName(b.Block))
+		}
+		if v, ok := b.Block.(Validator); ok {
+			if err := v.Validate(); err != nil {
+				return fmt.Errorf("multirate: block %d (%s): %v", k, typeName(b.Block), err)
+			}
+		}
+		for i, p := range m.inputs[k] {
+			if p.block < 0 {
+				return fmt.Errorf("multirate: input %d of block %d (%s) is not connected", i, k, typeName(b.Block))
+			}
+			if p.block >= len(m.blocks) || p.index < 0 || p.index >= len(m.values[p.block]) {
+				return fmt.Errorf("multirate: input %d of block %d is connected to a missing output %d of block %d", i, k, p.index, p.block)
+			}
+		}
+	}
+	return nil
+}
+
+// Run steps the blocks until the simulation time reaches tEnd,
+// which is not included, a block's Step function returns false,
+// or ctx is cancelled. In the latter case ctx.Err() is returned.
+// Blocks which implement io.Closer are closed at the end.
+func (m *MultiRateSystem) Run(ctx context.Context, tEnd float64) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	for k := range m.blocks {
+		b := &m.blocks[k]
+		b.stepped = false
+		copy(b.in, b.ic)
+		if d, ok := b.Block.(DTSetter); ok {
+			d.SetDT(1 / b.rate)
+		}
+		defer closeBlock(k, b.Block)
+	}
+
+	q := make(eventQueue, len(m.blocks))
+	for k := range q {
+		q[k] = event{block: k}
+	}
+	heap.Init(&q)
+	for len(q) > 0 {
+		e := &q[0]
+		b := &m.blocks[e.block]
+		if e.t >= tEnd {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		m.t = e.t
+		for i, p := range m.inputs[e.block] {
+			if m.blocks[p.block].stepped {
+				b.in[i] = m.values[p.block][p.index]
+			}
+		}
+		if !b.Step(b.in, m.values[e.block]) {
+			return nil
+		}
+		b.stepped = true
+		// The time is computed from the step count, which does not drift.
+		e.n++
+		e.t = float64(e.n) / b.rate
+		heap.Fix(&q, 0)
+	}
+	return nil
+}
+
+// event is the next step of a block, it is the n'th step at time t.
+type event struct {
+	t        float64
+	n, block int
+}
+
+// eventQueue is a min-heap of events ordered by time and block index.
+type eventQueue []event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].t != q[j].t {
+		return q[i].t < q[j].t
+	}
+	return q[i].block < q[j].block
+}
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x any)   { *q = append(*q, x.(event)) }
+func (q *eventQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,65 @@
+package loops
+
+import (
+	"context"
+	"testing"
+)
+
+// TestMultiRate runs a 10 Hz sensor model and a 100 Hz controller for 1 s.
+func TestMultiRate(t *testing.T) {
+	var m MultiRateSystem
+	sensor, control := Recorder{NumChannels: 1}, Recorder{NumChannels: 1}
+	m.AddWithRate(&RampSource{Slope: 1}, 10) // 0 sensor
+	m.AddWithRate(Tee{}, 10)                 // 1
+	m.AddWithRate(&sensor, 10)               // 2
+	m.AddWithRate(Scale(2), 100)             // 3 controller
+	m.AddWithRate(&control, 100)             // 4
+	m.Connect(0, 1, 0, 0)                    // ramp -> tee
+	m.Connect(1, 2, 0, 0)                    // tee -> sensor
+	m.Connect(1, 3, 1, 0)                    // tee -> controller
+	m.Connect(3, 4, 0, 0)                    // controller -> control
+	if err := m.Run(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	x, y := sensor.Data[0], control.Data[0]
+	if len(x) != 10 || len(y) != 100 {
+		t.Fatalf("recorded %d sensor and %d controller steps", len(x), len(y))
+	}
+	for n, v := range y {
+		// The controller holds the last sensor value.
+		if want := 2 * x[n/10]; v != want {
+			t.Fatalf("controller step %d: got %v, want %v", n, v, want)
+		}
+	}
+	if x[9] != 0.9 || m.T() != 0.99 {
+		t.Fatalf("last sensor value %v at t=%v", x[9], m.T())
+	}
+
+	// The fast block gets the initial condition until the slow one updates.
+	var h MultiRateSystem
+	rec := Recorder{NumChannels: 1}
+	h.AddWithRate(&rec, 100)     // 0
+	h.AddWithRate(Source(3), 10) // 1
+	h.Connect(1, 0, 0, 0)        // src -> rec
+	h.AddIC(-1, 0, 0)
+	for round := 0; round < 2; round++ {
+		rec.Data = nil
+		if err := h.Run(context.Background(), 0.1); err != nil {
+			t.Fatal(err)
+		}
+		if !equal(rec.Data[0], []float64{-1, 3, 3, 3, 3, 3, 3, 3, 3, 3}) {
+			t.Fatalf("round %d: got %v", round, rec.Data[0])
+		}
+	}
+
+	var bad MultiRateSystem
+	bad.AddWithRate(Scale(1), 10)
+	if err := bad.Run(context.Background(), 1); err == nil {
+		t.Fatal("expected an error for an unconnected input")
+	}
+	bad.Connect(0, 0, 0, 0)
+	bad.AddWithRate(Source(0), 0)
+	if err := bad.Run(context.Background(), 1); err == nil {
+		t.Fatal("expected an error for a zero rate")
+	}
+}
@@ -0,0 +1,95 @@
+package loops
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Named ports
+//
+// Blocks and their ports can be addressed by name instead of index.
+// A block is given a name with AddNamed. Port names are returned by the
+// NamedBlock interface, which is implemented by all blocks of this package.
+// Other blocks have the port names in0, in1, ... and out0, out1, ...
+
+// A NamedBlock is a block with names for it's input and output ports.
+// It is an optional interface, which is used by ConnectNamed.
+type NamedBlock interface {
+	InputNames() []string
+	OutputNames() []string
+}
+
+// PortNames returns the names prefix0, prefix1, ... for n ports.
+// Blocks of other packages use it to implement NamedBlock.
+func PortNames(prefix string, n int) []string {
+	r := make([]string, n)
+	for i := range r {
+		r[i] = prefix + strconv.Itoa(i)
+	}
+	return r
+}
+
+// portNames returns the input and output names of b.
+func portNames(b Block) (in, out []string) {
+	if n, ok := b.(NamedBlock); ok {
+		return n.InputNames(), n.OutputNames()
+	}
+	return PortNames("in", b.Inputs()), PortNames("out", b.Outputs())
+}
+
+// AddNamed adds a block to the system, which can be found by name.
+func (s *System) AddNamed(name string, b Block) error {
+	if _, ok := s.names[name]; ok {
+		return fmt.Errorf("block name %q is already used", name)
+	}
+	if s.names == nil {
+		s.names = make(map[string]int)
+	}
+	s.names[name] = len(s.blocks)
+	s.Add(b)
+	return nil
+}
+
+// BlockByName returns the block which has been added with the given name
+// and it's index.
+func (s *System) BlockByName(name string) (Block, int, bool) {
+	i, ok := s.names[name]
+	if !ok {
+		return nil, -1, false
+	}
+	return s.blocks[i].Block, i, true
+}
+
+// ConnectNamed connects the output port srcPort of the block srcName
+// to the input port dstPort of the block dstName.
+func (s *System) ConnectNamed(srcName, srcPort, dstName, dstPort string) error {
+	src, o, err := s.port(srcName, srcPort, false)
+	if err != nil {
+		return err
+	}
+	dst, i, err := s.port(dstName, dstPort, true)
+	if err != nil {
+		return err
+	}
+	s.Connect(src, dst, o, i)
+	return nil
+}
+
+// port returns the index of the named block and it's input or output port.
+func (s *System) port(name, port string, input bool) (int, int, error) {
+	b, k, ok := s.BlockByName(name)
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown block %q", name)
+	}
+	in, out := portNames(b)
+	names, kind := out, "output"
+	if input {
+		names, kind = in, "input"
+	}
+	for i, n := range names {
+		if n == port {
+			return k, i, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("block %q (%s) has no %s %q, it has %q", name, typeName(b), kind, port, names)
+}
@@ -0,0 +1,67 @@
+package loops
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestConnectNamed wires the 1st order system of TestOde1 by name.
+func TestConnectNamed(t *testing.T) {
+	var s System
+	rec := Recorder{NumChannels: 1}
+	for _, b := range []struct {
+		name string
+		b    Block
+	}{
+		{"inte", &Integrate{State: 1}},
+		{"tee", Tee{}},
+		{"neg", Scale(-1)},
+		{"add", Add{}},
+		{"zeros", Source(0)},
+		{"stop", &Stop{Time: 1}},
+		{"rec", &rec},
+	} {
+		if err := s.AddNamed(b.name, b.b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range [][4]string{
+		{"inte", "out", "tee", "in"},
+		{"tee", "out0", "rec", "in0"},
+		{"tee", "out1", "neg", "in"},
+		{"neg", "out", "add", "in1"},
+		{"zeros", "out", "stop", "in"},
+		{"stop", "out", "add", "in0"},
+		{"add", "out", "inte", "in"},
+	} {
+		if err := s.ConnectNamed(c[0], c[1], c[2], c[3]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, add, ok := s.BlockByName("add")
+	if !ok || add != 3 {
+		t.Fatalf("BlockByName: %d %v", add, ok)
+	}
+	s.AddIC(-1, add, 1)
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	x := rec.Data[0]
+	tEnd := float64(len(x)) * DefaultDT
+	if last := x[len(x)-1]; math.Abs(last-math.Exp(-tEnd)) > 0.01 {
+		t.Fatalf("x(%v) = %v, want %v", tEnd, last, math.Exp(-tEnd))
+	}
+
+	if err := s.AddNamed("add", Add{}); err == nil {
+		t.Fatal("expected an error for a duplicate name")
+	}
+	if err := s.ConnectNamed("nosuch", "out", "add", "in0"); err == nil {
+		t.Fatal("expected an error for an unknown block")
+	}
+	err := s.ConnectNamed("tee", "out2", "add", "in0")
+	if err == nil || !strings.Contains(err.Error(), `"out0" "out1"`) {
+		t.Fatalf("expected an error listing the outputs, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package loops
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+)
+
+// Optimizer holds the options of Optimize.
+type Optimizer struct {
+	Population int // Candidates per generation, 20 if it is 0, at least 2.
+}
+
+// Optimize searches the parameters within bounds which minimize the sum
+// of all objectives with the default options, see Optimizer.Optimize.
+func Optimize(sysFactory func(params []float64) *System, bounds [][2]float64, objectives []func(*System) float64, maxEval int) ([]float64, error) {
+	return Optimizer{}.Optimize(sysFactory, bounds, objectives, maxEval)
+}
+
+// Optimize searches the parameters within bounds which minimize the sum
+// of all objectives.
+//
+// For every candidate parameter set, sysFactory builds a new system,
+// which is run to completion. Each objective is then called with the
+// finished system, e.g. to inspect a Recorder. Objectives with different
+// weights should scale their results accordingly.
+//
+// The search is a simple genetic algorithm with o.Population
+// candidates per generation, which are simulated in parallel.
+// It stops after maxEval simulations and returns the best parameters.
+func (o Optimizer) Optimize(sysFactory func(params []float64) *System, bounds [][2]float64, objectives []func(*System) float64, maxEval int) ([]float64, error) {
+	if len(bounds) == 0 {
+		return nil, fmt.Errorf("optimize: no parameters")
+	}
+	for i, b := range bounds {
+		if b[0] > b[1] {
+			return nil, fmt.Errorf("optimize: parameter %d: lower bound %v exceeds upper bound %v", i, b[0], b[1])
+		}
+	}
+	np := o.Population
+	if np == 0 {
+		np = 20
+	}
+	np = max(np, 2)
+	if maxEval < np {
+		return nil, fmt.Errorf("optimize: maxEval %d is smaller than the population %d", maxEval, np)
+	}
+	rng := rand.New(rand.NewPCG(1, 0))
+
+	type candidate struct {
+		params []float64
+		cost   float64
+	}
+
+	// evaluate runs all candidates in parallel.
+	evaluate := func(pop []candidate) error {
+		var wg sync.WaitGroup
+		errs := make([]error, len(pop))
+		for k := range pop {
+			wg.Add(1)
+			go func(k int) {
+				defer wg.Done()
+				s := sysFactory(pop[k].params)
+				if err := s.Start(context.Background()); err != nil {
+					errs[k] = err
+					return
+				}
+				cost := 0.0
+				for _, f := range objectives {
+					cost += f(s)
+				}
+				if math.IsNaN(cost) {
+					cost = math.Inf(1)
+				}
+				pop[k].cost = cost
+			}(k)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		sort.Slice(pop, func(i, j int) bool { return pop[i].cost < pop[j].cost })
+		return nil
+	}
+
+	// clamp keeps parameter i within it's bounds.
+	clamp := func(i int, x float64) float64 {
+		return math.Max(bounds[i][0], math.Min(bounds[i][1], x))
+	}
+
+	// The first generation is uniformly distributed.
+	pop := make([]candidate, np)
+	for k := range pop {
+		pop[k].params = make([]float64, len(bounds))
+		for i, b := range bounds {
+			pop[k].params[i] = b[0] + rng.Float64()*(b[1]-b[0])
+		}
+	}
+	if err := evaluate(pop); err != nil {
+		return nil, err
+	}
+
+	// Each generation keeps the better half and replaces the rest
+	// with mutated crossovers of the survivors.
+	// The mutation width shrinks with the number of generations.
+	width := 0.1
+	for n := np; n+np/2 <= maxEval; n += np / 2 {
+		elite := np - np/2
+		children := make([]candidate, np/2)
+		for k := range children {
+			a, b := pop[rng.IntN(elite)].params, pop[rng.IntN(elite)].params
+			p := make([]float64, len(bounds))
+			for i := range p {
+				w := rng.Float64()
+				p[i] = w*a[i] + (1-w)*b[i]
+				p[i] = clamp(i, p[i]+rng.NormFloat64()*width*(bounds[i][1]-bounds[i][0]))
+			}
+			children[k].params = p
+		}
+		if err := evaluate(children); err != nil {
+			return nil, err
+		}
+		pop = append(pop[:elite], children...)
+		sort.SliceStable(pop, func(i, j int) bool { return pop[i].cost < pop[j].cost })
+		width *= 0.9
+	}
+	return pop[0].params, nil
+}
@@ -0,0 +1,53 @@
+package loops
+
+import (
+	"math"
+	"testing"
+)
+
+// TestOptimize finds the decay rate k of x' = -k x, for which x(1) = exp(-2).
+func TestOptimize(t *testing.T) {
+	factory := func(p []float64) *System {
+		var s System
+		s.Add(&Integrate{State: 1})      // 0
+		s.Add(Tee{})                     // 1
+		s.Add(&Recorder{NumChannels: 1}) // 2
+		s.Add(Scale(-p[0]))              // 3
+		s.Add(&Stop{Time: 1})            // 4
+		s.Connect(0, 1, 0, 0)            // inte -> tee
+		s.Connect(1, 2, 0, 0)            // tee -> rec
+		s.Connect(1, 3, 1, 0)            // tee -> scale
+		s.Connect(3, 4, 0, 0)            // scale -> stop
+		s.Connect(4, 0, 0, 0)            // stop -> inte
+		s.AddIC(1, 3, 0)
+		return &s
+	}
+	final := func(s *System) float64 {
+		x := s.Block(2).(*Recorder).Data[0]
+		d := x[len(x)-1] - math.Exp(-2)
+		return d * d
+	}
+	p, err := Optimize(factory, [][2]float64{{0, 10}}, []func(*System) float64{final}, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(p[0]-2) > 0.2 {
+		t.Fatalf("got k = %v, want 2", p[0])
+	}
+
+	// A larger population needs more evaluations.
+	if _, err := (Optimizer{Population: 50}).Optimize(factory, [][2]float64{{0, 10}}, nil, 40); err == nil {
+		t.Fatal("expected an error for fewer evaluations than the population")
+	}
+	p, err = Optimizer{Population: 10}.Optimize(factory, [][2]float64{{0, 10}}, []func(*System) float64{final}, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(p[0]-2) > 0.2 {
+		t.Fatalf("population 10: got k = %v, want 2", p[0])
+	}
+
+	if _, err := Optimize(factory, [][2]float64{{1, 0}}, nil, 200); err == nil {
+		t.Fatal("expected an error for invalid bounds")
+	}
+}
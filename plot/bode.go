@@ -0,0 +1,157 @@
+package plot
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/cmplx"
+	"sort"
+
+	"github.com/ktye/loops"
+)
+
+// BodePlot is the frequency response of a system,
+// which is measured by Bode.
+type BodePlot struct {
+	Freq     []float64 // Frequencies in Hz.
+	GainDB   []float64 // Magnitude in dB.
+	PhaseDeg []float64 // Phase in degrees, within (-180, 180].
+	Size     image.Point
+}
+
+// Bode measures the frequency response of sys from the output of
+// inputBlock to the first output of outputBlock.
+//
+// The input block must be a *loops.SineSource. For each frequency,
+// it clones the system, sets the frequency and an amplitude of 1 for the
+// sine source of the clone and runs it with the time step dt.
+// The simulation time is 20 periods of the lowest frequency.
+// The first half is left for the system to settle. In the second half,
+// the amplitude and phase of input and output are measured by a
+// cross-correlation with a sine and a cosine over whole periods.
+// The input block must have a single output, and sys must not
+// contain a Stop block, see System.Simulate.
+func Bode(sys *loops.System, inputBlock, outputBlock int, freqHz []float64, dt float64) (*BodePlot, error) {
+	if len(freqHz) == 0 {
+		return nil, fmt.Errorf("bode: no frequencies")
+	}
+	fmin := math.Inf(1)
+	for _, f := range freqHz {
+		if f <= 0 || f*dt >= 0.5 {
+			return nil, fmt.Errorf("bode: frequency %v Hz is not within (0, %v)", f, 0.5/dt)
+		}
+		fmin = math.Min(fmin, f)
+	}
+	tEnd := 20 / fmin
+
+	if inputBlock < 0 || inputBlock >= sys.NumBlocks() {
+		return nil, fmt.Errorf("bode: input block %d does not exist", inputBlock)
+	}
+	if _, ok := sys.Block(inputBlock).(*loops.SineSource); !ok {
+		return nil, fmt.Errorf("bode: input block %d is not a *loops.SineSource", inputBlock)
+	}
+
+	b := &BodePlot{Size: image.Point{512, 256}}
+	for _, f := range freqHz {
+		c, err := sys.Clone()
+		if err != nil {
+			return nil, fmt.Errorf("bode: %v", err)
+		}
+		sine := c.Block(inputBlock).(*loops.SineSource)
+		sine.Amplitude, sine.Frequency = 1, f
+		c.DT = dt
+		t, signals, err := c.Simulate(tEnd, []int{inputBlock, outputBlock})
+		if err != nil {
+			return nil, fmt.Errorf("bode: %v Hz: %v", f, err)
+		}
+
+		// Correlate whole periods at the end.
+		periods := math.Floor(t[len(t)-1] / 2 * f)
+		n := int(math.Round(periods / f / dt))
+		if periods < 1 || n < 2 {
+			return nil, fmt.Errorf("bode: %v Hz: simulation is too short", f)
+		}
+		start := len(t) - n
+		u := correlate(signals[inputBlock][0][start:], t[start:], f)
+		y := correlate(signals[outputBlock][0][start:], t[start:], f)
+		if u == 0 {
+			return nil, fmt.Errorf("bode: %v Hz: no input signal", f)
+		}
+		h := y / u
+		b.Freq = append(b.Freq, f)
+		b.GainDB = append(b.GainDB, 20*math.Log10(cmplx.Abs(h)))
+		b.PhaseDeg = append(b.PhaseDeg, cmplx.Phase(h)*180/math.Pi)
+	}
+	return b, nil
+}
+
+// correlate returns the complex amplitude a+ib of x = a*sin(wt) + b*cos(wt).
+func correlate(x, t []float64, f float64) complex128 {
+	var s, c float64
+	for k, v := range x {
+		sin, cos := math.Sincos(2 * math.Pi * f * t[k])
+		s += v * sin
+		c += v * cos
+	}
+	n := float64(len(x))
+	return complex(2*s/n, 2*c/n)
+}
+
+// Write stores the magnitude and phase as a png file with two panels
+// over a logarithmic frequency axis.
+func (b *BodePlot) Write(filename string) error {
+	if len(b.Freq) == 0 {
+		return fmt.Errorf("bode: no data")
+	}
+	size := b.Size
+	if size.X <= 0 || size.Y <= 0 {
+		size = image.Point{512, 256}
+	}
+	gain := &Plot{NumChannels: 1, Size: size, Grid: true, XLabel: "log f"}
+	phase := &Plot{NumChannels: 1, Size: size, Grid: true, XLabel: "log f"}
+	gain.Scale, phase.Scale = 1, 180
+	for _, g := range b.GainDB {
+		gain.Scale = math.Max(gain.Scale, math.Abs(g))
+	}
+	freq, gainDB, phaseDeg := b.sorted()
+	lo, hi := math.Log10(freq[0]), math.Log10(freq[len(freq)-1])
+	gain.Title = fmt.Sprintf("gain db, %.3g hz - %.3g hz, +-%.3g", freq[0], freq[len(freq)-1], gain.Scale)
+	phase.Title = "phase deg, +-180"
+
+	// One value per pixel column, interpolated at log f.
+	m := MultiPlot{Plots: []*Plot{gain, phase}}
+	for x := 0; x < size.X; x++ {
+		lf := lo + (hi-lo)*float64(x)/float64(size.X-1)
+		m.Step([]float64{interpolate(freq, gainDB, lf), interpolate(freq, phaseDeg, lf)}, nil)
+	}
+	return m.Write(filename)
+}
+
+// sorted returns the data ordered by frequency.
+func (b *BodePlot) sorted() (freq, gainDB, phaseDeg []float64) {
+	idx := make([]int, len(b.Freq))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool { return b.Freq[idx[i]] < b.Freq[idx[j]] })
+	for _, i := range idx {
+		freq = append(freq, b.Freq[i])
+		gainDB = append(gainDB, b.GainDB[i])
+		phaseDeg = append(phaseDeg, b.PhaseDeg[i])
+	}
+	return freq, gainDB, phaseDeg
+}
+
+// interpolate returns y at log10(f) = lf, linear in log f.
+func interpolate(f, y []float64, lf float64) float64 {
+	if len(f) == 1 || lf <= math.Log10(f[0]) {
+		return y[0]
+	}
+	for k := 1; k < len(f); k++ {
+		l0, l1 := math.Log10(f[k-1]), math.Log10(f[k])
+		if lf <= l1 {
+			return y[k-1] + (y[k]-y[k-1])*(lf-l0)/(l1-l0)
+		}
+	}
+	return y[len(y)-1]
+}
@@ -0,0 +1,54 @@
+package plot
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/ktye/loops"
+)
+
+// TestBode measures a first order low pass with a corner frequency of 1 Hz,
+// which has a slope of -20 dB per decade.
+func TestBode(t *testing.T) {
+	tau := 1 / (2 * math.Pi)
+	var s loops.System
+	s.Add(&loops.SineSource{})             // 0
+	s.Add(loops.Subtract{})                // 1
+	s.Add(loops.Scale(1 / tau))            // 2
+	s.Add(&loops.Integrate{})              // 3
+	s.Add(loops.Tee{})                     // 4
+	s.Add(&loops.Recorder{NumChannels: 1}) // 5
+	s.Connect(0, 1, 0, 0)                  // input -> sub
+	s.Connect(1, 2, 0, 0)                  // sub -> scale
+	s.Connect(2, 3, 0, 0)                  // scale -> inte
+	s.Connect(3, 4, 0, 0)                  // inte -> tee
+	s.Connect(4, 1, 0, 1)                  // tee -> sub
+	s.Connect(4, 5, 1, 0)                  // tee -> rec
+	s.AddIC(0, 1, 1)
+
+	b, err := Bode(&s, 0, 3, []float64{100, 10}, 1e-4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range b.Freq {
+		wantGain := 20 * math.Log10(1/math.Hypot(1, f))
+		wantPhase := -math.Atan(f) * 180 / math.Pi
+		t.Logf("%v Hz: %.3f dB (%.3f), %.2f deg (%.2f)", f, b.GainDB[i], wantGain, b.PhaseDeg[i], wantPhase)
+		if math.Abs(b.GainDB[i]-wantGain) > 0.3 || math.Abs(b.PhaseDeg[i]-wantPhase) > 5 {
+			t.Errorf("%v Hz: got %v dB, %v deg", f, b.GainDB[i], b.PhaseDeg[i])
+		}
+	}
+	if slope := b.GainDB[0] - b.GainDB[1]; math.Abs(slope+20) > 0.5 {
+		t.Errorf("slope is %v dB per decade", slope)
+	}
+	if sine := s.Block(0).(*loops.SineSource); sine.Frequency != 0 {
+		t.Error("the original system is changed")
+	}
+	if _, err := Bode(&s, 2, 3, []float64{1}, 1e-4); err == nil {
+		t.Error("expected an error for an input block, which is not a sine source")
+	}
+	if err := b.Write(filepath.Join(t.TempDir(), "bode.png")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,64 @@
+package plot
+
+import (
+	"image"
+	"image/color"
+	"strings"
+)
+
+// The labels are drawn with a tiny bitmap font.
+// Each glyph has 5 rows of 3 pixels, the bits of each row are the
+// pixels from left to right. Lower case letters are drawn as upper case.
+var glyphs = map[rune][5]uint8{
+	'A': {2, 5, 7, 5, 5}, 'B': {6, 5, 6, 5, 6}, 'C': {3, 4, 4, 4, 3}, 'D': {6, 5, 5, 5, 6},
+	'E': {7, 4, 6, 4, 7}, 'F': {7, 4, 6, 4, 4}, 'G': {3, 4, 5, 5, 3}, 'H': {5, 5, 7, 5, 5},
+	'I': {7, 2, 2, 2, 7}, 'J': {1, 1, 1, 5, 2}, 'K': {5, 5, 6, 5, 5}, 'L': {4, 4, 4, 4, 7},
+	'M': {5, 7, 7, 5, 5}, 'N': {6, 5, 5, 5, 5}, 'O': {2, 5, 5, 5, 2}, 'P': {6, 5, 6, 4, 4},
+	'Q': {2, 5, 5, 6, 3}, 'R': {6, 5, 6, 5, 5}, 'S': {3, 4, 2, 1, 6}, 'T': {7, 2, 2, 2, 2},
+	'U': {5, 5, 5, 5, 7}, 'V': {5, 5, 5, 5, 2}, 'W': {5, 5, 7, 7, 5}, 'X': {5, 5, 2, 5, 5},
+	'Y': {5, 5, 2, 2, 2}, 'Z': {7, 1, 2, 4, 7},
+	'0': {7, 5, 5, 5, 7}, '1': {2, 6, 2, 2, 7}, '2': {6, 1, 2, 4, 7}, '3': {6, 1, 2, 1, 6},
+	'4': {5, 5, 7, 1, 1}, '5': {7, 4, 6, 1, 6}, '6': {3, 4, 7, 5, 7}, '7': {7, 1, 2, 2, 2},
+	'8': {7, 5, 7, 5, 7}, '9': {7, 5, 7, 1, 6},
+	' ': {}, '-': {0, 0, 7, 0, 0}, '.': {0, 0, 0, 0, 2}, ',': {0, 0, 0, 2, 4},
+	':': {0, 2, 0, 2, 0}, '=': {0, 7, 0, 7, 0}, '+': {0, 2, 7, 2, 0}, '(': {1, 2, 2, 2, 1},
+	')': {4, 2, 2, 2, 4}, '/': {1, 1, 2, 4, 4}, '_': {0, 0, 0, 0, 7}, '?': {6, 1, 2, 0, 2},
+}
+
+// fontScale is the size of a font pixel in image pixels.
+const fontScale = 2
+
+// charWidth and charHeight are the size of a character including the spacing.
+const (
+	charWidth  = 4 * fontScale
+	charHeight = 6 * fontScale
+)
+
+// textWidth returns the width of s in pixels.
+func textWidth(s string) int {
+	return len([]rune(s)) * charWidth
+}
+
+// drawText draws s with the top left corner at (x, y).
+// Characters without a glyph are drawn as a question mark.
+func drawText(img *image.RGBA, x, y int, s string, c color.Color) {
+	for _, r := range strings.ToUpper(s) {
+		g, ok := glyphs[r]
+		if !ok {
+			g = glyphs['?']
+		}
+		for row, bits := range g {
+			for col := 0; col < 3; col++ {
+				if bits&(4>>col) == 0 {
+					continue
+				}
+				for dy := 0; dy < fontScale; dy++ {
+					for dx := 0; dx < fontScale; dx++ {
+						img.Set(x+col*fontScale+dx, y+row*fontScale+dy, c)
+					}
+				}
+			}
+		}
+		x += charWidth
+	}
+}
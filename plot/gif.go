@@ -0,0 +1,149 @@
+package plot
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"os"
+	"sort"
+)
+
+// snapshot stores a copy of the current image as a frame for WriteGIF.
+func (p *Plot) snapshot() {
+	img := image.NewRGBA(p.img.Bounds())
+	copy(img.Pix, p.img.Pix)
+	p.frames = append(p.frames, img)
+}
+
+// WriteGIF stores the snapshots as an animated gif file,
+// which shows the signals growing.
+// Snapshots are taken every SnapshotEvery steps. If the last step has
+// not been captured, the final image is added as the last frame.
+// The frame delay is 1/framesPerSecond, rounded to the gif resolution
+// of 10ms.
+// The colors are reduced to a palette of at most 256 colors
+// with the median cut algorithm.
+// It must be called manually at the end of the simulation.
+func (p *Plot) WriteGIF(filename string, framesPerSecond int) error {
+	if p.img == nil {
+		return fmt.Errorf("plot: no data")
+	}
+	if p.SnapshotEvery <= 0 {
+		return fmt.Errorf("plot: no snapshots, SnapshotEvery is %d", p.SnapshotEvery)
+	}
+	if framesPerSecond <= 0 {
+		return fmt.Errorf("plot: frames per second must be positive: %d", framesPerSecond)
+	}
+	frames := p.frames
+	if p.x%p.SnapshotEvery != 0 {
+		frames = append(frames[:len(frames):len(frames)], p.img)
+	}
+	delay := max(1, (100+framesPerSecond/2)/framesPerSecond)
+
+	// The last frame contains all colors.
+	palette := medianCut(frames[len(frames)-1], 256)
+	g := gif.GIF{LoopCount: 0}
+	for _, img := range frames {
+		pi := image.NewPaletted(img.Bounds(), palette)
+		draw.Draw(pi, pi.Bounds(), img, image.Point{}, draw.Src)
+		g.Image = append(g.Image, pi)
+		g.Delay = append(g.Delay, delay)
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := gif.EncodeAll(f, &g); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// medianCut returns a palette of at most n colors for img.
+// The box of colors with the largest range is split at the median
+// of it's widest channel, until there are n boxes.
+// Each box contributes the mean of it's colors, weighted by their count.
+func medianCut(img *image.RGBA, n int) color.Palette {
+	counts := make(map[color.RGBA]int)
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		c := color.RGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3]}
+		counts[c]++
+	}
+	type entry struct {
+		c color.RGBA
+		n int
+	}
+	all := make([]entry, 0, len(counts))
+	for c, k := range counts {
+		all = append(all, entry{c, k})
+	}
+	// Sort for reproducible palettes.
+	sort.Slice(all, func(i, j int) bool {
+		a, b := all[i].c, all[j].c
+		return a.R < b.R || a.R == b.R && (a.G < b.G || a.G == b.G && (a.B < b.B || a.B == b.B && a.A < b.A))
+	})
+	channel := func(c color.RGBA, k int) uint8 { return [4]uint8{c.R, c.G, c.B, c.A}[k] }
+
+	// widest returns the channel with the largest range and the range.
+	widest := func(box []entry) (int, int) {
+		var ch, width int
+		for k := 0; k < 4; k++ {
+			lo, hi := 255, 0
+			for _, e := range box {
+				v := int(channel(e.c, k))
+				lo, hi = min(lo, v), max(hi, v)
+			}
+			if hi-lo > width {
+				ch, width = k, hi-lo
+			}
+		}
+		return ch, width
+	}
+
+	boxes := [][]entry{all}
+	for len(boxes) < n {
+		b, ch, width := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if k, w := widest(box); w > width {
+				b, ch, width = i, k, w
+			}
+		}
+		if b < 0 {
+			break
+		}
+		box := boxes[b]
+		sort.SliceStable(box, func(i, j int) bool { return channel(box[i].c, ch) < channel(box[j].c, ch) })
+		// Split at the median pixel, keeping both halves non-empty.
+		total, half, m := 0, 0, 0
+		for _, e := range box {
+			total += e.n
+		}
+		for m < len(box)-1 && half+box[m].n <= total/2 {
+			half += box[m].n
+			m++
+		}
+		m = max(1, m)
+		boxes = append(boxes, box[m:])
+		boxes[b] = box[:m]
+	}
+
+	palette := make(color.Palette, len(boxes))
+	for i, box := range boxes {
+		var r, g, b, a, total int
+		for _, e := range box {
+			r += int(e.c.R) * e.n
+			g += int(e.c.G) * e.n
+			b += int(e.c.B) * e.n
+			a += int(e.c.A) * e.n
+			total += e.n
+		}
+		palette[i] = color.RGBA{uint8(r / total), uint8(g / total), uint8(b / total), uint8(a / total)}
+	}
+	return palette
+}
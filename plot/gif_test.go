@@ -0,0 +1,92 @@
+package plot
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWriteGIF captures every 3rd of 10 steps and the final image.
+func TestWriteGIF(t *testing.T) {
+	p := Plot{NumChannels: 2, Size: image.Point{40, 20}, SnapshotEvery: 3}
+	for k := 0; k < 10; k++ {
+		p.Step([]float64{float64(k) / 10, -0.5}, nil)
+	}
+	name := filepath.Join(t.TempDir(), "plot.gif")
+	if err := p.WriteGIF(name, 20); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 4 {
+		t.Fatalf("got %d frames, want 4", len(g.Image))
+	}
+	for i, d := range g.Delay {
+		if d != 5 {
+			t.Fatalf("frame %d: delay %d, want 5", i, d)
+		}
+	}
+
+	// The last frame has the pixels of both channels, the first one
+	// only the first 3 steps.
+	last, first := g.Image[3], g.Image[0]
+	if c := color.RGBAModel.Convert(last.At(9, p.Size.Y/2-int(0.9*float64(p.Size.Y)/2))); c != Colors[0] {
+		t.Errorf("last frame: got %v, want %v", c, Colors[0])
+	}
+	if c := color.RGBAModel.Convert(first.At(5, p.Size.Y/2+p.Size.Y/4)); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("first frame: got %v, want white", c)
+	}
+
+	for _, q := range []*Plot{
+		{NumChannels: 1},
+		{NumChannels: 1, SnapshotEvery: 0, img: p.img},
+	} {
+		if q.WriteGIF(name, 20) == nil {
+			t.Errorf("expected an error for %+v", q)
+		}
+	}
+	if p.WriteGIF(name, 0) == nil {
+		t.Error("expected an error for 0 frames per second")
+	}
+}
+
+// TestMedianCut reduces a gradient of 1024 colors to 256.
+func TestMedianCut(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 32; y++ {
+			img.Set(x, y, color.RGBA{uint8(8 * x), uint8(8 * y), 128, 255})
+		}
+	}
+	p := medianCut(img, 256)
+	if len(p) != 256 {
+		t.Fatalf("got %d colors", len(p))
+	}
+	// Every pixel is close to it's palette color.
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 32; y++ {
+			c := img.RGBAAt(x, y)
+			q := p[p.Index(c)].(color.RGBA)
+			if d := max(absDiff(c.R, q.R), absDiff(c.G, q.G)); d > 16 {
+				t.Fatalf("pixel %d,%d: %v is mapped to %v", x, y, c, q)
+			}
+		}
+	}
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
@@ -0,0 +1,72 @@
+package plot
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+)
+
+// MultiPlot stacks several plots vertically in a single image.
+// It is a terminal block, whose inputs are the channels of all plots in order.
+type MultiPlot struct {
+	Plots []*Plot
+}
+
+// SetDT passes the time step to all plots.
+func (m *MultiPlot) SetDT(dt float64) {
+	for _, p := range m.Plots {
+		p.SetDT(dt)
+	}
+}
+func (m *MultiPlot) Inputs() int {
+	n := 0
+	for _, p := range m.Plots {
+		n += p.NumChannels
+	}
+	return n
+}
+func (m *MultiPlot) Outputs() int {
+	return 0
+}
+func (m *MultiPlot) Step(in, out []float64) bool {
+	for _, p := range m.Plots {
+		if !p.Step(in[:p.NumChannels], nil) {
+			return false
+		}
+		in = in[p.NumChannels:]
+	}
+	return true
+}
+
+// Image returns the panels stacked from top to bottom.
+// The width is the one of the widest panel.
+func (m *MultiPlot) Image() *image.RGBA {
+	w, h := 0, 0
+	for _, p := range m.Plots {
+		if p.img == nil {
+			p.init()
+		}
+		w, h = max(w, p.Size.X), h+p.Size.Y
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	y := 0
+	for _, p := range m.Plots {
+		draw.Draw(img, p.img.Bounds().Add(image.Point{0, y}), p.img, image.Point{}, draw.Src)
+		y += p.Size.Y
+	}
+	return img
+}
+
+// Write stores the stacked image as a png file.
+// It must be called manually at the end of the simulation.
+func (m *MultiPlot) Write(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, m.Image())
+}
@@ -20,18 +20,34 @@ var Colors []color.Color = []color.Color{
 	color.RGBA{255, 255, 0, 255},
 }
 
-// Plot is a terminal block which writes a png image.
+// Plot is a terminal block which writes a png or svg image.
 // The plot is very primitive: a pixel per value.
 // The x-axis is stretched horizontally at the center of the image
-// with one pixel per DT.
+// with one pixel per time step.
+// The title, axis labels and the legend of the png image
+// are drawn with a tiny font, which knows only upper case letters,
+// digits and a few punctuation characters.
 type Plot struct {
-	NumChannels int         // Number of input channels.
-	Scale       float64     // Y-axis contains data from [-Scale,+Scale]
-	Size        image.Point // Image dimensions.
-	img         *image.RGBA // Image structure.
-	x           int         // current x pixel position
+	NumChannels   int           // Number of input channels.
+	Scale         float64       // Y-axis contains data from [-Scale,+Scale]
+	Size          image.Point   // Image dimensions.
+	Title         string        // Title at the top center.
+	XLabel        string        // Label of the x-axis at the bottom right.
+	YLabel        string        // Label of the y-axis at the top left.
+	Grid          bool          // Draw dashed lines at +-Scale/4 and +-Scale/2.
+	ChannelLabels []string      // Legend entries per channel, drawn at the top left.
+	SnapshotEvery int           // Capture a frame for WriteGIF every n steps, none if 0.
+	img           *image.RGBA   // Image structure.
+	frames        []*image.RGBA // snapshots for WriteGIF
+	x             int           // current x pixel position
+	data          [][]float64   // samples per channel for WriteSVG
+	dt            float64       // time step
 }
 
+// SetDT is called by the system to tell the time step,
+// which is used for the time axis label of the svg output.
+func (p *Plot) SetDT(dt float64) { p.dt = dt }
+
 func (p *Plot) Inputs() int {
 	return p.NumChannels
 }
@@ -41,34 +57,83 @@ func (p *Plot) Outputs() int {
 func (p *Plot) Step(in, out []float64) bool {
 	// At the first step, initialize the image.
 	if p.img == nil {
-		width, height := p.Size.X, p.Size.Y
-		if width <= 0 || height <= 0 {
-			p.Size = image.Point{512, 512}
-		}
-		p.img = image.NewRGBA(image.Rect(0, 0, p.Size.X, p.Size.Y))
-		draw.Draw(p.img, p.img.Bounds(), &image.Uniform{color.White}, image.ZP, draw.Src)
-
-		// Set default y-scale to [+1,-1].
-		if p.Scale == 0 {
-			p.Scale = 1
-		}
-
-		// Draw the x-axis.
-		for i := 0; i < p.Size.X; i++ {
-			p.img.Set(i, p.Size.Y/2, color.Black)
-		}
+		p.init()
 	}
 
 	// Draw one pixel per input channel, in it's own color.
+	if p.data == nil {
+		p.data = make([][]float64, len(in))
+	}
 	for i, v := range in {
-		y := p.Size.Y/2 - int(v*float64(p.Size.Y)/(2*p.Scale))
+		p.data[i] = append(p.data[i], v)
+		y := p.row(v)
 		fmt.Println("Plot:", v, y, p.Size)
 		p.img.Set(p.x, y, Colors[i%len(Colors)])
 	}
 	p.x++
+	if p.SnapshotEvery > 0 && p.x%p.SnapshotEvery == 0 {
+		p.snapshot()
+	}
 	return true
 }
 
+// row returns the pixel row of the value v.
+func (p *Plot) row(v float64) int {
+	return p.Size.Y/2 - int(v*float64(p.Size.Y)/(2*p.Scale))
+}
+
+// init creates the image with the axis, grid and labels.
+func (p *Plot) init() {
+	width, height := p.Size.X, p.Size.Y
+	if width <= 0 || height <= 0 {
+		p.Size = image.Point{512, 512}
+	}
+	p.img = image.NewRGBA(image.Rect(0, 0, p.Size.X, p.Size.Y))
+	draw.Draw(p.img, p.img.Bounds(), &image.Uniform{color.White}, image.ZP, draw.Src)
+
+	// Set default y-scale to [+1,-1].
+	if p.Scale == 0 {
+		p.Scale = 1
+	}
+
+	// Draw dashed grid lines.
+	if p.Grid {
+		gray := color.Gray{192}
+		for _, f := range []float64{-0.5, -0.25, 0.25, 0.5} {
+			y := p.row(f * p.Scale)
+			for i := 0; i < p.Size.X; i++ {
+				if i%8 < 4 {
+					p.img.Set(i, y, gray)
+				}
+			}
+		}
+	}
+
+	// Draw the x-axis.
+	for i := 0; i < p.Size.X; i++ {
+		p.img.Set(i, p.Size.Y/2, color.Black)
+	}
+
+	// Draw the labels and the legend.
+	drawText(p.img, (p.Size.X-textWidth(p.Title))/2, 2, p.Title, color.Black)
+	drawText(p.img, p.Size.X-textWidth(p.XLabel)-2, p.Size.Y/2+4, p.XLabel, color.Black)
+	y := 2 + charHeight
+	if p.YLabel != "" {
+		drawText(p.img, 2, y, p.YLabel, color.Black)
+		y += charHeight
+	}
+	for i, label := range p.ChannelLabels {
+		c := Colors[i%len(Colors)]
+		for x := 2; x < 2+2*charWidth; x++ {
+			for dy := 0; dy < fontScale; dy++ {
+				p.img.Set(x, y+charHeight/2-fontScale+dy, c)
+			}
+		}
+		drawText(p.img, 2+3*charWidth, y, label, color.Black)
+		y += charHeight
+	}
+}
+
 // Write stores the image as a png file.
 // It must be called manually at the end of the simulation.
 func (p *Plot) Write(filename string) error {
@@ -0,0 +1,94 @@
+package plot
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLegend checks that the legend is drawn in the top left corner
+// with the channel colors, only if labels are set.
+func TestLegend(t *testing.T) {
+	colors := func(p *Plot) map[color.RGBA]int {
+		for k := 0; k < 10; k++ {
+			p.Step([]float64{0, 0}, nil)
+		}
+		r := make(map[color.RGBA]int)
+		for y := 0; y < 40; y++ {
+			for x := 0; x < 80; x++ {
+				r[p.img.RGBAAt(x, y)]++
+			}
+		}
+		return r
+	}
+	blue, green := Colors[0].(color.RGBA), Colors[1].(color.RGBA)
+	black := color.RGBA{0, 0, 0, 255}
+
+	c := colors(&Plot{NumChannels: 2, Size: image.Point{200, 100}, ChannelLabels: []string{"x", "dx/dt"}})
+	if c[blue] == 0 || c[green] == 0 || c[black] == 0 {
+		t.Fatalf("legend is missing: %v", c)
+	}
+	c = colors(&Plot{NumChannels: 2, Size: image.Point{200, 100}})
+	if c[blue] != 0 || c[green] != 0 || c[black] != 0 {
+		t.Fatalf("unexpected legend: %v", c)
+	}
+}
+
+// TestGrid checks the dashed lines at ±Scale/4 and ±Scale/2,
+// which are the rows 16 and 8 pixels above and below the axis.
+func TestGrid(t *testing.T) {
+	p := Plot{NumChannels: 1, Scale: 2, Size: image.Point{64, 64}, Grid: true}
+	p.init()
+	gray := color.RGBA{192, 192, 192, 255}
+	for y := 0; y < 64; y++ {
+		want := y == 16 || y == 24 || y == 40 || y == 48
+		if got := p.img.RGBAAt(1, y) == gray; got != want {
+			t.Errorf("row %d: gray is %v", y, got)
+		}
+		if p.img.RGBAAt(5, y) == gray {
+			t.Errorf("row %d: the grid line is not dashed", y)
+		}
+	}
+	if p.row(0.5) != 24 || p.row(-1) != 48 {
+		t.Fatalf("the values ±Scale/4 and -Scale/2 are at the rows %d and %d", p.row(0.5), p.row(-1))
+	}
+}
+
+// TestMultiPlot stacks two panels and checks the image size and the axes.
+func TestMultiPlot(t *testing.T) {
+	m := MultiPlot{Plots: []*Plot{
+		{NumChannels: 1, Size: image.Point{100, 50}, Title: "x", Grid: true},
+		{NumChannels: 2, Size: image.Point{80, 60}},
+	}}
+	if n := m.Inputs(); n != 3 {
+		t.Fatalf("got %d inputs, want 3", n)
+	}
+	for k := 0; k < 20; k++ {
+		m.Step([]float64{0.5, -0.5, 1}, nil)
+	}
+	name := filepath.Join(t.TempDir(), "multi.png")
+	if err := m.Write(name); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 110 {
+		t.Fatalf("image is %v, want 100x110", b)
+	}
+	// The x-axis of each panel is at it's center.
+	for _, y := range []int{25, 50 + 30} {
+		if r, g, b, _ := img.At(50, y).RGBA(); r != 0 || g != 0 || b != 0 {
+			t.Fatalf("no axis at y=%d", y)
+		}
+	}
+}
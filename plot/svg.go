@@ -0,0 +1,71 @@
+package plot
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"os"
+)
+
+// WriteSVG stores the plot as an svg file.
+// It uses the same colors and scaling as the png image, with one
+// polyline per channel. The x-axis, grid lines at half the scale
+// and labels for the time range and the y-scale are included.
+// It must be called manually at the end of the simulation.
+func (p *Plot) WriteSVG(filename string) error {
+	if p.img == nil {
+		return fmt.Errorf("plot: no data")
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	W, H := p.Size.X, p.Size.Y
+	y := func(v float64) float64 { return float64(H)/2 - v*float64(H)/(2*p.Scale) }
+	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", W, H, W, H)
+	fmt.Fprintf(w, "<rect width=\"%d\" height=\"%d\" fill=\"white\"/>\n", W, H)
+
+	// Grid lines at +-Scale/2 and every tenth of the width.
+	for _, v := range []float64{-p.Scale / 2, p.Scale / 2} {
+		fmt.Fprintf(w, "<line x1=\"0\" y1=\"%g\" x2=\"%d\" y2=\"%g\" stroke=\"lightgray\"/>\n", y(v), W, y(v))
+	}
+	for i := 1; i < 10; i++ {
+		x := i * W / 10
+		fmt.Fprintf(w, "<line x1=\"%d\" y1=\"0\" x2=\"%d\" y2=\"%d\" stroke=\"lightgray\"/>\n", x, x, H)
+	}
+	fmt.Fprintf(w, "<line x1=\"0\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"black\"/>\n", H/2, W, H/2)
+
+	for i, data := range p.data {
+		fmt.Fprintf(w, "<polyline fill=\"none\" stroke=\"%s\" points=\"", hex(Colors[i%len(Colors)]))
+		for x, v := range data {
+			if x > 0 {
+				w.WriteByte(' ')
+			}
+			fmt.Fprintf(w, "%d,%g", x, y(v))
+		}
+		fmt.Fprintf(w, "\"/>\n")
+	}
+
+	// Labels: y-scale at the top and bottom, the time range on the x-axis.
+	n := len(p.data[0])
+	fmt.Fprintf(w, "<text x=\"2\" y=\"12\" font-size=\"10\">%g</text>\n", p.Scale)
+	fmt.Fprintf(w, "<text x=\"2\" y=\"%d\" font-size=\"10\">%g</text>\n", H-2, -p.Scale)
+	fmt.Fprintf(w, "<text x=\"2\" y=\"%d\" font-size=\"10\">0</text>\n", H/2-2)
+	if p.dt > 0 {
+		fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\" font-size=\"10\" text-anchor=\"end\">t=%g</text>\n", W-2, H/2-2, float64(n)*p.dt)
+	} else {
+		fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\" font-size=\"10\" text-anchor=\"end\">%d steps</text>\n", W-2, H/2-2, n)
+	}
+	fmt.Fprintf(w, "</svg>\n")
+	return w.Flush()
+}
+
+// hex returns the color c as #rrggbb.
+func hex(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
@@ -0,0 +1,52 @@
+package plot
+
+import (
+	"encoding/xml"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWriteSVG plots two channels and checks the polylines of the svg file.
+func TestWriteSVG(t *testing.T) {
+	p := Plot{NumChannels: 2, Size: image.Point{100, 50}}
+	p.SetDT(0.1)
+	for k := 0; k < 20; k++ {
+		p.Step([]float64{float64(k) / 20, -0.5}, nil)
+	}
+	name := filepath.Join(t.TempDir(), "plot.svg")
+	if err := p.WriteSVG(name); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var svg struct {
+		Polylines []struct {
+			Stroke string `xml:"stroke,attr"`
+			Points string `xml:"points,attr"`
+		} `xml:"polyline"`
+		Lines []struct{} `xml:"line"`
+		Texts []string   `xml:"text"`
+	}
+	if err := xml.Unmarshal(b, &svg); err != nil {
+		t.Fatalf("%v\n%s", err, b)
+	}
+	if len(svg.Polylines) != 2 {
+		t.Fatalf("got %d polylines, want 2", len(svg.Polylines))
+	}
+	if s := svg.Polylines[0].Stroke; s != "#0000ff" {
+		t.Fatalf("first channel has color %s", s)
+	}
+	if p := svg.Polylines[1].Points; p[:7] != "0,37.5 " {
+		t.Fatalf("second channel starts with %q", p)
+	}
+	if len(svg.Lines) == 0 || len(svg.Texts) == 0 {
+		t.Fatalf("axis or labels are missing:\n%s", b)
+	}
+	if (&Plot{}).WriteSVG(name) == nil {
+		t.Fatal("expected an error for an empty plot")
+	}
+}
@@ -0,0 +1,56 @@
+package loops
+
+import "time"
+
+// BlockProfile is the execution time of a block's Step function,
+// which is measured, if profiling is enabled.
+type BlockProfile struct {
+	BlockIndex    int
+	TypeName      string
+	Calls         int64
+	TotalDuration time.Duration
+	MaxDuration   time.Duration
+}
+
+// add adds the duration of a call.
+func (p *BlockProfile) add(d time.Duration) {
+	p.Calls++
+	p.TotalDuration += d
+	if d > p.MaxDuration {
+		p.MaxDuration = d
+	}
+}
+
+// EnableProfiling measures the time of each Step call in the following
+// simulation runs. The result is returned by ProfilingReport.
+func (s *System) EnableProfiling() {
+	s.profiling = true
+}
+
+// ProfilingReport returns the profile of every block for the last
+// simulation run, indexed by block number.
+// It must not be called while the simulation is running.
+// It returns nil, if profiling is not enabled.
+func (s *System) ProfilingReport() []BlockProfile {
+	return append([]BlockProfile(nil), s.profile...)
+}
+
+// startProfile clears the profile at the start of a simulation run.
+func (s *System) startProfile() {
+	if !s.profiling {
+		s.profile = nil
+		return
+	}
+	s.profile = make([]BlockProfile, len(s.blocks))
+	for k, b := range s.blocks {
+		s.profile[k] = BlockProfile{BlockIndex: k, TypeName: typeName(b.Block)}
+	}
+}
+
+// blockProfile returns the profile of block k, or nil if profiling is disabled.
+func (s *System) blockProfile(k int) *BlockProfile {
+	if s.profile == nil {
+		return nil
+	}
+	return &s.profile[k]
+}
@@ -0,0 +1,61 @@
+package loops
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// slowBlock passes it's input after sleeping.
+type slowBlock struct{}
+
+func (b slowBlock) Inputs() int  { return 1 }
+func (b slowBlock) Outputs() int { return 1 }
+func (b slowBlock) Step(in, out []float64) bool {
+	time.Sleep(time.Millisecond)
+	out[0] = in[0]
+	return true
+}
+
+// TestProfiling finds the slow block as the bottleneck.
+func TestProfiling(t *testing.T) {
+	var s System
+	s.Add(Source(1))         // 0
+	s.Add(&Stop{Time: 0.05}) // 1
+	s.Add(slowBlock{})       // 2
+	s.Add(Scale(2))          // 3
+	s.Add(discard{})         // 4
+	s.Connect(0, 1, 0, 0)    // ones -> stop
+	s.Connect(1, 2, 0, 0)    // stop -> slow
+	s.Connect(2, 3, 0, 0)    // slow -> scale
+	s.Connect(3, 4, 0, 0)    // scale -> discard
+	if r := s.ProfilingReport(); r != nil {
+		t.Fatalf("profiling is not enabled: %v", r)
+	}
+	s.EnableProfiling()
+	for _, start := range []func() error{
+		func() error { return s.Start(context.Background()) },
+		s.StartSync,
+	} {
+		s.Reset()
+		if err := start(); err != nil {
+			t.Fatal(err)
+		}
+		r := s.ProfilingReport()
+		if len(r) != 5 {
+			t.Fatalf("got %d profiles", len(r))
+		}
+		slowest := 0
+		for k, p := range r {
+			if p.BlockIndex != k {
+				t.Fatalf("profile %d has index %d", k, p.BlockIndex)
+			}
+			if p.TotalDuration > r[slowest].TotalDuration {
+				slowest = k
+			}
+		}
+		if p := r[slowest]; slowest != 2 || p.TypeName != "slowBlock" || p.Calls < 4 || p.MaxDuration < time.Millisecond {
+			t.Fatalf("bottleneck is %+v", p)
+		}
+	}
+}
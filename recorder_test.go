@@ -0,0 +1,62 @@
+package loops
+
+import (
+	"math"
+	"testing"
+)
+
+// TestRecorder records a ramp and checks down- and resampling.
+func TestRecorder(t *testing.T) {
+	rec := Recorder{NumChannels: 1}
+	for i := 0; i < 10; i++ {
+		rec.Step([]float64{float64(i)}, nil)
+	}
+
+	d := rec.Downsample(3)
+	if got, want := d.Data[0], []float64{0, 3, 6, 9}; !equal(got, want) {
+		t.Fatalf("downsample: got %v, want %v", got, want)
+	}
+
+	// Doubling the rate of a ramp interpolates half steps.
+	r, err := rec.Resample(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(r.Data[0]); n != 19 {
+		t.Fatalf("resample: got %d samples, want 19", n)
+	}
+	for k, v := range r.Data[0] {
+		if math.Abs(v-float64(k)/2) > 1e-12 {
+			t.Fatalf("resample: sample %d is %v, want %v", k, v, float64(k)/2)
+		}
+	}
+
+	if _, err := rec.Resample(0, 1); err == nil {
+		t.Fatal("expected an error for a zero rate")
+	}
+}
+
+func equal(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// truncate shortens a and b to their common length.
+// The number of samples of two concurrent runs, which are terminated by
+// a Stop block, may differ by one: the blocks after the stop are still
+// running when it's context is cancelled. It fails, if they differ by more.
+func truncate(t *testing.T, a, b []float64) ([]float64, []float64) {
+	t.Helper()
+	if d := len(a) - len(b); d < -1 || d > 1 {
+		t.Fatalf("%d and %d samples", len(a), len(b))
+	}
+	n := min(len(a), len(b))
+	return a[:n], b[:n]
+}
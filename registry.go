@@ -0,0 +1,111 @@
+package loops
+
+import "fmt"
+
+// A Registry holds block constructors by type name.
+// It is used by System.UnmarshalJSON and NewSystemFromDOT
+// to create blocks from their type names.
+//
+// NewRegistry returns a registry with all built-in blocks, which
+// applications extend with their own types by calling Register.
+type Registry map[string]func() Block
+
+// NewRegistry returns a registry with all blocks of this package, which
+// can be configured by their exported fields. Blocks that need a function,
+// such as MathFunc and CallbackSink, are not included.
+func NewRegistry() Registry {
+	r := make(Registry)
+	for _, f := range []func() Block{
+		func() Block { return Abs{} },
+		func() Block { return Add{} },
+		func() Block { return AddN{} },
+		func() Block { return Ceiling{} },
+		func() Block { return Deadband{} },
+		func() Block { return DemuxN{} },
+		func() Block { return Divide{} },
+		func() Block { return GainScheduler{} },
+		func() Block { return Lookup1D{} },
+		func() Block { return Lookup2D{} },
+		func() Block { return MulN{} },
+		func() Block { return MultiSwitch{} },
+		func() Block { return Multiply{} },
+		func() Block { return MuxN{} },
+		func() Block { return Negate{} },
+		func() Block { return Polynomial{} },
+		func() Block { return Polynomial2D{} },
+		func() Block { return Quantizer{} },
+		func() Block { return Saturation{} },
+		func() Block { return Scale(0) },
+		func() Block { return Sign{} },
+		func() Block { return Source(0) },
+		func() Block { return Sqrt{} },
+		func() Block { return Square{} },
+		func() Block { return Subtract{} },
+		func() Block { return Switch{} },
+		func() Block { return Tee{} },
+		func() Block { return TeeN{} },
+		func() Block { return Truncate{} },
+		func() Block { return &BiquadIIR{} },
+		func() Block { return &CSVSink{} },
+		func() Block { return &CSVSource{} },
+		func() Block { return &Convolution{} },
+		func() Block { return &ChirpSource{} },
+		func() Block { return &Deadtime{} },
+		func() Block { return &Delay{} },
+		func() Block { return &Derivative{} },
+		func() Block { return &FrequencyMeter{} },
+		func() Block { return &GainSchedulerPID{} },
+		func() Block { return &GaussianRandom{} },
+		func() Block { return &Hysteresis{} },
+		func() Block { return &ImpulseSource{} },
+		func() Block { return &ImpulseResponseCapture{} },
+		func() Block { return &InputPort{} },
+		func() Block { return &Integrate{} },
+		func() Block { return &MedianFilter{} },
+		func() Block { return &MinMax{} },
+		func() Block { return &MovingAverage{} },
+		func() Block { return &OutputPort{} },
+		func() Block { return &PRBSSource{} },
+		func() Block { return &Print{} },
+		func() Block { return &RampSource{} },
+		func() Block { return &RateLimiter{} },
+		func() Block { return &Recorder{} },
+		func() Block { return &Relay{} },
+		func() Block { return &RunningMax{} },
+		func() Block { return &RunningMin{} },
+		func() Block { return &SampleAndHold{} },
+		func() Block { return &SawtoothSource{} },
+		func() Block { return &Scope{} },
+		func() Block { return &ScopeReplay{} },
+		func() Block { return &SineSource{} },
+		func() Block { return &SquareSource{} },
+		func() Block { return &Statistics{} },
+		func() Block { return &Stop{} },
+		func() Block { return &System{} },
+		func() Block { return &TransferFunction{} },
+		func() Block { return &UniformNoise{} },
+		func() Block { return &UniformRandom{} },
+		func() Block { return &WhiteNoise{} },
+		func() Block { return &ZeroCrossing{} },
+	} {
+		r.Register(typeName(f()), f)
+	}
+	return r
+}
+
+// Register adds the constructor for blocks with the given type name.
+// It replaces a previously registered one.
+// The name must match the type name without package qualifier,
+// which is used by MarshalJSON.
+func (r Registry) Register(name string, factory func() Block) {
+	r[name] = factory
+}
+
+// NewBlock returns a new block of the registered type name.
+func (r Registry) NewBlock(name string) (Block, error) {
+	f, ok := r[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown block type %q", name)
+	}
+	return f(), nil
+}
@@ -0,0 +1,159 @@
+package loops
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// relaxation solves x' = u - x with x0 = 1 and u = 0.
+// An Integrate block is used in the feedback loop of ode1System, other
+// integrators compute the derivative with their model function F.
+// It returns the scope, which records x(t+dt) at the times t.
+func relaxation(t *testing.T, b Block, stopTime float64) *Scope {
+	scope := &Scope{NumChannels: 1}
+	s := ode1System(scope, &Stop{Time: stopTime})
+	if _, ok := b.(*Integrate); !ok {
+		s = &System{}
+		s.Add(Source(0))             // 0
+		s.Add(b)                     // 1
+		s.Add(&Stop{Time: stopTime}) // 2
+		s.Add(scope)                 // 3
+		s.Connect(0, 1, 0, 0)        // zeros -> inte
+		s.Connect(1, 2, 0, 0)        // inte -> stop
+		s.Connect(2, 3, 0, 0)        // stop -> scope
+	}
+	if err := s.StartSync(); err != nil {
+		t.Fatal(err)
+	}
+	return scope
+}
+
+// maxErr returns the maximum error of the recording with respect to exp(-t).
+func maxErr(scope *Scope) (e float64) {
+	for k, tk := range scope.Time {
+		e = math.Max(e, math.Abs(scope.Data[0][k]-math.Exp(-tk-DefaultDT)))
+	}
+	return e
+}
+
+// TestRK4 compares Euler and RK4 integration of the relaxation equation
+// with the analytical solution at the same time step.
+func TestRK4(t *testing.T) {
+	calls := 0
+	f := func(x, u float64) float64 { calls++; return u - x }
+	b := &RK4{F: f, State: 1}
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	euler := relaxation(t, &Integrate{State: 1}, 2)
+	rk4 := relaxation(t, b, 2)
+	eulerErr, rk4Err := maxErr(euler), maxErr(rk4)
+	t.Logf("max error: euler %.3g, rk4 %.3g", eulerErr, rk4Err)
+
+	// One system step is one time step with four evaluations.
+	// The integrator steps once more than the scope, when the stop ends it.
+	if n := len(rk4.Time); n < 190 || n != len(euler.Time) {
+		t.Fatalf("rk4 recorded %d steps, euler %d", n, len(euler.Time))
+	}
+	if calls != 4*(len(rk4.Time)+1) {
+		t.Fatalf("%d evaluations for %d steps", calls, len(rk4.Time))
+	}
+	if rk4Err*100 > eulerErr {
+		t.Fatalf("rk4 error %v is not 100 times smaller than euler error %v", rk4Err, eulerErr)
+	}
+	if (&RK4{}).Validate() == nil {
+		t.Error("expected an error for a nil F")
+	}
+}
+
+// TestAdamsBashforth4 compares the error and the number of evaluations
+// of Euler, RK4 and Adams-Bashforth integration of the relaxation equation.
+func TestAdamsBashforth4(t *testing.T) {
+	var rk4Calls, ab4Calls int
+	euler := relaxation(t, &Integrate{State: 1}, 2)
+	rk4 := relaxation(t, &RK4{State: 1, F: func(x, u float64) float64 { rk4Calls++; return u - x }}, 2)
+	ab4 := relaxation(t, &AdamsBashforth4{State: 1, F: func(x, u float64) float64 { ab4Calls++; return u - x }}, 2)
+	eulerErr, rk4Err, ab4Err := maxErr(euler), maxErr(rk4), maxErr(ab4)
+	t.Logf("max error: euler %.3g, rk4 %.3g, ab4 %.3g", eulerErr, rk4Err, ab4Err)
+	t.Logf("evaluations: euler %d, rk4 %d, ab4 %d", len(euler.Time), rk4Calls, ab4Calls)
+
+	// The bootstrap takes 3 additional evaluations for each of the first 3 steps.
+	if n := len(ab4.Time); n < 190 || ab4Calls != n+1+9 || !(ab4Calls < rk4Calls) {
+		t.Fatalf("ab4 made %d evaluations for %d steps, rk4 %d", ab4Calls, n, rk4Calls)
+	}
+	if ab4Err*100 > eulerErr {
+		t.Fatalf("ab4 error %v is not 100 times smaller than euler error %v", ab4Err, eulerErr)
+	}
+
+	// 4th order: halving the time step reduces the error by 16.
+	b := AdamsBashforth4{F: func(x, u float64) float64 { return -x }}
+	final := func(dt float64, n int) float64 {
+		b.State = 1
+		b.Reset()
+		b.SetDT(dt)
+		var out [1]float64
+		for k := 0; k < n; k++ {
+			b.Step([]float64{0}, out[:])
+		}
+		return b.State
+	}
+	e1 := math.Abs(final(0.1, 20) - math.Exp(-2))
+	e2 := math.Abs(final(0.05, 40) - math.Exp(-2))
+	if r := e1 / e2; r < 12 || r > 20 {
+		t.Fatalf("error ratio %v for half the time step", r)
+	}
+	if (&AdamsBashforth4{}).Validate() == nil {
+		t.Error("expected an error for a nil F")
+	}
+}
+
+// TestAdaptiveIntegrate solves the stiff equation x' = -500(x - u)
+// for a constant input u = 1 with two tolerances.
+func TestAdaptiveIntegrate(t *testing.T) {
+	const lambda = 500
+	f := func(x, u float64) float64 { return -lambda * (x - u) }
+	maxErr := func(tol float64) float64 {
+		b := &AdaptiveIntegrate{F: f, Tol: tol}
+		if err := b.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		e := 0.0
+		for n, x := range run(b, 20, 1) {
+			tn := float64(n+1) * DefaultDT
+			e = math.Max(e, math.Abs(x[0]-(1-math.Exp(-lambda*tn))))
+		}
+		return e
+	}
+	coarse, fine := maxErr(1e-3), maxErr(1e-8)
+	t.Logf("max error: tol 1e-3: %.3g, tol 1e-8: %.3g", coarse, fine)
+	if !(fine*100 < coarse) || coarse > 0.01 {
+		t.Fatalf("errors %v and %v", coarse, fine)
+	}
+
+	// One system step is one time step.
+	var s System
+	rec := Recorder{NumChannels: 1}
+	s.Add(Source(1))                           // 0
+	s.Add(&AdaptiveIntegrate{F: f, Tol: 1e-6}) // 1
+	s.Add(&Stop{Time: 0.1})                    // 2
+	s.Add(&rec)                                // 3
+	s.Connect(0, 1, 0, 0)                      // src -> inte
+	s.Connect(1, 2, 0, 0)                      // inte -> stop
+	s.Connect(2, 3, 0, 0)                      // stop -> rec
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rec.Data[0]); n < 9 || n > 10 {
+		t.Fatalf("recorded %d steps", n)
+	}
+	for _, b := range []*AdaptiveIntegrate{
+		{Tol: 1},
+		{F: f},
+		{F: f, Tol: 1, MinDT: 0.1, MaxDT: 0.01},
+	} {
+		if b.Validate() == nil {
+			t.Errorf("expected an error for %+v", *b)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package loops
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"math"
+	"testing"
+)
+
+// TestScope runs the 1st order system with a Scope and compares
+// the recorded decay with the analytical solution exp(-t).
+func TestScope(t *testing.T) {
+	var scope = Scope{NumChannels: 1}
+	var s System
+	s.Add(&Integrate{State: 1}) // 0
+	s.Add(&scope)               // 1
+	s.Add(Scale(-1))            // 2
+	s.Add(Add{})                // 3
+	s.Add(Tee{})                // 4
+	s.Add(Source(0))            // 5
+	s.Add(&Stop{Time: 1})       // 6
+	s.Connect(0, 4, 0, 0)       // inte -> tee
+	s.Connect(4, 1, 0, 0)       // tee -> scope
+	s.Connect(4, 2, 1, 0)       // tee -> neg
+	s.Connect(2, 3, 0, 1)       // neg -> add
+	s.Connect(5, 6, 0, 0)       // zeros -> stop
+	s.Connect(6, 3, 0, 0)       // stop -> add
+	s.Connect(3, 0, 0, 0)       // add -> inte
+	s.AddIC(-1, 3, 1)           // -x0 to add
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scope.Time) != len(scope.Data[0]) || len(scope.Time) < 90 {
+		t.Fatalf("got %d times and %d samples", len(scope.Time), len(scope.Data[0]))
+	}
+	for k, x := range scope.Data[0] {
+		// The integrator's output at step k is the state at the end of the step.
+		want := math.Exp(-(scope.Time[k] + DefaultDT))
+		if math.Abs(x-want) > 0.01*want {
+			t.Fatalf("x(%v) = %v, want %v", scope.Time[k]+DefaultDT, x, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := scope.CSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1+len(scope.Time) || rows[0][0] != "t" || rows[0][1] != "y0" {
+		t.Fatalf("unexpected csv: %d rows, header %v", len(rows), rows[0])
+	}
+}
@@ -0,0 +1,188 @@
+package loops
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Simulate runs the system until stopTime and returns all outputs
+// of the blocks with the given indexes.
+// signals[k][o] are the samples of output o of block k at the times t.
+//
+// Simulate runs on a copy of the system, see Clone, so it is not changed
+// and can be simulated again. It wires a Scope for each block into the copy,
+// together with a Stop block on the first recorded signal. Connected outputs
+// are split with a Tee. The system must not contain another Stop block which
+// ends the simulation earlier. Spies are not copied and are not called.
+func (s *System) Simulate(stopTime float64, outputBlocks []int) (t []float64, signals map[int][][]float64, err error) {
+	c, err := s.Clone()
+	if err != nil {
+		return nil, nil, fmt.Errorf("simulate: %v", err)
+	}
+	return c.simulate(stopTime, outputBlocks)
+}
+
+// simulate implements Simulate and adds the blocks to s.
+func (s *System) simulate(stopTime float64, outputBlocks []int) (t []float64, signals map[int][][]float64, err error) {
+	if len(outputBlocks) == 0 {
+		return nil, nil, fmt.Errorf("simulate: no output blocks")
+	}
+	for _, k := range outputBlocks {
+		if k < 0 || k >= len(s.blocks) {
+			return nil, nil, fmt.Errorf("simulate: block %d does not exist", k)
+		}
+		if s.blocks[k].Block.Outputs() == 0 {
+			return nil, nil, fmt.Errorf("simulate: block %d (%s) has no outputs", k, typeName(s.blocks[k].Block))
+		}
+	}
+
+	scopes := make([]*Scope, len(outputBlocks))
+	for n, k := range outputBlocks {
+		scope := &Scope{NumChannels: s.blocks[k].Block.Outputs()}
+		scopes[n] = scope
+		s.Add(scope)
+		dst := len(s.blocks) - 1
+		for o := 0; o < scope.NumChannels; o++ {
+			src, port := s.tap(k, o)
+			if n == 0 && o == 0 {
+				s.Add(&Stop{Time: stopTime})
+				stop := len(s.blocks) - 1
+				s.Connect(src, stop, port, 0)
+				src, port = stop, 0
+			}
+			s.Connect(src, dst, port, o)
+		}
+	}
+
+	if err := s.Start(context.Background()); err != nil {
+		return nil, nil, err
+	}
+
+	// The scopes may differ by a step, when the simulation ends.
+	t = scopes[0].Time
+	for _, scope := range scopes {
+		if len(scope.Time) < len(t) {
+			t = scope.Time
+		}
+	}
+	signals = make(map[int][][]float64)
+	for n, k := range outputBlocks {
+		data := make([][]float64, scopes[n].NumChannels)
+		for o := range data {
+			if o < len(scopes[n].Data) {
+				data[o] = scopes[n].Data[o][:len(t)]
+			}
+		}
+		signals[k] = data
+	}
+	return t, signals, nil
+}
+
+// tap returns a free output which carries the signal of output o of block k.
+// If the output is already connected, a Tee is inserted and
+// it's second output is returned.
+func (s *System) tap(k, o int) (int, int) {
+	if s.blocks[k].Out[o] == nil {
+		return k, o
+	}
+	s.Add(Tee{})
+	tee := len(s.blocks) - 1
+	for n, c := range s.connections {
+		if !c.vector && c.src == k && c.o == o {
+			c.src, c.o = tee, 0
+			s.connections[n] = c
+			s.connect(c)
+			break
+		}
+	}
+	s.Connect(k, tee, o, 0)
+	return tee, 1
+}
+
+// StepResponse applies a step of the given amplitude at t = 0 to the first
+// unconnected input of inputBlock and returns the output 0 of outputBlock
+// until duration, see Simulate. The system is not changed, the simulation
+// runs on a copy. StepMetrics computes the characteristic values.
+func (s *System) StepResponse(amplitude, duration float64, inputBlock, outputBlock int) (time, output []float64, err error) {
+	if inputBlock < 0 || inputBlock >= len(s.blocks) {
+		return nil, nil, fmt.Errorf("step response: block %d does not exist", inputBlock)
+	}
+	c, err := s.Clone()
+	if err != nil {
+		return nil, nil, fmt.Errorf("step response: %v", err)
+	}
+	in := -1
+	for i, ch := range c.blocks[inputBlock].In {
+		if ch == nil {
+			in = i
+			break
+		}
+	}
+	if in < 0 {
+		return nil, nil, fmt.Errorf("step response: block %d (%s) has no free input", inputBlock, typeName(s.blocks[inputBlock].Block))
+	}
+	c.Add(Source(amplitude))
+	c.Connect(len(c.blocks)-1, inputBlock, 0, in)
+	t, signals, err := c.simulate(duration, []int{outputBlock})
+	if err != nil {
+		return nil, nil, err
+	}
+	return t, signals[outputBlock][0], nil
+}
+
+// StepResponseMetrics are the characteristic values of a step response.
+// The FinalValue is the last sample, the other values refer to it.
+type StepResponseMetrics struct {
+	FinalValue   float64
+	RiseTime     float64 // from 10% to 90% of the final value
+	PeakTime     float64 // time of the maximum
+	Overshoot    float64 // of the maximum, relative to the final value
+	SettlingTime float64 // after which the output stays within 2% of the final value
+}
+
+// StepMetrics computes the metrics of the step response y at the times t,
+// e.g. returned by StepResponse. The response should have settled at the end.
+// Times between samples are interpolated linearly.
+func StepMetrics(t, y []float64) StepResponseMetrics {
+	var m StepResponseMetrics
+	n := len(y)
+	if n == 0 || len(t) < n {
+		return m
+	}
+	final := y[n-1]
+	m.FinalValue = final
+
+	// at returns the time, at which y first crosses level, or NaN.
+	at := func(level float64) float64 {
+		for k := 1; k < n; k++ {
+			if (y[k-1]-level)*(y[k]-level) <= 0 && y[k] != y[k-1] {
+				return t[k-1] + (level-y[k-1])*(t[k]-t[k-1])/(y[k]-y[k-1])
+			}
+		}
+		return math.NaN()
+	}
+	m.RiseTime = at(0.9*final) - at(0.1*final)
+
+	peak := 0
+	for k := range y {
+		if math.Abs(y[k]) > math.Abs(y[peak]) {
+			peak = k
+		}
+	}
+	m.PeakTime = t[peak]
+	if final != 0 {
+		m.Overshoot = max(0, (y[peak]-final)/final)
+	}
+
+	band := 0.02 * math.Abs(final)
+	for k := n - 1; k >= 0; k-- {
+		if math.Abs(y[k]-final) > band {
+			if k+1 < n {
+				m.SettlingTime = t[k+1]
+			}
+			break
+		}
+	}
+	return m
+}
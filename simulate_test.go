@@ -0,0 +1,117 @@
+package loops
+
+import (
+	"math"
+	"testing"
+)
+
+// TestSimulate solves the 1st order system x' = -x with Simulate.
+func TestSimulate(t *testing.T) {
+	var s System
+	s.Add(&Integrate{State: 1}) // 0
+	s.Add(Scale(-1))            // 1
+	s.Connect(0, 1, 0, 0)       // inte -> neg
+	s.Connect(1, 0, 0, 0)       // neg -> inte
+	s.AddIC(-1, 0, 0)
+
+	tm, signals, err := s.Simulate(1, []int{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, y := signals[0][0], signals[1][0]
+	if len(tm) < 90 || len(x) != len(tm) || len(y) != len(tm) {
+		t.Fatalf("got %d times, %d and %d samples", len(tm), len(x), len(y))
+	}
+	for k := range tm {
+		if want := math.Exp(-tm[k] - DefaultDT); math.Abs(x[k]-want) > 0.01 {
+			t.Fatalf("x(%v) = %v, want %v", tm[k], x[k], want)
+		}
+		if y[k] != -x[k] {
+			t.Fatalf("y(%v) = %v, want %v", tm[k], y[k], -x[k])
+		}
+	}
+
+	// The system is not changed and a second simulation gives the same result.
+	if n := s.NumBlocks(); n != 2 {
+		t.Fatalf("the system has %d blocks after Simulate", n)
+	}
+	if state := s.Block(0).(*Integrate).State; state != 1 {
+		t.Fatalf("the state of the integrator changed to %v", state)
+	}
+	_, again, err := s.Simulate(1, []int{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, b := truncate(t, x, again[0][0]); !equal(a, b) {
+		t.Fatal("the second simulation differs")
+	}
+
+	var r System
+	r.Add(&Recorder{NumChannels: 1})
+	if _, _, err := r.Simulate(1, []int{0}); err == nil {
+		t.Fatal("expected an error for a block without outputs")
+	}
+}
+
+// TestStepResponse checks the metrics of the step response of the 2nd order
+// system wn²/(s² + 2ζwn s + wn²) with the classical formulas.
+func TestStepResponse(t *testing.T) {
+	const wn, zeta = 4, 0.4
+	s := System{DT: 0.001}
+	s.Add(&TransferFunction{Num: []float64{2 * wn * wn}, Den: []float64{1, 2 * zeta * wn, wn * wn}})
+
+	tm, y, err := s.StepResponse(0.5, 6, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.NumBlocks() != 1 {
+		t.Fatalf("the system has %d blocks", s.NumBlocks())
+	}
+	if len(tm) < 5990 || len(y) != len(tm) {
+		t.Fatalf("got %d times and %d samples", len(tm), len(y))
+	}
+	m := StepMetrics(tm, y)
+	wd := wn * math.Sqrt(1-zeta*zeta)
+
+	// The rise and settling time are computed from the analytical response,
+	// as the formulas for them are approximations.
+	exact := func(t float64) float64 {
+		return 1 - math.Exp(-zeta*wn*t)*(math.Cos(wd*t)+zeta*wn/wd*math.Sin(wd*t))
+	}
+	first := func(level float64) float64 { // first crossing, before the peak
+		lo, hi := 0.0, math.Pi/wd
+		for i := 0; i < 60; i++ {
+			if mid := (lo + hi) / 2; exact(mid) < level {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		return lo
+	}
+	settling := 0.0
+	for t := 0.0; t < 6; t += 1e-5 {
+		if math.Abs(exact(t)-1) > 0.02 {
+			settling = t
+		}
+	}
+	for _, c := range []struct {
+		name      string
+		got, want float64
+		tol       float64
+	}{
+		{"final value", m.FinalValue, 1, 0.001},
+		{"overshoot", m.Overshoot, math.Exp(-zeta * math.Pi / math.Sqrt(1-zeta*zeta)), 0.005},
+		{"peak time", m.PeakTime, math.Pi / wd, 0.01},
+		{"rise time", m.RiseTime, first(0.9) - first(0.1), 0.005},
+		{"settling time", m.SettlingTime, settling, 0.01},
+	} {
+		if math.Abs(c.got-c.want) > c.tol {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	if _, _, err := s.StepResponse(1, 1, 1, 0); err == nil {
+		t.Error("expected an error for a missing block")
+	}
+}
@@ -0,0 +1,85 @@
+package loops
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// A ChannelSpy records all values which are sent over a connection.
+// It is created by System.SpyOn.
+type ChannelSpy struct {
+	history   []float64
+	mu        sync.Mutex
+	in, out   chan float64
+	src, port int // spied block output
+}
+
+// SpyOn inserts a ChannelSpy into the connection at output srcPort of block src.
+// The spy forwards every value to the original destination and keeps a copy.
+// The output must already be connected.
+func (s *System) SpyOn(src, srcPort int) (*ChannelSpy, error) {
+	if src < 0 || src >= len(s.blocks) {
+		return nil, fmt.Errorf("spy: block %d does not exist", src)
+	}
+	b := s.blocks[src]
+	if srcPort < 0 || srcPort >= len(b.Out) {
+		return nil, fmt.Errorf("spy: block %d has no output %d", src, srcPort)
+	}
+	if b.Out[srcPort] == nil {
+		return nil, fmt.Errorf("spy: block %d output %d is not connected", src, srcPort)
+	}
+	spy := &ChannelSpy{src: src, port: srcPort}
+	spy.insert(s)
+	s.spies = append(s.spies, spy)
+	return spy, nil
+}
+
+// insert places the spy between the block output and it's destination.
+func (c *ChannelSpy) insert(s *System) {
+	out := s.blocks[c.src].Out
+	c.in, c.out = make(chan float64), out[c.port]
+	out[c.port] = c.in
+}
+
+// reset clears the history and inserts the spy into the
+// reallocated channels of a system after a reset.
+func (c *ChannelSpy) reset(s *System) {
+	c.mu.Lock()
+	c.history = nil
+	c.mu.Unlock()
+	c.insert(s)
+}
+
+// Values returns a copy of all values recorded so far.
+// It may be called while the simulation is running.
+func (c *ChannelSpy) Values() []float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]float64(nil), c.history...)
+}
+
+// run forwards values from the source to the destination
+// until ctx is cancelled.
+func (c *ChannelSpy) run(ctx context.Context) {
+	for {
+		select {
+		case v, ok := <-c.in:
+			if !ok {
+				// The source has stopped.
+				close(c.out)
+				return
+			}
+			c.mu.Lock()
+			c.history = append(c.history, v)
+			c.mu.Unlock()
+			select {
+			case c.out <- v:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package loops
+
+import (
+	"context"
+	"testing"
+)
+
+// TestSpyOn observes the integrator output of the 1st order system
+// and compares it to the recorded output.
+func TestSpyOn(t *testing.T) {
+	var inte = Integrate{State: 1}
+	var rec = Recorder{NumChannels: 1}
+	var stop = Stop{Time: 0.5}
+
+	var s System
+	s.Add(&inte)          // 0
+	s.Add(Tee{})          // 1
+	s.Add(&rec)           // 2
+	s.Add(Scale(-1))      // 3
+	s.Add(&stop)          // 4
+	s.Connect(0, 1, 0, 0) // inte -> tee
+	s.Connect(1, 2, 0, 0) // tee -> rec
+	s.Connect(1, 3, 1, 0) // tee -> scale
+	s.Connect(3, 4, 0, 0) // scale -> stop
+	s.Connect(4, 0, 0, 0) // stop -> inte
+	s.AddIC(1, 3, 0)
+
+	spy, err := s.SpyOn(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SpyOn(2, 0); err == nil {
+		t.Fatal("expected an error for a block without outputs")
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	v := spy.Values()
+	if len(v) < len(rec.Data[0]) {
+		t.Fatalf("spy has %d values, recorder %d", len(v), len(rec.Data[0]))
+	}
+	for i, x := range rec.Data[0] {
+		if v[i] != x {
+			t.Fatalf("value %d: spy %v, recorder %v", i, v[i], x)
+		}
+	}
+}
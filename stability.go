@@ -0,0 +1,186 @@
+package loops
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// maxStiffness is the largest ratio of the eigenvalue magnitudes,
+// which ValidateStability accepts.
+const maxStiffness = 1e8
+
+// ValidateStability checks the numerical stability of the system
+// for the time step dt, or s.DT if dt is 0.
+//
+// The system is linearized with LinearizeAt at the states of it's Integrate
+// blocks and the values of it's Source blocks. It returns an error for the
+// first condition, which fails for an eigenvalue λ of A:
+//
+//   - Re λ < 0: the linearized system is asymptotically stable.
+//   - |Im λ| < π/dt: the oscillation is below the Nyquist frequency 1/(2dt).
+//   - |1 + λdt| < 1: the mode decays with the forward Euler method of Integrate.
+//
+// Blocks do not report their linearization separately,
+// so instead of a condition number for each block, it rejects a
+// stiffness ratio max|λ|/min|λ| above 1e8 of the whole system.
+// The system itself is not changed, see LinearizeAt for it's limitations.
+func (s *System) ValidateStability(dt float64) error {
+	if dt == 0 {
+		dt = timeStep(s.DT)
+	}
+	if !(dt > 0) || math.IsInf(dt, 1) {
+		return fmt.Errorf("stability: invalid time step %v", dt)
+	}
+	var x0, u0 []float64
+	for _, b := range s.blocks {
+		switch v := b.Block.(type) {
+		case *Integrate:
+			x0 = append(x0, v.State)
+		case Source:
+			u0 = append(u0, float64(v))
+		}
+	}
+	if len(x0) == 0 {
+		return nil
+	}
+	A, _, _, _, err := s.LinearizeAt(x0, u0)
+	if err != nil {
+		return fmt.Errorf("stability: %v", err)
+	}
+	ev, err := eigenvalues(A)
+	if err != nil {
+		return fmt.Errorf("stability: %v", err)
+	}
+	lo, hi := math.Inf(1), 0.0
+	for _, l := range ev {
+		if real(l) >= 0 {
+			return fmt.Errorf("stability: the system is not stable, it has the eigenvalue %.4g", l)
+		}
+		if f := math.Abs(imag(l)) / (2 * math.Pi); f >= 0.5/dt {
+			return fmt.Errorf("stability: the eigenvalue %.4g oscillates at %.4g Hz, above the Nyquist frequency %.4g Hz", l, f, 0.5/dt)
+		}
+		if r := cmplx.Abs(1 + l*complex(dt, 0)); r >= 1 {
+			return fmt.Errorf("stability: the eigenvalue %.4g is not stable for the time step %v, |1+λdt| is %.4g", l, dt, r)
+		}
+		lo, hi = math.Min(lo, cmplx.Abs(l)), math.Max(hi, cmplx.Abs(l))
+	}
+	if hi > maxStiffness*lo {
+		return fmt.Errorf("stability: the stiffness ratio %.4g of the eigenvalues exceeds %g", hi/lo, maxStiffness)
+	}
+	return nil
+}
+
+// eigenvalues returns the eigenvalues of the square matrix a.
+// It reduces a to Hessenberg form by elimination and iterates
+// with complex, shifted QR steps.
+func eigenvalues(a [][]float64) ([]complex128, error) {
+	n := len(a)
+	h := make([][]complex128, n)
+	for i := range h {
+		if len(a[i]) != n {
+			return nil, fmt.Errorf("eigenvalues: matrix is not square")
+		}
+		h[i] = make([]complex128, n)
+		for j, v := range a[i] {
+			h[i][j] = complex(v, 0)
+		}
+	}
+
+	// Hessenberg reduction by elimination with pivoting.
+	for m := 1; m < n-1; m++ {
+		p := m
+		for i := m + 1; i < n; i++ {
+			if cmplx.Abs(h[i][m-1]) > cmplx.Abs(h[p][m-1]) {
+				p = i
+			}
+		}
+		if p != m {
+			h[p], h[m] = h[m], h[p]
+			for i := range h {
+				h[i][p], h[i][m] = h[i][m], h[i][p]
+			}
+		}
+		if h[m][m-1] == 0 {
+			continue
+		}
+		for i := m + 1; i < n; i++ {
+			t := h[i][m-1] / h[m][m-1]
+			if t == 0 {
+				continue
+			}
+			for j := range h {
+				h[i][j] -= t * h[m][j]
+			}
+			for j := range h {
+				h[j][m] += t * h[j][i]
+			}
+		}
+	}
+
+	ev := make([]complex128, n)
+	iter := 0
+	for hi := n - 1; hi >= 0; {
+		// Find the start lo of the unreduced block, which ends at hi.
+		lo := hi
+		for lo > 0 {
+			if cmplx.Abs(h[lo][lo-1]) <= 1e-14*(cmplx.Abs(h[lo][lo])+cmplx.Abs(h[lo-1][lo-1])) {
+				h[lo][lo-1] = 0
+				break
+			}
+			lo--
+		}
+		if lo == hi {
+			ev[hi] = h[hi][hi]
+			hi, iter = hi-1, 0
+			continue
+		}
+		if iter++; iter > 100*n {
+			return nil, fmt.Errorf("eigenvalues: no convergence")
+		}
+
+		// The Wilkinson shift is the eigenvalue of the trailing 2x2 block,
+		// which is closer to the last diagonal element.
+		// Every 10 iterations, an exceptional shift breaks cycles.
+		p, q, r, t := h[hi-1][hi-1], h[hi-1][hi], h[hi][hi-1], h[hi][hi]
+		d := cmplx.Sqrt((p-t)*(p-t)/4 + q*r)
+		mu := (p+t)/2 + d
+		if cmplx.Abs(mu-t) > cmplx.Abs((p+t)/2-d-t) {
+			mu = (p+t)/2 - d
+		}
+		if iter%10 == 0 {
+			mu = t + complex(cmplx.Abs(h[hi][hi-1]), 0)
+		}
+
+		// QR step H - μI = QR, H = RQ + μI with Givens rotations.
+		for k := lo; k <= hi; k++ {
+			h[k][k] -= mu
+		}
+		c, s := make([]complex128, hi), make([]complex128, hi)
+		for k := lo; k < hi; k++ {
+			x, y := h[k][k], h[k+1][k]
+			nr := math.Hypot(cmplx.Abs(x), cmplx.Abs(y))
+			if nr == 0 {
+				c[k], s[k] = 1, 0
+				continue
+			}
+			c[k], s[k] = x/complex(nr, 0), y/complex(nr, 0)
+			for j := k; j <= hi; j++ {
+				a, b := h[k][j], h[k+1][j]
+				h[k][j] = cmplx.Conj(c[k])*a + cmplx.Conj(s[k])*b
+				h[k+1][j] = -s[k]*a + c[k]*b
+			}
+		}
+		for k := lo; k < hi; k++ {
+			for i := lo; i <= min(k+2, hi); i++ {
+				a, b := h[i][k], h[i][k+1]
+				h[i][k] = a*c[k] + b*s[k]
+				h[i][k+1] = -a*cmplx.Conj(s[k]) + b*cmplx.Conj(c[k])
+			}
+		}
+		for k := lo; k <= hi; k++ {
+			h[k][k] += mu
+		}
+	}
+	return ev, nil
+}
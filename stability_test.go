@@ -0,0 +1,117 @@
+package loops
+
+import (
+	"math"
+	"math/cmplx"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestEigenvalues compares with matrices of known eigenvalues.
+func TestEigenvalues(t *testing.T) {
+	w := math.Sqrt(30 - 0.25*0.25)
+	for _, c := range []struct {
+		a    [][]float64
+		want []complex128
+	}{
+		{[][]float64{{-2}}, []complex128{-2}},
+		{[][]float64{{0, 1}, {-30, -0.5}}, []complex128{complex(-0.25, -w), complex(-0.25, w)}},
+		{[][]float64{{0, 1}, {-1, 0}}, []complex128{-1i, 1i}},
+		// The companion matrix of (s+1)(s+2)(s+3) = s³ + 6s² + 11s + 6.
+		{[][]float64{{0, 1, 0}, {0, 0, 1}, {-6, -11, -6}}, []complex128{-3, -2, -1}},
+		{[][]float64{{4, 1, 2, 0}, {0, 3, 1, 1}, {0, 0, 2, 5}, {0, 0, 0, 1}}, []complex128{1, 2, 3, 4}},
+	} {
+		ev, err := eigenvalues(c.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Slice(ev, func(i, j int) bool {
+			if math.Abs(real(ev[i])-real(ev[j])) > 1e-9 {
+				return real(ev[i]) < real(ev[j])
+			}
+			return imag(ev[i]) < imag(ev[j])
+		})
+		if len(ev) != len(c.want) {
+			t.Fatalf("%v: got %v, want %v", c.a, ev, c.want)
+		}
+		for i := range ev {
+			if cmplx.Abs(ev[i]-c.want[i]) > 1e-9 {
+				t.Fatalf("%v: got %v, want %v", c.a, ev, c.want)
+			}
+		}
+	}
+}
+
+// TestEigenvaluesTrace checks that the eigenvalues of a full matrix
+// sum up to it's trace and are real or complex conjugate pairs.
+func TestEigenvaluesTrace(t *testing.T) {
+	a := zeroMatrix(7, 7)
+	trace := 0.0
+	for i := range a {
+		for j := range a {
+			a[i][j] = math.Sin(float64(3*i+j*j+1)) * float64(i-2*j+3)
+		}
+		trace += a[i][i]
+	}
+	ev, err := eigenvalues(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum complex128
+	for _, l := range ev {
+		sum += l
+	}
+	if cmplx.Abs(sum-complex(trace, 0)) > 1e-9 {
+		t.Fatalf("sum of %v is %v, trace %v", ev, sum, trace)
+	}
+	for _, l := range ev {
+		found := false
+		for _, m := range ev {
+			found = found || cmplx.Abs(m-cmplx.Conj(l)) < 1e-9
+		}
+		if !found {
+			t.Fatalf("%v has no conjugate in %v", l, ev)
+		}
+	}
+}
+
+// TestValidateStability checks the 1st and 2nd order systems
+// and an unstable one for different time steps.
+func TestValidateStability(t *testing.T) {
+	stop := func() *Stop { return &Stop{Time: 1} }
+	if err := ode1System(discard{}, stop()).ValidateStability(0.01); err != nil {
+		t.Fatal(err)
+	}
+	// The eigenvalues of the oscillator are -0.25±5.47i.
+	if err := ode2System(discard{}, stop()).ValidateStability(0.01); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		s    *System
+		dt   float64
+		want string
+	}{
+		{ode1System(discard{}, stop()), 2.5, "not stable for the time step"},
+		{ode2System(discard{}, stop()), 0.1, "not stable for the time step"},
+		{ode2System(discard{}, stop()), 0.7, "Nyquist"},
+		{ode2System(discard{}, stop()), -1, "invalid time step"},
+	} {
+		if err := c.s.ValidateStability(c.dt); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("dt %v: got %v, want %q", c.dt, err, c.want)
+		}
+	}
+
+	// x' = x grows.
+	var s System
+	s.Add(&Integrate{State: 1}) // 0
+	s.Add(Tee{})                // 1
+	s.Add(discard{})            // 2
+	s.Connect(0, 1, 0, 0)       // inte -> tee
+	s.Connect(1, 0, 0, 0)       // tee -> inte
+	s.Connect(1, 2, 1, 0)       // tee -> discard
+	s.AddIC(1, 0, 0)
+	if err := s.ValidateStability(0.01); err == nil || !strings.Contains(err.Error(), "not stable, it has the eigenvalue") {
+		t.Errorf("got %v for an unstable system", err)
+	}
+}
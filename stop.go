@@ -0,0 +1,51 @@
+package loops
+
+import "sync"
+
+// Forced stop
+//
+// A Stop block ends the simulation at it's stop time. To end it earlier,
+// e.g. from a signal handler, the Stop blocks are registered with the system
+// and ForceStop stops all of them at once.
+
+// RegisterStop registers a Stop block of the system for ForceStop.
+// It must be called before the simulation is started.
+func (s *System) RegisterStop(stop *Stop) {
+	if s.force == nil {
+		s.force = make(chan struct{})
+	}
+	stop.force = s.force
+	s.stops = append(s.stops, stop)
+}
+
+// ForceStop ends the simulation at all registered Stop blocks
+// and calls their callbacks. It may be called from any goroutine.
+// The callbacks of each Stop block are called only once, even if it
+// reaches it's stop time at the same time.
+// Start may return before ForceStop, the system must not be reset
+// before ForceStop has returned.
+func (s *System) ForceStop() {
+	if s.force == nil {
+		return
+	}
+	s.forceOnce.Do(func() { close(s.force) })
+	for _, stop := range s.stops {
+		stop.callback()
+	}
+}
+
+// resetStop renews the channel of ForceStop, after it has been closed.
+func (s *System) resetStop() {
+	if s.force == nil {
+		return
+	}
+	select {
+	case <-s.force:
+	default:
+		return
+	}
+	s.force, s.forceOnce = make(chan struct{}), sync.Once{}
+	for _, stop := range s.stops {
+		stop.force = s.force
+	}
+}
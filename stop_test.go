@@ -0,0 +1,59 @@
+package loops
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestForceStop stops a long simulation from another goroutine.
+func TestForceStop(t *testing.T) {
+	var called atomic.Int32
+	stop := &Stop{Time: 1e6, Callbacks: []func(){func() { called.Add(1) }}}
+	s := ode1System(&Recorder{NumChannels: 1}, stop)
+	s.RegisterStop(stop)
+
+	forced := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.ForceStop()
+		close(forced)
+	}()
+	done := make(chan error)
+	go func() { done <- s.Start(context.Background()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("simulation did not stop")
+	}
+	<-forced
+	if n := called.Load(); n != 1 {
+		t.Fatalf("callbacks called %d times", n)
+	}
+	s.ForceStop()
+	if n := called.Load(); n != 1 {
+		t.Fatalf("callbacks called %d times after a second ForceStop", n)
+	}
+
+	// After a reset, the system runs to the stop time again.
+	stop.Time = 0.1
+	s.Reset()
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := called.Load(); n != 2 {
+		t.Fatalf("callbacks called %d times after reset", n)
+	}
+
+	c, err := s.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.stops) != 1 || c.stops[0] == stop || c.stops[0] != c.Block(6) {
+		t.Fatalf("clone has the registered stops %v", c.stops)
+	}
+}
@@ -0,0 +1,156 @@
+package loops
+
+import (
+	"context"
+	"fmt"
+)
+
+// Sub-system ports
+//
+// A system which is used as a block of another system receives it's
+// inputs at InputPort blocks and sends it's outputs from OutputPort blocks.
+// The ports are connected to the system's In and Out channels, which are
+// served by System.Step.
+
+// InputPort is a source within a sub-system, which outputs the
+// values of the parent system's input PortIndex.
+type InputPort struct {
+	PortIndex int
+	c         chan float64
+	done      <-chan struct{}
+}
+
+func (b *InputPort) Validate() error {
+	if b.PortIndex < 0 {
+		return fmt.Errorf("input port: negative index %d", b.PortIndex)
+	}
+	return nil
+}
+func (b *InputPort) InputNames() []string  { return nil }
+func (b *InputPort) OutputNames() []string { return []string{"out"} }
+func (b *InputPort) Inputs() int           { return 0 }
+func (b *InputPort) Outputs() int          { return 1 }
+func (b *InputPort) Step(in, out []float64) bool {
+	select {
+	case v, ok := <-b.c:
+		if !ok {
+			return false
+		}
+		out[0] = v
+		return true
+	case <-b.done:
+		return false
+	}
+}
+
+// OutputPort is a sink within a sub-system, which sends it's input
+// to the parent system's output PortIndex.
+type OutputPort struct {
+	PortIndex int
+	c         chan float64
+	done      <-chan struct{}
+}
+
+func (b *OutputPort) Validate() error {
+	if b.PortIndex < 0 {
+		return fmt.Errorf("output port: negative index %d", b.PortIndex)
+	}
+	return nil
+}
+func (b *OutputPort) InputNames() []string  { return []string{"in"} }
+func (b *OutputPort) OutputNames() []string { return nil }
+func (b *OutputPort) Inputs() int           { return 1 }
+func (b *OutputPort) Outputs() int          { return 0 }
+func (b *OutputPort) Step(in, out []float64) bool {
+	select {
+	case b.c <- in[0]:
+		return true
+	case <-b.done:
+		return false
+	}
+}
+
+// AddInputPort adds an InputPort for the system's input i
+// and returns it's block index.
+func (s *System) AddInputPort(i int) int {
+	s.Add(&InputPort{PortIndex: i})
+	return len(s.blocks) - 1
+}
+
+// AddOutputPort adds an OutputPort for the system's output i
+// and returns it's block index.
+func (s *System) AddOutputPort(i int) int {
+	s.Add(&OutputPort{PortIndex: i})
+	return len(s.blocks) - 1
+}
+
+// addPort connects a port block to the system's channel,
+// which is allocated if needed.
+func (s *System) addPort(b Block) {
+	switch p := b.(type) {
+	case *InputPort:
+		p.c = portChannel(&s.In, p.PortIndex)
+	case *OutputPort:
+		p.c = portChannel(&s.Out, p.PortIndex)
+	}
+}
+
+// portChannel returns the channel (*c)[i], extending *c as needed.
+func portChannel(c *[]chan float64, i int) chan float64 {
+	if i < 0 {
+		return nil
+	}
+	for len(*c) <= i {
+		*c = append(*c, nil)
+	}
+	if (*c)[i] == nil {
+		(*c)[i] = make(chan float64)
+	}
+	return (*c)[i]
+}
+
+// startPorts lets the port blocks stop, when ctx is done.
+func (s *System) startPorts(ctx context.Context) {
+	for _, b := range s.blocks {
+		switch p := b.Block.(type) {
+		case *InputPort:
+			p.done = ctx.Done()
+		case *OutputPort:
+			p.done = ctx.Done()
+		}
+	}
+}
+
+// checkPorts reports system ports which are not connected
+// or used by more than one port block.
+func (s *System) checkPorts() []ValidationError {
+	var errs []ValidationError
+	for i, c := range s.In {
+		if c == nil {
+			errs = append(errs, ValidationError{-1, "input", i, "system input is not connected"})
+		}
+	}
+	for i, c := range s.Out {
+		if c == nil {
+			errs = append(errs, ValidationError{-1, "output", i, "system output is not connected"})
+		}
+	}
+	in, out := make(map[int]int), make(map[int]int)
+	for k, b := range s.blocks {
+		var m map[int]int
+		var i int
+		switch p := b.Block.(type) {
+		case *InputPort:
+			m, i = in, p.PortIndex
+		case *OutputPort:
+			m, i = out, p.PortIndex
+		default:
+			continue
+		}
+		if j, ok := m[i]; ok {
+			errs = append(errs, ValidationError{k, "", -1, fmt.Sprintf("system port %d is also used by block %d", i, j)})
+		}
+		m[i] = k
+	}
+	return errs
+}
@@ -0,0 +1,54 @@
+package loops
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// TestSubSystem runs a sub-system, which integrates 2 times it's input.
+func TestSubSystem(t *testing.T) {
+	var sub System
+	sub.AddInputPort(0)     // 0
+	sub.Add(Scale(2))       // 1
+	sub.Add(&Integrate{})   // 2
+	sub.AddOutputPort(0)    // 3
+	sub.Connect(0, 1, 0, 0) // in -> scale
+	sub.Connect(1, 2, 0, 0) // scale -> inte
+	sub.Connect(2, 3, 0, 0) // inte -> out
+	rec := Recorder{NumChannels: 1}
+	var s System
+	s.Add(Source(1))        // 0
+	s.Add(&Stop{Time: 0.1}) // 1
+	s.Add(&sub)             // 2
+	s.Add(&rec)             // 3
+	s.Connect(0, 1, 0, 0)   // ones -> stop
+	s.Connect(1, 2, 0, 0)   // stop -> sub
+	s.Connect(2, 3, 0, 0)   // sub -> rec
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	x := rec.Data[0]
+	if len(x) < 8 {
+		t.Fatalf("recorded %v", x)
+	}
+	for k, v := range x {
+		if want := 2 * float64(k+1) * DefaultDT; math.Abs(v-want) > 1e-12 {
+			t.Fatalf("x[%d] = %v, want %v", k, v, want)
+		}
+	}
+	if sub.done != nil {
+		t.Fatal("sub-system is still running")
+	}
+
+	var dup System
+	dup.AddInputPort(0)
+	dup.AddInputPort(0)
+	dup.Add(discard{})
+	dup.Add(discard{})
+	dup.Connect(0, 2, 0, 0)
+	dup.Connect(1, 3, 0, 0)
+	if err := dup.Start(context.Background()); err == nil {
+		t.Fatal("expected an error for a duplicate port")
+	}
+}
@@ -0,0 +1,297 @@
+package loops
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// StartSync runs the simulation like Start, but calls the Step functions
+// of all blocks sequentially in a single goroutine.
+// This avoids the channel overhead, which dominates for small systems.
+//
+// The blocks are stepped in the order of their dependencies, which is
+// determined by a topological sort. Connections to inputs with an initial
+// condition are not dependencies: they deliver the value of the previous
+// step, just like a channel which has been filled with the initial condition.
+// Every feedback loop must therefore contain an initial condition.
+//
+// The simulation ends when a block's Step function returns false.
+// Blocks which come later in the order are not stepped again.
+// Sub-system ports are not supported.
+func (s *System) StartSync() error {
+	r, err := s.newSyncRunner()
+	if err != nil {
+		return err
+	}
+	s.setDT()
+	s.startProfile()
+	defer s.closeBlocks()
+
+	_, tEnd := s.clockBlock()
+	interval := s.ProgressInterval
+	if interval == 0 {
+		interval = time.Second
+	}
+	last := time.Now()
+	if s.ProgressFunc != nil {
+		defer func() { s.ProgressFunc(s.clock.T(), tEnd) }()
+	}
+	for r.step(s) {
+		if s.ProgressFunc != nil && time.Since(last) >= interval {
+			last = time.Now()
+			s.ProgressFunc(s.clock.T(), tEnd)
+		}
+	}
+	return nil
+}
+
+// ErrEnded is returned by StepOnce, when a block's Step function
+// has returned false.
+var ErrEnded = errors.New("the simulation has ended")
+
+// StepOnce advances the simulation by one step, in the same way as StartSync.
+// It is meant for debuggers and interactive use: blocks may be inspected
+// between the steps and StepOutputs returns the values they have sent.
+// Calling StepOnce n times gives the same result as the first n steps of StartSync.
+//
+// The first call checks the system and sends the initial conditions.
+// When a block's Step function returns false, the remaining blocks of
+// the step are skipped, all blocks are closed and ErrEnded is returned,
+// also for every later call. Reset starts again from the initial conditions.
+func (s *System) StepOnce() error {
+	if s.stepper == nil {
+		r, err := s.newSyncRunner()
+		if err != nil {
+			return err
+		}
+		s.stepper = r
+		s.setDT()
+		s.startProfile()
+	}
+	if s.stepper.ended {
+		return ErrEnded
+	}
+	if !s.stepper.step(s) {
+		s.closeBlocks()
+		return ErrEnded
+	}
+	return nil
+}
+
+// StepOutputs returns the outputs of block k of the last call to StepOnce.
+// They are zero before the first step and for blocks which have been skipped.
+func (s *System) StepOutputs(k int) []float64 {
+	if s.stepper == nil {
+		return make([]float64, len(s.blocks[k].Out))
+	}
+	return s.stepper.y[k]
+}
+
+// RunN advances the simulation by n steps with StepOnce.
+// It returns ErrEnded, if a block's Step function has returned false before.
+// The blocks are not closed after n steps, the simulation can be continued
+// with StepOnce or RunN, or ended with Reset.
+func (s *System) RunN(n int) error {
+	for k := 0; k < n; k++ {
+		if err := s.StepOnce(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EstimateRuntime predicts the wall-clock time for numSteps steps.
+// It runs a copy of the system for 100 steps with RunN and extrapolates
+// linearly. The copy is reset afterwards, which closes it's blocks,
+// and the system itself is not changed. See Clone for the blocks
+// which are copied. The steps are measured with the sequential runner
+// of StartSync, Start has an additional channel overhead.
+// An estimate above a minute is logged as a warning.
+func (s *System) EstimateRuntime(numSteps int) (time.Duration, error) {
+	const n = 100
+	c, err := s.Clone()
+	if err != nil {
+		return 0, fmt.Errorf("estimate runtime: %v", err)
+	}
+	start := time.Now()
+	err = c.RunN(n)
+	elapsed := time.Since(start)
+	c.Reset()
+	if err == ErrEnded {
+		return 0, fmt.Errorf("estimate runtime: the simulation ends within %d steps", n)
+	} else if err != nil {
+		return 0, fmt.Errorf("estimate runtime: %v", err)
+	}
+	d := time.Duration(float64(elapsed) * float64(numSteps) / n)
+	if d > time.Minute {
+		log.Printf("estimate runtime: %d steps take about %v", numSteps, d.Round(time.Second))
+	}
+	return d, nil
+}
+
+// syncRunner holds the state of a sequential simulation.
+//
+// Every connection is a queue of values, which have been sent
+// but not received yet. Queues are short, they hold at most the
+// initial conditions. The queue indexes of block k are in[k], out[k]
+// for scalar and vin[k], vout[k] for vector ports.
+type syncRunner struct {
+	order     []int
+	in, out   [][]int
+	vin, vout [][]int
+	queues    [][]float64
+	vqueues   [][][]float64
+	spies     [][]*ChannelSpy // spies on the outputs of each block
+	x, y      [][]float64
+	vx, vy    [][][]float64
+	ended     bool
+}
+
+// newSyncRunner checks the system, sorts the blocks and
+// fills the queues with the initial conditions.
+func (s *System) newSyncRunner() (*syncRunner, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	if len(s.In) > 0 || len(s.Out) > 0 {
+		return nil, fmt.Errorf("sync: sub-system ports are not supported")
+	}
+
+	n := len(s.blocks)
+	r := &syncRunner{
+		in: make([][]int, n), out: make([][]int, n),
+		vin: make([][]int, n), vout: make([][]int, n),
+	}
+	in, out, vin, vout := r.in, r.out, r.vin, r.vout
+	for k, b := range s.blocks {
+		in[k], out[k] = make([]int, len(b.In)), make([]int, len(b.Out))
+		vin[k], vout[k] = make([]int, len(b.VIn)), make([]int, len(b.VOut))
+	}
+	var queues [][]float64
+	var vqueues [][][]float64
+	var src, vsrc []int // source block of each queue
+	for _, c := range s.connections {
+		if c.o < 0 || c.i < 0 {
+			return nil, fmt.Errorf("sync: sub-system ports are not supported")
+		}
+		if c.vector {
+			vin[c.dst][c.i], vout[c.src][c.o] = len(vqueues), len(vqueues)
+			vqueues, vsrc = append(vqueues, nil), append(vsrc, c.src)
+			continue
+		}
+		in[c.dst][c.i], out[c.src][c.o] = len(queues), len(queues)
+		queues, src = append(queues, nil), append(src, c.src)
+	}
+	for _, ic := range s.initials {
+		q := in[ic.block][ic.input]
+		queues[q] = append(queues[q], ic.value)
+	}
+	r.queues, r.vqueues = queues, vqueues
+
+	// Sort the blocks with Kahn's algorithm.
+	next := make([][]int, n)
+	degree := make([]int, n)
+	edge := func(src, dst int) {
+		next[src] = append(next[src], dst)
+		degree[dst]++
+	}
+	for k := range s.blocks {
+		for _, q := range in[k] {
+			if len(queues[q]) == 0 {
+				edge(src[q], k)
+			}
+		}
+		for _, q := range vin[k] {
+			edge(vsrc[q], k)
+		}
+	}
+	order := make([]int, 0, n)
+	for k := range s.blocks {
+		if degree[k] == 0 {
+			order = append(order, k)
+		}
+	}
+	for i := 0; i < len(order); i++ {
+		for _, d := range next[order[i]] {
+			if degree[d]--; degree[d] == 0 {
+				order = append(order, d)
+			}
+		}
+	}
+	if len(order) < n {
+		var loop []int
+		for k := range s.blocks {
+			if degree[k] > 0 {
+				loop = append(loop, k)
+			}
+		}
+		return nil, fmt.Errorf("sync: feedback loop without initial condition through blocks %v", loop)
+	}
+	r.order = order
+
+	// Spies record the outputs of their block.
+	r.spies = make([][]*ChannelSpy, n)
+	for _, spy := range s.spies {
+		r.spies[spy.src] = append(r.spies[spy.src], spy)
+	}
+
+	r.x, r.y = make([][]float64, n), make([][]float64, n)
+	r.vx, r.vy = make([][][]float64, n), make([][][]float64, n)
+	for k, b := range s.blocks {
+		r.x[k], r.y[k] = make([]float64, len(b.In)), make([]float64, len(b.Out))
+		r.vx[k], r.vy[k] = make([][]float64, len(b.VIn)), make([][]float64, len(b.VOut))
+	}
+	return r, nil
+}
+
+// step steps all blocks once in order and advances the clock.
+// It returns false, if a block's Step function returns false.
+func (r *syncRunner) step(s *System) bool {
+	queues, vqueues := r.queues, r.vqueues
+	x, y, vx, vy := r.x, r.y, r.vx, r.vy
+	for _, k := range r.order {
+		b := s.blocks[k]
+		for i, q := range r.in[k] {
+			x[k][i] = queues[q][0]
+			queues[q] = queues[q][:copy(queues[q], queues[q][1:])]
+		}
+		for i, q := range r.vin[k] {
+			vx[k][i] = vqueues[q][0]
+			vqueues[q] = vqueues[q][:copy(vqueues[q], vqueues[q][1:])]
+		}
+		var ok bool
+		var t0 time.Time
+		if s.profile != nil {
+			t0 = time.Now()
+		}
+		if vb, isVector := b.Block.(VectorBlock); isVector {
+			ok = vb.StepVector(x[k], y[k], vx[k], vy[k])
+		} else {
+			ok = b.Step(x[k], y[k])
+		}
+		if s.profile != nil {
+			s.profile[k].add(time.Since(t0))
+		}
+		if !ok {
+			// The last step counts, even if not all blocks are stepped.
+			s.clock.tick()
+			r.ended = true
+			return false
+		}
+		for o, q := range r.out[k] {
+			queues[q] = append(queues[q], y[k][o])
+		}
+		for o, q := range r.vout[k] {
+			vqueues[q] = append(vqueues[q], vy[k][o])
+		}
+		for _, spy := range r.spies[k] {
+			spy.mu.Lock()
+			spy.history = append(spy.history, y[k][spy.port])
+			spy.mu.Unlock()
+		}
+	}
+	s.clock.tick()
+	return true
+}
@@ -0,0 +1,163 @@
+package loops
+
+import (
+	"context"
+	"testing"
+)
+
+// TestStartSync runs the 1st order system with both Start and StartSync,
+// which must give the same result.
+func TestStartSync(t *testing.T) {
+	var rec, syncRec Recorder
+	rec.NumChannels, syncRec.NumChannels = 1, 1
+	if err := ode1System(&rec, &Stop{Time: 1}).Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	s := ode1System(&syncRec, &Stop{Time: 1})
+	spy, err := s.SpyOn(2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StartSync(); err != nil {
+		t.Fatal(err)
+	}
+	x, y := rec.Data[0], syncRec.Data[0]
+	n := min(len(x), len(y))
+	if len(y) < 99 || !equal(x[:n], y[:n]) {
+		t.Fatalf("got %v, want %v", y, x)
+	}
+	if v := spy.Values(); len(v) < len(y) || v[0] != -y[0] {
+		t.Fatalf("spy recorded %v", v)
+	}
+
+	// A loop through a delay block needs an initial condition.
+	var loop System
+	loop.Add(&Integrate{})   // 0
+	loop.Add(Tee{})          // 1
+	loop.Add(discard{})      // 2
+	loop.Connect(0, 1, 0, 0) // inte -> tee
+	loop.Connect(1, 0, 0, 0) // tee -> inte
+	loop.Connect(1, 2, 1, 0) // tee -> discard
+	if err := loop.StartSync(); err == nil {
+		t.Fatal("expected an error for a loop without initial condition")
+	}
+}
+
+// BenchmarkOde1 runs the 1st order system for 100 steps
+// with Start and StartSync.
+func BenchmarkOde1(b *testing.B) {
+	b.Run("Start", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := ode1System(discard{}, &Stop{Time: 1}).Start(context.Background()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("StartSync", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := ode1System(discard{}, &Stop{Time: 1}).StartSync(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// TestStepOnce advances the 1st order system by 100 single steps
+// and compares it with Simulate.
+func TestStepOnce(t *testing.T) {
+	system := func() *System {
+		var s System
+		s.Add(&Integrate{State: 1}) // 0
+		s.Add(Scale(-1))            // 1
+		s.Connect(0, 1, 0, 0)       // inte -> neg
+		s.Connect(1, 0, 0, 0)       // neg -> inte
+		s.AddIC(-1, 0, 0)
+		return &s
+	}
+	_, signals, err := system().Simulate(1.5, []int{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := signals[0][0]
+	if len(want) < 100 {
+		t.Fatalf("simulate returned %d samples", len(want))
+	}
+
+	s := system()
+	if got := s.StepOutputs(0); len(got) != 1 || got[0] != 0 {
+		t.Fatalf("outputs before the first step: %v", got)
+	}
+	for k := 0; k < 100; k++ {
+		if err := s.StepOnce(); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.StepOutputs(0)[0]; got != want[k] {
+			t.Fatalf("step %d: got %v, want %v", k, got, want[k])
+		}
+		if got := s.Block(0).(*Integrate).State; got != want[k] {
+			t.Fatalf("step %d: state %v, want %v", k, got, want[k])
+		}
+	}
+	if n := s.Clock().Steps(); n != 100 {
+		t.Fatalf("clock counted %d steps", n)
+	}
+
+	// A Stop block ends the simulation after the same steps as StartSync.
+	var rec, syncRec Recorder
+	rec.NumChannels, syncRec.NumChannels = 1, 1
+	if err := ode1System(&syncRec, &Stop{Time: 0.5}).StartSync(); err != nil {
+		t.Fatal(err)
+	}
+	s = ode1System(&rec, &Stop{Time: 0.5})
+	for round := 0; round < 2; round++ {
+		rec.Data = nil
+		for n := 0; n < 1000; n++ {
+			if err := s.StepOnce(); err == ErrEnded {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !equal(rec.Data[0], syncRec.Data[0]) {
+			t.Fatalf("round %d: got %v, want %v", round, rec.Data, syncRec.Data)
+		}
+		if err := s.StepOnce(); err != ErrEnded {
+			t.Fatalf("after the end: %v", err)
+		}
+		s.Reset()
+		s.Block(0).(*Integrate).State = 1
+	}
+}
+
+// TestRunN runs the 1st order system in two parts of 50 steps
+// and estimates the runtime of a copy.
+func TestRunN(t *testing.T) {
+	s := ode1System(discard{}, &Stop{Time: 2})
+	for _, n := range []int64{50, 100} {
+		if err := s.RunN(50); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Clock().Steps(); got != n {
+			t.Fatalf("clock counted %d steps, want %d", got, n)
+		}
+	}
+	if err := s.RunN(1000); err != ErrEnded {
+		t.Fatalf("expected ErrEnded, got %v", err)
+	}
+	s.Reset()
+
+	s = ode1System(discard{}, &Stop{Time: 2})
+	d, err := s.EstimateRuntime(1e6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d <= 0 {
+		t.Fatalf("estimate %v", d)
+	}
+	if state := s.Block(0).(*Integrate).State; state != 1 || s.Clock().Steps() != 0 {
+		t.Fatalf("the system has been changed: state %v, %d steps", state, s.Clock().Steps())
+	}
+	if _, err := ode1System(discard{}, &Stop{Time: 0.5}).EstimateRuntime(1e6); err == nil {
+		t.Fatal("expected an error for a simulation, which ends within 100 steps")
+	}
+}
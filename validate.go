@@ -0,0 +1,126 @@
+package loops
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// A ValidationError is a problem of a system, which is found by Validate.
+type ValidationError struct {
+	BlockIndex int    // -1 for the ports of the system itself.
+	PortKind   string // "input", "output", "vector input", "vector output" or empty.
+	PortIndex  int    // -1, if the problem is not related to a single port.
+	Message    string
+}
+
+func (e ValidationError) Error() string {
+	var port string
+	if e.PortKind != "" {
+		port = " " + e.PortKind
+	}
+	if e.PortIndex >= 0 {
+		port += " " + strconv.Itoa(e.PortIndex)
+	}
+	if e.BlockIndex < 0 {
+		return "system" + port + ": " + e.Message
+	}
+	return "block " + strconv.Itoa(e.BlockIndex) + port + ": " + e.Message
+}
+
+// Validate returns all problems of the system at once.
+// Besides the errors which prevent the simulation from starting,
+// such as unconnected ports, invalid block parameters and algebraic loops,
+// it reports blocks without a path to a sink, isolated sources
+// and blocks whose port names do not match their number of ports.
+// An empty result means that the system is ready to start.
+func (s *System) Validate() []ValidationError {
+	errs := s.validate()
+	return append(errs, s.lint()...)
+}
+
+// validate returns the errors which prevent the system from starting.
+func (s *System) validate() []ValidationError {
+	var errs []ValidationError
+	unconnected := func(k int, kind string, n int, connected func(int) bool) {
+		for i := 0; i < n; i++ {
+			if !connected(i) {
+				errs = append(errs, ValidationError{k, kind, i, "not connected"})
+			}
+		}
+	}
+	for k, b := range s.blocks {
+		if v, ok := b.Block.(Validator); ok {
+			if err := v.Validate(); err != nil {
+				errs = append(errs, ValidationError{k, "", -1, err.Error()})
+			}
+		}
+		unconnected(k, "input", len(b.In), func(i int) bool { return b.In[i] != nil })
+		unconnected(k, "output", len(b.Out), func(i int) bool { return b.Out[i] != nil })
+		unconnected(k, "vector input", len(b.VIn), func(i int) bool { return b.VIn[i] != nil })
+		unconnected(k, "vector output", len(b.VOut), func(i int) bool { return b.VOut[i] != nil })
+	}
+	errs = append(errs, s.checkPorts()...)
+	if loop := s.algebraicLoop(); loop != nil {
+		errs = append(errs, ValidationError{loop[0], "", -1, fmt.Sprintf("algebraic loop through blocks %v", loop)})
+	}
+	return errs
+}
+
+// lint returns problems which do not prevent the simulation,
+// but are probably mistakes.
+func (s *System) lint() []ValidationError {
+	var errs []ValidationError
+
+	// Connections in reverse direction, and sinks.
+	prev := make([][]int, len(s.blocks))
+	sink := make([]bool, len(s.blocks))
+	hasOutput := make([]bool, len(s.blocks))
+	for _, c := range s.connections {
+		if c.o < 0 {
+			continue // from the system input
+		}
+		hasOutput[c.src] = true
+		if c.i < 0 {
+			sink[c.src] = true // to the system output
+			continue
+		}
+		prev[c.dst] = append(prev[c.dst], c.src)
+	}
+	var todo []int
+	for k, b := range s.blocks {
+		if b.Outputs() == 0 && len(b.VOut) == 0 {
+			sink[k] = true
+		}
+		if sink[k] {
+			todo = append(todo, k)
+		}
+	}
+	for len(todo) > 0 {
+		k := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		for _, p := range prev[k] {
+			if !sink[p] {
+				sink[p] = true
+				todo = append(todo, p)
+			}
+		}
+	}
+
+	for k, b := range s.blocks {
+		source := b.Inputs() == 0 && len(b.VIn) == 0
+		if source && !hasOutput[k] {
+			errs = append(errs, ValidationError{k, "", -1, "isolated source"})
+		} else if !sink[k] {
+			errs = append(errs, ValidationError{k, "", -1, "no path to a sink"})
+		}
+		if n, ok := b.Block.(NamedBlock); ok {
+			if in := n.InputNames(); len(in) != b.Inputs() {
+				errs = append(errs, ValidationError{k, "input", -1, fmt.Sprintf("%d input names for %d inputs", len(in), b.Inputs())})
+			}
+			if out := n.OutputNames(); len(out) != b.Outputs() {
+				errs = append(errs, ValidationError{k, "output", -1, fmt.Sprintf("%d output names for %d outputs", len(out), b.Outputs())})
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,53 @@
+package loops
+
+import (
+	"strings"
+	"testing"
+)
+
+// misnamed has more input names than inputs.
+type misnamed struct{ discard }
+
+func (b misnamed) InputNames() []string  { return []string{"a", "b"} }
+func (b misnamed) OutputNames() []string { return nil }
+
+// TestValidate reports all problems of a broken system at once.
+func TestValidate(t *testing.T) {
+	var s System
+	s.Add(Source(1))                  // 0
+	s.Add(Saturation{Min: 1, Max: 0}) // 1
+	s.Add(misnamed{})                 // 2
+	s.Add(Source(2))                  // 3
+	s.Add(Tee{})                      // 4
+	s.Add(Scale(1))                   // 5
+	s.Connect(1, 2, 0, 0)             // sat -> misnamed
+	s.Connect(3, 4, 0, 0)             // source -> tee
+	s.Connect(4, 5, 0, 0)             // tee -> scale
+
+	var got []string
+	for _, e := range s.Validate() {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		"block 0 output 0: not connected",
+		"block 1: saturation: min 1 must be smaller than max 0",
+		"block 1 input 0: not connected",
+		"block 4 output 1: not connected",
+		"block 5 output 0: not connected",
+		"block 0: isolated source",
+		"block 2 input: 2 input names for 1 inputs",
+		"block 3: no path to a sink",
+		"block 4: no path to a sink",
+		"block 5: no path to a sink",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if err := s.check(); err == nil || err.Error() != want[0] {
+		t.Fatalf("check returns %v", err)
+	}
+
+	if errs := ode1System(discard{}, &Stop{Time: 1}).Validate(); len(errs) != 0 {
+		t.Fatalf("ode1 system: %v", errs)
+	}
+}
@@ -0,0 +1,105 @@
+package loops
+
+import (
+	"fmt"
+	"log"
+)
+
+// Vector signals
+//
+// Signals are scalars, which are sent over a chan float64.
+// Vector signals bundle several values into a []float64,
+// which is sent over a separate kind of channel.
+// A block which uses vector signals implements the VectorBlock interface
+// in addition to Block. Inputs and Outputs still return the number of
+// scalar ports, VectorInputs and VectorOutputs the number of vector ports.
+// The system then calls StepVector instead of Step.
+//
+// Existing scalar blocks need no changes. To use them with a vector,
+// unpack it with DemuxN and pack the results with MuxN.
+// Vector ports are connected with ConnectVector.
+
+// A VectorBlock is a block with vector inputs or outputs.
+type VectorBlock interface {
+	Block
+	VectorInputs() int
+	VectorOutputs() int
+
+	// StepVector is called instead of Step with the scalar
+	// inputs and outputs in and out, and the vector
+	// inputs and outputs vin and vout.
+	// Slices which are sent to vout must not be modified later,
+	// as they are passed on to the receiver.
+	StepVector(in, out []float64, vin, vout [][]float64) bool
+}
+
+// ConnectVector creates a vector channel between src at vector output o
+// and dst at vector input i.
+func (s *System) ConnectVector(src, dst, o, i int) error {
+	if src < 0 || src >= len(s.blocks) || o < 0 || o >= len(s.blocks[src].VOut) {
+		return fmt.Errorf("block %d has no vector output %d", src, o)
+	}
+	if dst < 0 || dst >= len(s.blocks) || i < 0 || i >= len(s.blocks[dst].VIn) {
+		return fmt.Errorf("block %d has no vector input %d", dst, i)
+	}
+	c := connection{src: src, dst: dst, o: o, i: i, vector: true}
+	s.connections = append(s.connections, c)
+	s.connect(c)
+	return nil
+}
+
+// MuxN packs N scalar inputs into one vector output.
+type MuxN struct {
+	N int // Number of inputs.
+}
+
+func (b MuxN) Validate() error {
+	if b.N < 1 {
+		return fmt.Errorf("muxn: N must be positive: %d", b.N)
+	}
+	return nil
+}
+func (b MuxN) InputNames() []string  { return PortNames("in", b.N) }
+func (b MuxN) OutputNames() []string { return nil }
+func (b MuxN) Inputs() int           { return b.N }
+func (b MuxN) Outputs() int          { return 0 }
+func (b MuxN) VectorInputs() int     { return 0 }
+func (b MuxN) VectorOutputs() int    { return 1 }
+func (b MuxN) Step(in, out []float64) bool {
+	log.Print("muxn: Step is called without vector outputs")
+	return false
+}
+func (b MuxN) StepVector(in, out []float64, vin, vout [][]float64) bool {
+	vout[0] = append([]float64(nil), in...)
+	return true
+}
+
+// DemuxN unpacks a vector input of length N into N scalar outputs.
+type DemuxN struct {
+	N int // Number of outputs.
+}
+
+func (b DemuxN) Validate() error {
+	if b.N < 1 {
+		return fmt.Errorf("demuxn: N must be positive: %d", b.N)
+	}
+	return nil
+}
+func (b DemuxN) InputNames() []string  { return nil }
+func (b DemuxN) OutputNames() []string { return PortNames("out", b.N) }
+func (b DemuxN) Inputs() int           { return 0 }
+func (b DemuxN) Outputs() int          { return b.N }
+func (b DemuxN) VectorInputs() int     { return 1 }
+func (b DemuxN) VectorOutputs() int    { return 0 }
+func (b DemuxN) Step(in, out []float64) bool {
+	log.Print("demuxn: Step is called without vector inputs")
+	return false
+}
+func (b DemuxN) StepVector(in, out []float64, vin, vout [][]float64) bool {
+	if len(vin[0]) != b.N {
+		log.Printf("demuxn: got a vector of length %d, want %d", len(vin[0]), b.N)
+		return false
+	}
+	copy(out, vin[0])
+	return true
+}
@@ -0,0 +1,65 @@
+package loops
+
+import (
+	"context"
+	"testing"
+)
+
+// vectorScale is a vector block which scales it's vector input.
+type vectorScale float64
+
+func (b vectorScale) Inputs() int                 { return 0 }
+func (b vectorScale) Outputs() int                { return 0 }
+func (b vectorScale) VectorInputs() int           { return 1 }
+func (b vectorScale) VectorOutputs() int          { return 1 }
+func (b vectorScale) Step(in, out []float64) bool { return false }
+func (b vectorScale) StepVector(in, out []float64, vin, vout [][]float64) bool {
+	v := make([]float64, len(vin[0]))
+	for i, x := range vin[0] {
+		v[i] = float64(b) * x
+	}
+	vout[0] = v
+	return true
+}
+
+// TestMuxN packs three sources into a vector, scales it and unpacks it.
+func TestMuxN(t *testing.T) {
+	scope := Scope{NumChannels: 3}
+	var s System
+	s.Add(Source(1))        // 0
+	s.Add(Source(2))        // 1
+	s.Add(&Stop{Time: 0.1}) // 2
+	s.Add(MuxN{N: 3})       // 3
+	s.Add(vectorScale(10))  // 4
+	s.Add(DemuxN{N: 3})     // 5
+	s.Add(&scope)           // 6
+	s.Add(Source(3))        // 7
+	s.Connect(0, 3, 0, 0)
+	s.Connect(1, 3, 0, 1)
+	s.Connect(7, 2, 0, 0)
+	s.Connect(2, 3, 0, 2)
+	for _, c := range [][2]int{{3, 4}, {4, 5}} {
+		if err := s.ConnectVector(c[0], c[1], 0, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		s.Connect(5, 6, i, i)
+	}
+	if err := s.ConnectVector(0, 3, 0, 0); err == nil {
+		t.Fatal("expected an error for a block without vector outputs")
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(scope.Time) == 0 {
+		t.Fatal("nothing recorded")
+	}
+	for i, want := range []float64{10, 20, 30} {
+		for _, v := range scope.Data[i] {
+			if v != want {
+				t.Fatalf("channel %d: got %v, want %v", i, v, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package loops
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestWatchdog starts ode1 without the initial condition,
+// which blocks the add block forever.
+func TestWatchdog(t *testing.T) {
+	s := ode1System(&Recorder{NumChannels: 1}, &Stop{Time: 1})
+	s.initials = nil
+	s.Watchdog = 100 * time.Millisecond
+	done := make(chan error)
+	go func() { done <- s.Start(context.Background()) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrWatchdogTimeout) {
+			t.Fatalf("got %v, want %v", err, ErrWatchdogTimeout)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the watchdog did not end the simulation")
+	}
+
+	// A running simulation is not affected.
+	s = ode1System(&Recorder{NumChannels: 1}, &Stop{Time: 1})
+	s.Watchdog = 100 * time.Millisecond
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}